@@ -38,11 +38,125 @@ type BlockStatement struct {
 	Statements []Statement
 }
 
+// LetStatement is a let, const or var declaration, Token tells which.
+// Value is nil without initializer
 type LetStatement struct {
 	Statement
 	Token t.Token
 	Name  *IdentifierExpression
-	Value Expression
+	// Pattern is the ObjectPattern or ArrayPattern of a destructuring declaration, Name is nil then
+	Pattern Expression
+	Value   Expression
+}
+
+// Target returns Name or Pattern
+func (s *LetStatement) Target() Expression {
+	if s.Pattern != nil {
+		return s.Pattern
+	}
+	return s.Name
+}
+
+// WhileStatement while (Condition) Body
+type WhileStatement struct {
+	Statement
+	Token     t.Token
+	Condition Expression
+	Body      Statement
+}
+
+// DoWhileStatement do Body while (Condition)
+type DoWhileStatement struct {
+	Statement
+	Token     t.Token
+	Body      Statement
+	Condition Expression
+}
+
+// ForStatement for (Init; Condition; Update) Body, each part of the head is optional.
+// Init is a LetStatement or an ExpressionStatement
+type ForStatement struct {
+	Statement
+	Token     t.Token
+	Init      Statement
+	Condition Expression
+	Update    Expression
+	Body      Statement
+}
+
+// ForInStatement for (Left in Right) Body, over the enumerable string keys of Right.
+// Left is a LetStatement without Value or an ExpressionStatement of an assignment target
+type ForInStatement struct {
+	Statement
+	Token t.Token
+	Left  Statement
+	Right Expression
+	Body  Statement
+}
+
+// ForOfStatement for (Left of Right) Body, over the values of the iterable Right.
+// Left is like ForInStatement.Left
+type ForOfStatement struct {
+	Statement
+	Token t.Token
+	Left  Statement
+	Right Expression
+	Body  Statement
+}
+
+// SwitchStatement switch (Discriminant) { Cases }
+type SwitchStatement struct {
+	Statement
+	Token        t.Token
+	Discriminant Expression
+	Cases        []*SwitchCase
+}
+
+// SwitchCase case Test: Consequent, Test is nil for default
+type SwitchCase struct {
+	Token      t.Token
+	Test       Expression
+	Consequent []Statement
+}
+
+// ThrowStatement throw Argument
+type ThrowStatement struct {
+	Statement
+	Token    t.Token
+	Argument Expression
+}
+
+// TryStatement try Block catch (Param) Handler finally Finalizer.
+// Param is nil for catch without a binding, Handler or Finalizer is nil without its clause
+type TryStatement struct {
+	Statement
+	Token     t.Token
+	Block     *BlockStatement
+	Param     *IdentifierExpression
+	Handler   *BlockStatement
+	Finalizer *BlockStatement
+}
+
+// BreakStatement break Label, Label is optional
+type BreakStatement struct {
+	Statement
+	Token t.Token
+	Label *IdentifierExpression
+}
+
+// ContinueStatement continue Label, Label is optional
+type ContinueStatement struct {
+	Statement
+	Token t.Token
+	Label *IdentifierExpression
+}
+
+// LabeledStatement Label: Body
+type LabeledStatement struct {
+	Statement
+	Token t.Token
+	Label *IdentifierExpression
+	Body  Statement
 }
 
 type ReturnStatement struct {
@@ -51,16 +165,43 @@ type ReturnStatement struct {
 	ReturnValue Expression
 }
 
-type IntegerLiteral struct {
+type NumericLiteral struct {
 	Expression
 	Token t.Token
-	Value int64
+	Value float64
 }
 
 type StringLiteral struct {
 	Expression
 	Token t.Token
+	// Value is the cooked string, Raw is the source text between quotes
 	Value string
+	Raw   string
+}
+
+// TemplateLiteral `a${b}c`, Quasis are the strings around Expressions,
+// there is always one more quasi than expressions
+type TemplateLiteral struct {
+	Expression
+	Token       t.Token
+	Quasis      []*StringLiteral
+	Expressions []Expression
+}
+
+// TaggedTemplateExpression tag`a${b}c`
+type TaggedTemplateExpression struct {
+	Expression
+	Token t.Token
+	Tag   Expression
+	Quasi *TemplateLiteral
+}
+
+// RegExpLiteral /pattern/flags
+type RegExpLiteral struct {
+	Expression
+	Token   t.Token
+	Pattern string
+	Flags   string
 }
 
 type BooleanExpression struct {
@@ -69,6 +210,17 @@ type BooleanExpression struct {
 	Value bool
 }
 
+type NullLiteral struct {
+	Expression
+	Token t.Token
+}
+
+// UndefinedLiteral is undefined, which is scanned as a keyword
+type UndefinedLiteral struct {
+	Expression
+	Token t.Token
+}
+
 type InfixExpression struct {
 	Expression
 	Token    t.Token
@@ -77,12 +229,31 @@ type InfixExpression struct {
 	Right    Expression
 }
 
+// LogicalExpression Left && Right, Left || Right or Left ?? Right, Right is evaluated only if needed
+type LogicalExpression struct {
+	Expression
+	Token    t.Token
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+// AssignmentExpression Left = Right, Left is an IdentifierExpression or a pattern
+type AssignmentExpression struct {
+	Expression
+	Token    t.Token
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
 type IdentifierExpression struct {
 	Expression
 	Token t.Token
 	Value string
 }
 
+// ArrayLiteralExpression [a, , ...b], a nil element is a hole
 type ArrayLiteralExpression struct {
 	Expression
 	Token    t.Token
@@ -94,12 +265,53 @@ type IndexExpression struct {
 	Token t.Token
 	Left  Expression
 	Index Expression
+	// Optional a?.[k] is undefined when Left is null or undefined, skipping the rest of the chain
+	Optional bool
+}
+
+// MemberExpression Object.Property, Property is an IdentifierName
+type MemberExpression struct {
+	Expression
+	Token    t.Token
+	Object   Expression
+	Property string
+	// Optional a?.b, see IndexExpression
+	Optional bool
+}
+
+// ChainExpression wraps an optional chain a?.b.c(), which is short-circuited as a whole
+type ChainExpression struct {
+	Expression
+	Token t.Token
+	Chain Expression
+}
+
+type ThisExpression struct {
+	Expression
+	Token t.Token
 }
 
 type ObjectLiteralExpression struct {
 	Expression
 	Token t.Token
-	Pairs map[Expression]Expression
+	// Properties keep the source order, a later property overrides an earlier one with the same key
+	Properties []*Property
+}
+
+// Property is a property definition of an object literal, e.g. a: 1, "a": 1, 1: a, [a]: 1, a, a() {}.
+// Key is a StringLiteral of an identifier or reserved word name, a StringLiteral, a NumericLiteral,
+// or any expression when Computed
+type Property struct {
+	Token    t.Token
+	Key      Expression
+	Value    Expression
+	Computed bool
+	// Shorthand {a}, Value is the IdentifierExpression a
+	Shorthand bool
+	// Method {a() {}}, Value is a FunctionLiteral
+	Method bool
+	// Spread {...a}, Key is nil and Value is the SpreadElement
+	Spread bool
 }
 
 type PrefixExpression struct {
@@ -109,6 +321,23 @@ type PrefixExpression struct {
 	Right    Expression
 }
 
+// AwaitExpression await Argument in an async function
+type AwaitExpression struct {
+	Expression
+	Token    t.Token
+	Argument Expression
+}
+
+// UpdateExpression ++Argument, --Argument, Argument++ or Argument--, Argument is an IdentifierExpression
+type UpdateExpression struct {
+	Expression
+	Token    t.Token
+	Operator string
+	// Prefix is true for ++a, which is the new value, a++ is the old one
+	Prefix   bool
+	Argument Expression
+}
+
 type IfExpression struct {
 	Expression
 	Token       t.Token
@@ -122,8 +351,39 @@ type FunctionLiteral struct {
 	Token t.Token
 	// Name is optional, maybe not exist in anonymous function
 	Name       *IdentifierExpression
-	Parameters []*IdentifierExpression
-	Body       *BlockStatement
+	Parameters []*Parameter
+	// Rest ...args collects the arguments after Parameters, nil without rest parameter
+	Rest *IdentifierExpression
+	// Body of an arrow function with a concise body x => x is a block returning the expression
+	Body *BlockStatement
+	// Arrow functions take this and arguments of the enclosing function, they are not constructors
+	Arrow bool
+	// Async functions return a promise, await in them suspends the call
+	Async bool
+}
+
+// Parameter is a formal parameter, Default is evaluated when the argument is undefined
+type Parameter struct {
+	Token t.Token
+	Name  *IdentifierExpression
+	// Pattern is the ObjectPattern or ArrayPattern of a destructuring parameter, Name is nil then
+	Pattern Expression
+	Default Expression
+}
+
+// Target returns Name or Pattern
+func (p *Parameter) Target() Expression {
+	if p.Pattern != nil {
+		return p.Pattern
+	}
+	return p.Name
+}
+
+// SpreadElement ...Argument in arguments of a call or elements of an array literal
+type SpreadElement struct {
+	Expression
+	Token    t.Token
+	Argument Expression
 }
 
 type CallExpression struct {
@@ -131,4 +391,63 @@ type CallExpression struct {
 	Token        t.Token
 	FunctionName Expression
 	Arguments    []Expression
+	// Optional f?.(), see IndexExpression
+	Optional bool
+}
+
+// MARK: Pattern
+
+// ArrayPattern [a, , b = 1, ...rest] binds the values of an iterable, a nil element is a hole
+type ArrayPattern struct {
+	Expression
+	Token    t.Token
+	Elements []*PatternElement
+	// Rest is the target of ...rest, nil without rest element
+	Rest Expression
+}
+
+// ObjectPattern {a, b: c = 1, ...rest} binds properties of a value
+type ObjectPattern struct {
+	Expression
+	Token      t.Token
+	Properties []*PatternElement
+	// Rest gets the other own properties in a new object, nil without rest element
+	Rest *IdentifierExpression
+}
+
+// PatternElement binds Target to a value, Default is evaluated when the value is undefined.
+// Key and Computed are the property name of an element of an ObjectPattern, like in a Property
+type PatternElement struct {
+	Token    t.Token
+	Key      Expression
+	Computed bool
+	// Target is an IdentifierExpression, an ObjectPattern or an ArrayPattern
+	Target  Expression
+	Default Expression
+}
+
+// BoundNames returns the identifiers bound by an IdentifierExpression or a pattern in source order
+func BoundNames(target Expression) []*IdentifierExpression {
+	var names []*IdentifierExpression
+	switch target := target.(type) {
+	case *IdentifierExpression:
+		names = append(names, target)
+	case *ArrayPattern:
+		for _, element := range target.Elements {
+			if element != nil {
+				names = append(names, BoundNames(element.Target)...)
+			}
+		}
+		if target.Rest != nil {
+			names = append(names, BoundNames(target.Rest)...)
+		}
+	case *ObjectPattern:
+		for _, property := range target.Properties {
+			names = append(names, BoundNames(property.Target)...)
+		}
+		if target.Rest != nil {
+			names = append(names, target.Rest)
+		}
+	}
+	return names
 }
@@ -0,0 +1,160 @@
+package ast
+
+// Inspect calls f for node and then for its children in source order, unless f returns false.
+// Parameters, properties, switch cases and pattern elements are not nodes, f gets their children
+func Inspect(node JSNode, f func(JSNode) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *Program:
+		inspectList(node.Statements, f)
+	case *ExpressionStatement:
+		Inspect(node.Expression, f)
+	case *BlockStatement:
+		inspectList(node.Statements, f)
+	case *LetStatement:
+		Inspect(node.Target(), f)
+		Inspect(node.Value, f)
+	case *WhileStatement:
+		Inspect(node.Condition, f)
+		Inspect(node.Body, f)
+	case *DoWhileStatement:
+		Inspect(node.Body, f)
+		Inspect(node.Condition, f)
+	case *ForStatement:
+		Inspect(node.Init, f)
+		Inspect(node.Condition, f)
+		Inspect(node.Update, f)
+		Inspect(node.Body, f)
+	case *ForInStatement:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+		Inspect(node.Body, f)
+	case *ForOfStatement:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+		Inspect(node.Body, f)
+	case *SwitchStatement:
+		Inspect(node.Discriminant, f)
+		for _, c := range node.Cases {
+			Inspect(c.Test, f)
+			inspectList(c.Consequent, f)
+		}
+	case *ThrowStatement:
+		Inspect(node.Argument, f)
+	case *TryStatement:
+		Inspect(node.Block, f)
+		if node.Param != nil {
+			Inspect(node.Param, f)
+		}
+		if node.Handler != nil {
+			Inspect(node.Handler, f)
+		}
+		if node.Finalizer != nil {
+			Inspect(node.Finalizer, f)
+		}
+	case *BreakStatement:
+		if node.Label != nil {
+			Inspect(node.Label, f)
+		}
+	case *ContinueStatement:
+		if node.Label != nil {
+			Inspect(node.Label, f)
+		}
+	case *LabeledStatement:
+		Inspect(node.Label, f)
+		Inspect(node.Body, f)
+	case *ReturnStatement:
+		Inspect(node.ReturnValue, f)
+	case *TemplateLiteral:
+		for i, quasi := range node.Quasis {
+			Inspect(quasi, f)
+			if i < len(node.Expressions) {
+				Inspect(node.Expressions[i], f)
+			}
+		}
+	case *TaggedTemplateExpression:
+		Inspect(node.Tag, f)
+		Inspect(node.Quasi, f)
+	case *InfixExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+	case *LogicalExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+	case *AssignmentExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+	case *UpdateExpression:
+		Inspect(node.Argument, f)
+	case *ArrayLiteralExpression:
+		// holes are nil
+		inspectList(node.Elements, f)
+	case *IndexExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Index, f)
+	case *MemberExpression:
+		Inspect(node.Object, f)
+	case *ChainExpression:
+		Inspect(node.Chain, f)
+	case *ObjectLiteralExpression:
+		for _, property := range node.Properties {
+			Inspect(property.Key, f)
+			Inspect(property.Value, f)
+		}
+	case *PrefixExpression:
+		Inspect(node.Right, f)
+	case *AwaitExpression:
+		Inspect(node.Argument, f)
+	case *IfExpression:
+		Inspect(node.Condition, f)
+		Inspect(node.Consequence, f)
+		if node.Alternative != nil {
+			Inspect(node.Alternative, f)
+		}
+	case *FunctionLiteral:
+		if node.Name != nil {
+			Inspect(node.Name, f)
+		}
+		for _, parameter := range node.Parameters {
+			Inspect(parameter.Target(), f)
+			Inspect(parameter.Default, f)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, f)
+		}
+		Inspect(node.Body, f)
+	case *SpreadElement:
+		Inspect(node.Argument, f)
+	case *CallExpression:
+		Inspect(node.FunctionName, f)
+		inspectList(node.Arguments, f)
+	case *ArrayPattern:
+		for _, element := range node.Elements {
+			if element != nil {
+				inspectPatternElement(element, f)
+			}
+		}
+		Inspect(node.Rest, f)
+	case *ObjectPattern:
+		for _, property := range node.Properties {
+			inspectPatternElement(property, f)
+		}
+		if node.Rest != nil {
+			Inspect(node.Rest, f)
+		}
+	}
+}
+
+func inspectList[N JSNode](nodes []N, f func(JSNode) bool) {
+	for _, node := range nodes {
+		Inspect(node, f)
+	}
+}
+
+func inspectPatternElement(element *PatternElement, f func(JSNode) bool) {
+	Inspect(element.Key, f)
+	Inspect(element.Target, f)
+	Inspect(element.Default, f)
+}
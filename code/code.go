@@ -81,6 +81,49 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+	OpRegExp
+	OpUndefined
+	OpCheckInitialized
+	OpReferenceError
+	OpGetIterator
+	OpEnumerate
+	OpIteratorNext
+	OpIteratorClose
+	OpCase
+	OpJumpTable
+	OpThrow
+	OpJumpNotUndefined
+	OpAppend
+	OpAppendSpread
+	OpCallSpread
+	OpDup
+	OpIteratorValue
+	OpIteratorRest
+	OpObjectRest
+	OpCheckObjectCoercible
+	OpMod
+	OpExp
+	OpShiftLeft
+	OpShiftRight
+	OpUnsignedShiftRight
+	OpBitwiseAnd
+	OpBitwiseOr
+	OpBitwiseXor
+	OpJumpTrue
+	OpJumpNotNullish
+	OpNewCell
+	OpGetCell
+	OpSetCell
+	OpGetFreeCell
+	OpSetFreeCell
+	OpGetProperty
+	OpJumpNullish
+	OpCallMethod
+	OpCallMethodSpread
+	OpThis
+	OpArguments
+	OpToNumeric
+	OpAwait
 )
 
 type Definition struct {
@@ -122,6 +165,87 @@ var definitions = map[Opcode]*Definition{
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	// OpRegExp creates a RegExp object from the constant, one per evaluation
+	OpRegExp:    {"OpRegExp", []int{2}},
+	OpUndefined: {"OpUndefined", []int{}},
+	// OpCheckInitialized fails if the let or const on top of stack, named by the constant, is in the temporal dead zone
+	OpCheckInitialized: {"OpCheckInitialized", []int{2}},
+	// OpReferenceError fails with the name constant, for a let or const used before its declaration
+	OpReferenceError: {"OpReferenceError", []int{2}},
+	// OpGetIterator replaces the iterable on top of stack with its iterator record for for-of
+	OpGetIterator: {"OpGetIterator", []int{}},
+	// OpEnumerate replaces the object on top of stack with an iterator record of its keys for for-in
+	OpEnumerate: {"OpEnumerate", []int{}},
+	// OpIteratorNext pushes the next value of the iterator record on top of stack,
+	// when done it pops the record and jumps to the operand
+	OpIteratorNext: {"OpIteratorNext", []int{2}},
+	// OpIteratorClose pops the iterator record and calls return of its iterator object, for an early exit
+	OpIteratorClose: {"OpIteratorClose", []int{}},
+	// OpCase pops a case value, if it strictly equals the switch value below it,
+	// it pops the switch value too and jumps to the operand
+	OpCase: {"OpCase", []int{2}},
+	// OpJumpTable pops the switch value and jumps to its clause in the JumpTable constant
+	OpJumpTable: {"OpJumpTable", []int{2}},
+	// OpThrow throws the value on top of stack to the nearest handler
+	OpThrow: {"OpThrow", []int{}},
+	// OpJumpNotUndefined pops a value and jumps if it is not undefined, for default values
+	OpJumpNotUndefined: {"OpJumpNotUndefined", []int{2}},
+	// OpAppend pops a value and appends it to the array below it
+	OpAppend: {"OpAppend", []int{}},
+	// OpAppendSpread pops an iterable and appends its values to the array below it
+	OpAppendSpread: {"OpAppendSpread", []int{}},
+	// OpCallSpread pops an array and calls the function below it with the elements as arguments
+	OpCallSpread: {"OpCallSpread", []int{}},
+	OpDup:        {"OpDup", []int{}},
+	// OpIteratorValue pushes the next value of the iterator record on top of stack, undefined when it is done
+	OpIteratorValue: {"OpIteratorValue", []int{}},
+	// OpIteratorRest pushes an array of the remaining values of the iterator record on top of stack
+	OpIteratorRest: {"OpIteratorRest", []int{}},
+	// OpObjectRest pops the operand count of keys and an object,
+	// it pushes a new object with the other own properties for {a, ...rest}
+	OpObjectRest: {"OpObjectRest", []int{1}},
+	// OpCheckObjectCoercible fails if the value on top of stack is undefined or null, before destructuring it
+	OpCheckObjectCoercible: {"OpCheckObjectCoercible", []int{}},
+	OpMod:                  {"OpMod", []int{}},
+	OpExp:                  {"OpExp", []int{}},
+	OpShiftLeft:            {"OpShiftLeft", []int{}},
+	OpShiftRight:           {"OpShiftRight", []int{}},
+	OpUnsignedShiftRight:   {"OpUnsignedShiftRight", []int{}},
+	OpBitwiseAnd:           {"OpBitwiseAnd", []int{}},
+	OpBitwiseOr:            {"OpBitwiseOr", []int{}},
+	OpBitwiseXor:           {"OpBitwiseXor", []int{}},
+	// OpJumpTrue pops a value and jumps if it is truthy
+	OpJumpTrue: {"OpJumpTrue", []int{2}},
+	// OpJumpNotNullish pops a value and jumps if it is neither undefined nor null
+	OpJumpNotNullish: {"OpJumpNotNullish", []int{2}},
+	// OpNewCell puts a new uninitialized cell in the local, for a variable which closures capture and which is assigned,
+	// the closures and the function share it
+	OpNewCell: {"OpNewCell", []int{1}},
+	// OpGetCell pushes the value of the cell in the local
+	OpGetCell: {"OpGetCell", []int{1}},
+	// OpSetCell pops a value into the cell in the local
+	OpSetCell: {"OpSetCell", []int{1}},
+	// OpGetFreeCell pushes the value of the cell in the free variable
+	OpGetFreeCell: {"OpGetFreeCell", []int{1}},
+	// OpSetFreeCell pops a value into the cell in the free variable
+	OpSetFreeCell: {"OpSetFreeCell", []int{1}},
+	// OpGetProperty pops an object and pushes its property named by the string constant, for a.b
+	OpGetProperty: {"OpGetProperty", []int{2}},
+	// OpJumpNullish pops a value and jumps if it is undefined or null
+	OpJumpNullish: {"OpJumpNullish", []int{2}},
+	// OpCallMethod calls like OpCall with the value below the callee as this, which it pops too
+	OpCallMethod: {"OpCallMethod", []int{1}},
+	// OpCallMethodSpread calls like OpCallSpread with the value below the callee as this
+	OpCallMethodSpread: {"OpCallMethodSpread", []int{}},
+	// OpThis pushes this of current function
+	OpThis: {"OpThis", []int{}},
+	// OpArguments pushes the arguments object of current function, an array of all the arguments passed
+	OpArguments: {"OpArguments", []int{}},
+	// OpToNumeric pops a value and pushes it converted to a number, the old value of ++ and --
+	OpToNumeric: {"OpToNumeric", []int{}},
+	// OpAwait pops a value and suspends the async function until it is settled,
+	// then it pushes the fulfillment value or throws the rejection reason
+	OpAwait: {"OpAwait", []int{}},
 }
 
 func Lookup(op byte) (*Definition, error) {
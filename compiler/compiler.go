@@ -5,12 +5,64 @@ import (
 	"github.com/Seeingu/coldmoon/ast"
 	"github.com/Seeingu/coldmoon/code"
 	"github.com/Seeingu/coldmoon/object"
+	"github.com/Seeingu/coldmoon/token"
+	"slices"
+	"strings"
 )
 
 type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	// breakables are the enclosing loops and labeled statements, innermost last
+	breakables []*breakable
+	// labels of the labeled statement being compiled, taken by the statement it labels
+	labels []string
+	// handlers of the try statements compiled, inner ones first
+	handlers []object.Handler
+	// chain is the optional chain being compiled
+	chain *optionalChain
+}
+
+// optionalChain collects the jumps of the optional accesses and calls in a chain, which are patched at its end.
+// A jump leaves a nullish value on the stack, or a receiver and the nullish method of a?.b?.()
+type optionalChain struct {
+	jumps       []int
+	methodJumps []int
+}
+
+// breakable is a statement break and continue jump out of,
+// the jumps are patched when its end and continue positions are known
+type breakable struct {
+	labels []string
+	// loop is true for iteration statements, the targets of continue and break without label
+	loop bool
+	// iterator is true for for-in and for-of, their iterator record is on the stack while they run
+	iterator bool
+	// isSwitch is true for switch statements, the targets of break without label but not of continue
+	isSwitch bool
+	// try is set for try statements, which are never jump targets
+	try       *tryBlock
+	breaks    []int
+	continues []int
+}
+
+// tryBlock is the part of a try statement covered by its handler, the try block, or the try and catch blocks
+// with finally. A jump out of it runs the finally block, which the handler doesn't cover
+type tryBlock struct {
+	finally *ast.BlockStatement
+	// start of the range being compiled
+	start  int
+	ranges [][2]int
+	// stackDepth is the number of iterator records on the stack
+	stackDepth int
+}
+
+// cover ends the range being compiled at end
+func (t *tryBlock) cover(end int) {
+	if end > t.start {
+		t.ranges = append(t.ranges, [2]int{t.start, end})
+	}
 }
 
 const VirtualOffset = 9999
@@ -46,11 +98,10 @@ func New() *Compiler {
 func (c *Compiler) Compile(node ast.JSNode) error {
 	switch node := node.(type) {
 	case *ast.Program:
-		for _, s := range node.Statements {
-			err := c.Compile(s)
-			if err != nil {
-				return err
-			}
+		c.symbolTable.cells = cellNames(node)
+		err := c.compileScope(node.Statements)
+		if err != nil {
+			return err
 		}
 	case *ast.ExpressionStatement:
 		err := c.Compile(node.Expression)
@@ -59,29 +110,107 @@ func (c *Compiler) Compile(node ast.JSNode) error {
 		}
 		c.emit(code.OpPop)
 	case *ast.BlockStatement:
+		c.enterBlockScope()
+		err := c.declareLexical(node.Statements)
+		if err != nil {
+			return err
+		}
 		for _, statement := range node.Statements {
 			err := c.Compile(statement)
 			if err != nil {
 				return err
 			}
 		}
+		c.leaveBlockScope()
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
-		err := c.Compile(node.Value)
+		if node.Pattern != nil {
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+			return c.compilePattern(node.Pattern, c.initialize)
+		}
+		// declared when entering the scope
+		symbol, _ := c.symbolTable.Resolve(node.Name.Value)
+		binding := c.symbolTable.ResolveBinding(node.Name.Value)
+		if node.Value == nil {
+			if binding.Kind == VarDeclaration {
+				// undefined since the function start
+				break
+			}
+			c.emit(code.OpUndefined)
+		} else {
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+		}
+		binding.Initialized = true
+		c.setSymbol(symbol)
+	case *ast.AssignmentExpression:
+		if node.Operator != "=" {
+			err := c.compileCompoundAssignment(node)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		err := c.Compile(node.Right)
 		if err != nil {
 			return err
 		}
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
+		if _, ok := node.Left.(*ast.IdentifierExpression); !ok {
+			// the value of a destructuring assignment is the right side
+			c.emit(code.OpDup)
+			return c.compilePattern(node.Left, c.assign)
+		}
+		symbol, err := c.assignSymbol(node.Left.(*ast.IdentifierExpression).Value)
+		if err != nil {
+			return err
+		}
+		c.loadSymbol(symbol)
+	case *ast.UpdateExpression:
+		err := c.Compile(node.Argument)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpToNumeric)
+		if !node.Prefix {
+			// the old value converted to a number is the result
+			c.emit(code.OpDup)
+		}
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: 1}))
+		if node.Operator == "++" {
+			c.emit(code.OpAdd)
 		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
+			c.emit(code.OpSub)
+		}
+		symbol, err := c.assignSymbol(node.Argument.(*ast.IdentifierExpression).Value)
+		if err != nil {
+			return err
+		}
+		if node.Prefix {
+			c.loadSymbol(symbol)
 		}
 	case *ast.IdentifierExpression:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
 			return fmt.Errorf("symbol not found: %s", node.Value)
 		}
+		binding := c.symbolTable.ResolveBinding(node.Value)
+		if binding == nil || binding.Initialized && !binding.MaybeUninitialized {
+			c.loadSymbol(symbol)
+			break
+		}
+		name := c.addConstant(&object.StringObject{Value: node.Value})
+		if !binding.Initialized && c.symbolTable.InFunction(binding) {
+			// code before the declaration in the same function always runs before the initialization
+			c.emit(code.OpReferenceError, name)
+			break
+		}
+		// a function declared before the initialization may be called after it
 		c.loadSymbol(symbol)
+		c.emit(code.OpCheckInitialized, name)
 	case *ast.IfExpression:
 		err := c.Compile(node.Condition)
 		if err != nil {
@@ -94,9 +223,7 @@ func (c *Compiler) Compile(node ast.JSNode) error {
 			return err
 		}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.keepBlockValue(node.Consequence.Statements)
 
 		elseJumpPos := c.emit(code.OpJump, VirtualOffset)
 
@@ -112,9 +239,7 @@ func (c *Compiler) Compile(node ast.JSNode) error {
 				return err
 			}
 
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+			c.keepBlockValue(node.Alternative.Statements)
 
 			afterAlternativePos := len(c.currentInstructions())
 			c.changeOperand(elseJumpPos, afterAlternativePos)
@@ -141,15 +266,11 @@ func (c *Compiler) Compile(node ast.JSNode) error {
 		if err != nil {
 			return err
 		}
+		if op, ok := binaryOperators[node.Operator]; ok {
+			c.emit(op)
+			break
+		}
 		switch node.Operator {
-		case "+":
-			c.emit(code.OpAdd)
-		case "-":
-			c.emit(code.OpSub)
-		case "*":
-			c.emit(code.OpMul)
-		case "/":
-			c.emit(code.OpDiv)
 		case ">":
 			c.emit(code.OpGreaterThan)
 		case "<":
@@ -161,55 +282,131 @@ func (c *Compiler) Compile(node ast.JSNode) error {
 		default:
 			return fmt.Errorf("unknown operator %s", node.Operator)
 		}
+	case *ast.LogicalExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		jump, ok := logicalOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		// the left value is the result if the right operand is skipped
+		c.emit(code.OpDup)
+		skipPos := c.emit(jump, VirtualOffset)
+		c.emit(code.OpPop)
+		err = c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+		c.changeOperand(skipPos, len(c.currentInstructions()))
 	case *ast.ArrayLiteralExpression:
-		for _, element := range node.Elements {
-			err := c.Compile(element)
-			if err != nil {
-				return err
-			}
+		err := c.compileArray(node.Elements)
+		if err != nil {
+			return err
 		}
-		c.emit(code.OpArray, len(node.Elements))
 	case *ast.ObjectLiteralExpression:
-		var keys []ast.Expression
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-		for _, k := range keys {
-			err := c.Compile(k)
+		for _, property := range node.Properties {
+			if property.Spread {
+				// {...a} is parsed for the rest element of patterns
+				return fmt.Errorf("spread properties in object literals are not supported")
+			}
+			err := c.Compile(property.Key)
 			if err != nil {
 				return err
 			}
-			err = c.Compile(node.Pairs[k])
+			err = c.Compile(property.Value)
 			if err != nil {
 				return err
 			}
 		}
-		c.emit(code.OpObject, len(keys)*2)
+		c.emit(code.OpObject, len(node.Properties)*2)
 	case *ast.IndexExpression:
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
 		}
+		if node.Optional {
+			c.emitOptionalCheck(false)
+		}
 		err = c.Compile(node.Index)
 		if err != nil {
 			return err
 		}
 		c.emit(code.OpIndex)
+	case *ast.MemberExpression:
+		err := c.Compile(node.Object)
+		if err != nil {
+			return err
+		}
+		if node.Optional {
+			c.emitOptionalCheck(false)
+		}
+		c.emit(code.OpGetProperty, c.addConstant(&object.StringObject{Value: node.Property}))
+	case *ast.ChainExpression:
+		err := c.compileChain(node)
+		if err != nil {
+			return err
+		}
+	case *ast.ThisExpression:
+		c.emit(code.OpThis)
+	case *ast.AwaitExpression:
+		err := c.Compile(node.Argument)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpAwait)
 	case *ast.FunctionLiteral:
 		c.enterScope()
+		c.symbolTable.cells = cellNames(node)
 		if node.Name != nil {
 			c.symbolTable.DefineFunctionName(node.Name.Value)
 		}
 
+		// arguments are the first locals, the names bound by patterns follow
+		for i, parameter := range node.Parameters {
+			_, err := c.symbolTable.Declare(parameterName(i, parameter), VarDeclaration)
+			if err != nil {
+				return err
+			}
+		}
+		if node.Rest != nil {
+			_, err := c.symbolTable.Declare(node.Rest.Value, VarDeclaration)
+			if err != nil {
+				return err
+			}
+		}
 		for _, parameter := range node.Parameters {
-			c.symbolTable.Define(parameter.Value)
+			err := c.symbolTable.DeclarePattern(parameter.Pattern, VarDeclaration)
+			if err != nil {
+				return err
+			}
+		}
+		useArguments := !node.Arrow && usesArguments(node)
+		if useArguments {
+			_, err := c.symbolTable.Declare("arguments", VarDeclaration)
+			if err != nil {
+				return err
+			}
+		}
+		c.newParameterCells(node)
+		if useArguments {
+			symbol, _ := c.symbolTable.Resolve("arguments")
+			c.newCell(symbol)
+			c.emit(code.OpArguments)
+			c.setSymbol(symbol)
+		}
+		err := c.compileParameters(node)
+		if err != nil {
+			return err
 		}
 
-		err := c.Compile(node.Body)
+		err = c.compileScope(node.Body.Statements)
 		if err != nil {
 			return err
 		}
-		if c.lastInstructionIs(code.OpPop) {
+		// the value of a last expression statement is returned
+		if c.lastInstructionIs(code.OpPop) && endsWithExpression(node.Body.Statements) {
 			c.replaceLastPopWithReturn()
 		}
 		if !c.lastInstructionIs(code.OpReturnValue) {
@@ -217,47 +414,268 @@ func (c *Compiler) Compile(node ast.JSNode) error {
 		}
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		handlers := c.currentScope().handlers
 		instructions := c.leaveScope()
 		for _, s := range freeSymbols {
-			c.loadSymbol(s)
+			c.loadCapture(s)
 		}
 		compiledFn := &object.CompiledFunction{
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			Rest:          node.Rest != nil,
+			Handlers:      handlers,
+			Arrow:         node.Arrow,
+			Arguments:     useArguments,
+			Async:         node.Async,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+	case *ast.WhileStatement:
+		loop := c.enterBreakable(true)
+		start := len(c.currentInstructions())
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		exitPos := c.emit(code.OpJumpFalse, VirtualOffset)
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, start)
+		c.changeOperand(exitPos, len(c.currentInstructions()))
+		c.leaveBreakable(loop, start)
+	case *ast.DoWhileStatement:
+		loop := c.enterBreakable(true)
+		start := len(c.currentInstructions())
+		err := c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		continuePos := len(c.currentInstructions())
+		err = c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		exitPos := c.emit(code.OpJumpFalse, VirtualOffset)
+		c.emit(code.OpJump, start)
+		c.changeOperand(exitPos, len(c.currentInstructions()))
+		c.leaveBreakable(loop, continuePos)
+	case *ast.ForStatement:
+		loop := c.enterBreakable(true)
+		// let and const of the head are in a block around the loop,
+		// closures capture values, or cells copied before each update, so every iteration has its own copy of them
+		c.enterBlockScope()
+		var cells []Symbol
+		if node.Init != nil {
+			err := c.declareLexical([]ast.Statement{node.Init})
+			if err != nil {
+				return err
+			}
+			err = c.Compile(node.Init)
+			if err != nil {
+				return err
+			}
+			cells = c.iterationCells(node.Init)
+			c.copyCells(cells)
+		}
+		start := len(c.currentInstructions())
+		exitPos := -1
+		if node.Condition != nil {
+			err := c.Compile(node.Condition)
+			if err != nil {
+				return err
+			}
+			exitPos = c.emit(code.OpJumpFalse, VirtualOffset)
+		}
+		err := c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		continuePos := len(c.currentInstructions())
+		c.copyCells(cells)
+		if node.Update != nil {
+			err := c.Compile(node.Update)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpPop)
+		}
+		c.emit(code.OpJump, start)
+		if exitPos >= 0 {
+			c.changeOperand(exitPos, len(c.currentInstructions()))
+		}
+		c.leaveBlockScope()
+		c.leaveBreakable(loop, continuePos)
+	case *ast.SwitchStatement:
+		err := c.compileSwitch(node)
+		if err != nil {
+			return err
+		}
+	case *ast.ThrowStatement:
+		err := c.Compile(node.Argument)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryStatement:
+		err := c.compileTry(node)
+		if err != nil {
+			return err
+		}
+	case *ast.ForInStatement:
+		err := c.compileForInOf(node.Left, node.Right, node.Body, code.OpEnumerate)
+		if err != nil {
+			return err
+		}
+	case *ast.ForOfStatement:
+		err := c.compileForInOf(node.Left, node.Right, node.Body, code.OpGetIterator)
+		if err != nil {
+			return err
+		}
+	case *ast.LabeledStatement:
+		err := c.addLabel(node.Label.Value)
+		if err != nil {
+			return err
+		}
+		switch node.Body.(type) {
+		case *ast.WhileStatement, *ast.DoWhileStatement, *ast.ForStatement, *ast.ForInStatement, *ast.ForOfStatement,
+			*ast.LabeledStatement:
+			// the loop or the next labeled statement takes the label
+			return c.Compile(node.Body)
+		}
+		labeled := c.enterBreakable(false)
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		c.leaveBreakable(labeled, -1)
+	case *ast.BreakStatement:
+		target, err := c.jumpTarget(node.Label, false)
+		if err != nil {
+			return err
+		}
+		tries, err := c.exitBreakables(target)
+		if err != nil {
+			return err
+		}
+		target.breaks = append(target.breaks, c.emit(code.OpJump, VirtualOffset))
+		c.resumeTries(tries)
+	case *ast.ContinueStatement:
+		target, err := c.jumpTarget(node.Label, true)
+		if err != nil {
+			return err
+		}
+		tries, err := c.exitBreakables(target)
+		if err != nil {
+			return err
+		}
+		target.continues = append(target.continues, c.emit(code.OpJump, VirtualOffset))
+		c.resumeTries(tries)
 	case *ast.ReturnStatement:
+		if c.hasFinally() {
+			err := c.compileFinallyReturn(node.ReturnValue)
+			if err != nil {
+				return err
+			}
+			break
+		}
+		if node.ReturnValue == nil {
+			c.emit(code.OpReturn)
+			break
+		}
 		err := c.Compile(node.ReturnValue)
 		if err != nil {
 			return err
 		}
 		c.emit(code.OpReturnValue)
 	case *ast.CallExpression:
-		err := c.Compile(node.FunctionName)
+		method, err := c.compileCallee(node.FunctionName)
 		if err != nil {
 			return err
 		}
+		if node.Optional {
+			c.emitOptionalCheck(method)
+		}
+		if hasSpread(node.Arguments) {
+			err = c.compileArray(node.Arguments)
+			if err != nil {
+				return err
+			}
+			if method {
+				c.emit(code.OpCallMethodSpread)
+			} else {
+				c.emit(code.OpCallSpread)
+			}
+			break
+		}
 		for _, a := range node.Arguments {
 			err := c.Compile(a)
 			if err != nil {
 				return err
 			}
 		}
-		c.emit(code.OpCall, len(node.Arguments))
+		if method {
+			c.emit(code.OpCallMethod, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+	case *ast.RegExpLiteral:
+		re, err := object.NewRegExp(node.Pattern, node.Flags)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression /%s/: %s", node.Pattern, err)
+		}
+		c.emit(code.OpRegExp, c.addConstant(re))
+	case *ast.TemplateLiteral:
+		// `a${b}c` is "a" + b + "c", the first quasi makes sure the result is a string
+		c.emit(code.OpConstant, c.addConstant(&object.StringObject{Value: node.Quasis[0].Value}))
+		for i, e := range node.Expressions {
+			err := c.Compile(e)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpAdd)
+			if quasi := node.Quasis[i+1]; quasi.Value != "" {
+				c.emit(code.OpConstant, c.addConstant(&object.StringObject{Value: quasi.Value}))
+				c.emit(code.OpAdd)
+			}
+		}
+	case *ast.TaggedTemplateExpression:
+		// o.tag`a` is a method call like o.tag(strings)
+		method, err := c.compileCallee(node.Tag)
+		if err != nil {
+			return err
+		}
+		// the strings array is a constant, so every evaluation of the same site gets the same object
+		c.emit(code.OpConstant, c.addConstant(templateStrings(node.Quasi)))
+		for _, e := range node.Quasi.Expressions {
+			err := c.Compile(e)
+			if err != nil {
+				return err
+			}
+		}
+		if method {
+			c.emit(code.OpCallMethod, len(node.Quasi.Expressions)+1)
+		} else {
+			c.emit(code.OpCall, len(node.Quasi.Expressions)+1)
+		}
 	case *ast.StringLiteral:
 		s := &object.StringObject{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(s))
-	case *ast.IntegerLiteral:
-		integer := &object.Integer{Value: node.Value}
-		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.NumericLiteral:
+		number := object.NewNumber(node.Value)
+		c.emit(code.OpConstant, c.addConstant(number))
 	case *ast.BooleanExpression:
 		if node.Value {
 			c.emit(code.OpTrue)
 		} else {
 			c.emit(code.OpFalse)
 		}
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+	case *ast.UndefinedLiteral:
+		c.emit(code.OpUndefined)
 	}
 	return nil
 }
@@ -266,18 +684,26 @@ func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		NumLocals:    c.symbolTable.function.numBlockDefinitions,
+		Handlers:     c.currentScope().handlers,
 	}
 }
 
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	// NumLocals are the locals of the main frame, for let and const in blocks
+	NumLocals int
+	// Handlers are the exception handlers of the main frame
+	Handlers []object.Handler
 }
 
 func (c *Compiler) ByteCode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		NumLocals:    c.symbolTable.function.numBlockDefinitions,
+		Handlers:     c.currentScope().handlers,
 	}
 }
 
@@ -308,61 +734,1168 @@ func (c *Compiler) leaveScope() code.Instructions {
 	return instructions
 }
 
-// MARK: Utils
-
-func (c *Compiler) addConstant(obj object.Object) int {
-	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
+// enterBreakable starts a loop or labeled statement, which takes the pending labels
+func (c *Compiler) enterBreakable(loop bool) *breakable {
+	scope := c.currentScope()
+	b := &breakable{labels: scope.labels, loop: loop}
+	scope.labels = nil
+	scope.breakables = append(scope.breakables, b)
+	return b
 }
 
-func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	ins := code.Make(op, operands...)
-	pos := c.addInstruction(ins)
-
-	c.setLastInstruction(op, pos)
-	return pos
+// leaveBreakable patches break jumps to current position and continue jumps to continuePos
+func (c *Compiler) leaveBreakable(b *breakable, continuePos int) {
+	scope := c.currentScope()
+	scope.breakables = scope.breakables[:len(scope.breakables)-1]
+	for _, pos := range b.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	for _, pos := range b.continues {
+		c.changeOperand(pos, continuePos)
+	}
 }
 
-func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
-	previous := c.currentScope().lastInstruction
-	last := EmittedInstruction{Opcode: op, Position: pos}
-
-	c.scopes[c.scopeIndex].previousInstruction = previous
-	c.scopes[c.scopeIndex].lastInstruction = last
+// exitBreakables compiles the exit from the breakables inside target, from all of them if target is nil:
+// iterators of for-in and for-of loops are closed, finally blocks run.
+// It returns the try statements exited, their handlers don't cover the exit
+func (c *Compiler) exitBreakables(target *breakable) ([]*breakable, error) {
+	breakables := c.currentScope().breakables
+	var tries []*breakable
+	for i := len(breakables) - 1; i >= 0 && breakables[i] != target; i-- {
+		b := breakables[i]
+		if b.iterator {
+			c.emit(code.OpIteratorClose)
+		}
+		if b.try == nil {
+			continue
+		}
+		b.try.cover(len(c.currentInstructions()))
+		tries = append(tries, b)
+		if b.try.finally != nil {
+			// jumps in the finally block are from outside of the try statement
+			c.currentScope().breakables = breakables[:i]
+			err := c.Compile(b.try.finally)
+			c.currentScope().breakables = breakables
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tries, nil
 }
 
-func (c *Compiler) replaceLastPopWithReturn() {
-	lastPos := c.currentScope().lastInstruction.Position
-	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
-	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+// resumeTries starts new ranges of tries after an exit from them
+func (c *Compiler) resumeTries(tries []*breakable) {
+	for _, b := range tries {
+		b.try.start = len(c.currentInstructions())
+	}
+}
 
+// enterTry starts the range of a try statement, finally is nil without a finally block
+func (c *Compiler) enterTry(finally *ast.BlockStatement) *breakable {
+	scope := c.currentScope()
+	stackDepth := 0
+	for _, b := range scope.breakables {
+		if b.iterator {
+			stackDepth++
+		}
+	}
+	b := &breakable{try: &tryBlock{finally: finally, start: len(c.currentInstructions()), stackDepth: stackDepth}}
+	scope.breakables = append(scope.breakables, b)
+	return b
 }
 
-func (c *Compiler) addInstruction(ins []byte) int {
-	posNewInstruction := len(c.currentInstructions())
-	updatedInstructions := append(c.currentInstructions(), ins...)
-	c.scopes[c.scopeIndex].instructions = updatedInstructions
-	return posNewInstruction
+// leaveTry ends the range of a try statement
+func (c *Compiler) leaveTry(b *breakable) {
+	scope := c.currentScope()
+	scope.breakables = scope.breakables[:len(scope.breakables)-1]
+	b.try.cover(len(c.currentInstructions()))
 }
 
-func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
-	ins := c.currentInstructions()
-	for i := 0; i < len(newInstruction); i++ {
-		ins[pos+i] = newInstruction[i]
+// addHandlers adds the handler of a try statement at target
+func (c *Compiler) addHandlers(b *breakable, target int) {
+	scope := c.currentScope()
+	for _, r := range b.try.ranges {
+		scope.handlers = append(scope.handlers, object.Handler{
+			Start:      r[0],
+			End:        r[1],
+			Target:     target,
+			StackDepth: b.try.stackDepth,
+		})
 	}
 }
 
-func (c *Compiler) changeOperand(opPos int, operand int) {
-	op := code.Opcode(c.currentInstructions()[opPos])
-	newInstruction := code.Make(op, operand)
-	c.replaceInstruction(opPos, newInstruction)
+func (c *Compiler) hasFinally() bool {
+	for _, b := range c.currentScope().breakables {
+		if b.try != nil && b.try.finally != nil {
+			return true
+		}
+	}
+	return false
 }
 
-func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
-	if len(c.currentInstructions()) == 0 {
-		return false
+// compileTry compiles a try statement, the finally block is compiled at each exit:
+// after the try and catch blocks, before jumps and returns out of them, and in a handler rethrowing the exception
+func (c *Compiler) compileTry(node *ast.TryStatement) error {
+	if node.Finalizer == nil {
+		return c.compileTryCatch(node)
 	}
-	return c.currentScope().lastInstruction.Opcode == op
+	b := c.enterTry(node.Finalizer)
+	var err error
+	if node.Handler != nil {
+		err = c.compileTryCatch(node)
+	} else {
+		err = c.Compile(node.Block)
+	}
+	if err != nil {
+		return err
+	}
+	c.leaveTry(b)
+	err = c.Compile(node.Finalizer)
+	if err != nil {
+		return err
+	}
+	endPos := c.emit(code.OpJump, VirtualOffset)
+
+	c.addHandlers(b, len(c.currentInstructions()))
+	// the exception waits in a local, the finally block may jump away or throw another one
+	c.enterBlockScope()
+	exception, err := c.symbolTable.Declare("%exception", LetDeclaration)
+	if err != nil {
+		return err
+	}
+	c.setSymbol(exception)
+	err = c.Compile(node.Finalizer)
+	if err != nil {
+		return err
+	}
+	c.loadSymbol(exception)
+	c.emit(code.OpThrow)
+	c.leaveBlockScope()
+
+	c.changeOperand(endPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileTryCatch(node *ast.TryStatement) error {
+	b := c.enterTry(nil)
+	err := c.Compile(node.Block)
+	if err != nil {
+		return err
+	}
+	c.leaveTry(b)
+	endPos := c.emit(code.OpJump, VirtualOffset)
+
+	c.addHandlers(b, len(c.currentInstructions()))
+	// the parameter is declared in the catch block, a let of the same name there is an error
+	c.enterBlockScope()
+	if node.Param == nil {
+		c.emit(code.OpPop)
+	} else {
+		symbol, err := c.symbolTable.Declare(node.Param.Value, LetDeclaration)
+		if err != nil {
+			return err
+		}
+		c.symbolTable.ResolveBinding(node.Param.Value).Initialized = true
+		c.newCell(symbol)
+		c.setSymbol(symbol)
+	}
+	err = c.declareLexical(node.Handler.Statements)
+	if err != nil {
+		return err
+	}
+	for _, statement := range node.Handler.Statements {
+		err := c.Compile(statement)
+		if err != nil {
+			return err
+		}
+	}
+	c.leaveBlockScope()
+
+	c.changeOperand(endPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileFinallyReturn compiles a return from try statements with finally blocks, which run before it.
+// The return value waits in a local, a finally block may jump away or throw
+func (c *Compiler) compileFinallyReturn(value ast.Expression) error {
+	if value == nil {
+		c.emit(code.OpUndefined)
+	} else {
+		err := c.Compile(value)
+		if err != nil {
+			return err
+		}
+	}
+	c.enterBlockScope()
+	symbol, err := c.symbolTable.Declare("%return", LetDeclaration)
+	if err != nil {
+		return err
+	}
+	c.setSymbol(symbol)
+	tries, err := c.exitBreakables(nil)
+	if err != nil {
+		return err
+	}
+	c.loadSymbol(symbol)
+	c.emit(code.OpReturnValue)
+	c.resumeTries(tries)
+	c.leaveBlockScope()
+	return nil
+}
+
+// compileForInOf compiles a for-in or a for-of loop, iterate is the opcode replacing right with its iterator record
+func (c *Compiler) compileForInOf(left ast.Statement, right ast.Expression, body ast.Statement, iterate code.Opcode) error {
+	err := c.Compile(right)
+	if err != nil {
+		return err
+	}
+	c.emit(iterate)
+
+	loop := c.enterBreakable(true)
+	loop.iterator = true
+	start := len(c.currentInstructions())
+	nextPos := c.emit(code.OpIteratorNext, VirtualOffset)
+
+	// let and const of left are in a block of the body,
+	// closures capture values, so every iteration has its own copy of them
+	c.enterBlockScope()
+	switch left := left.(type) {
+	case *ast.LetStatement:
+		err = c.declareLexical([]ast.Statement{left})
+		if err != nil {
+			return err
+		}
+		err = c.compilePattern(left.Target(), c.initialize)
+	case *ast.ExpressionStatement:
+		err = c.compilePattern(left.Expression, c.assign)
+	}
+	if err != nil {
+		return err
+	}
+	err = c.Compile(body)
+	if err != nil {
+		return err
+	}
+	c.leaveBlockScope()
+	c.emit(code.OpJump, start)
+
+	c.leaveBreakable(loop, start)
+	if len(loop.breaks) > 0 {
+		// breaks exit before the iterator is done
+		c.emit(code.OpIteratorClose)
+	}
+	c.changeOperand(nextPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileParameters assigns default values to parameters with undefined arguments and destructures patterns in order,
+// with defaults or patterns the names of a parameter are in the temporal dead zone until then
+func (c *Compiler) compileParameters(node *ast.FunctionLiteral) error {
+	var names []*ast.IdentifierExpression
+	simple := true
+	for _, parameter := range node.Parameters {
+		names = append(names, ast.BoundNames(parameter.Target())...)
+		simple = simple && parameter.Default == nil && parameter.Pattern == nil
+	}
+	if simple {
+		return nil
+	}
+	if node.Rest != nil {
+		names = append(names, node.Rest)
+	}
+	for _, name := range names {
+		c.symbolTable.ResolveBinding(name.Value).Initialized = false
+	}
+
+	for i, parameter := range node.Parameters {
+		symbol, _ := c.symbolTable.Resolve(parameterName(i, parameter))
+		switch {
+		case parameter.Pattern != nil:
+			c.loadSymbol(symbol)
+			err := c.compileDefault(parameter.Default)
+			if err != nil {
+				return err
+			}
+			err = c.compilePattern(parameter.Pattern, c.initialize)
+			if err != nil {
+				return err
+			}
+		case parameter.Default != nil:
+			c.loadSymbol(symbol)
+			skipPos := c.emit(code.OpJumpNotUndefined, VirtualOffset)
+			err := c.Compile(parameter.Default)
+			if err != nil {
+				return err
+			}
+			c.setSymbol(symbol)
+			c.changeOperand(skipPos, len(c.currentInstructions()))
+			fallthrough
+		default:
+			c.symbolTable.ResolveBinding(parameter.Name.Value).Initialized = true
+		}
+	}
+	if node.Rest != nil {
+		c.symbolTable.ResolveBinding(node.Rest.Value).Initialized = true
+	}
+	return nil
+}
+
+// parameterName is the name of the local of an argument, a hidden one for a pattern
+func parameterName(i int, parameter *ast.Parameter) string {
+	if parameter.Name != nil {
+		return parameter.Name.Value
+	}
+	return fmt.Sprintf("%%parameter%d", i)
+}
+
+// compilePattern destructures the value on top of stack into target, store pops a value into a bound name.
+// Computed keys are not supported in an object pattern with a rest element, OpObjectRest would evaluate them again
+func (c *Compiler) compilePattern(target ast.Expression, store func(name *ast.IdentifierExpression) error) error {
+	switch target := target.(type) {
+	case *ast.IdentifierExpression:
+		return store(target)
+	case *ast.ArrayPattern:
+		c.emit(code.OpGetIterator)
+		for _, element := range target.Elements {
+			c.emit(code.OpIteratorValue)
+			if element == nil {
+				c.emit(code.OpPop)
+				continue
+			}
+			err := c.compilePatternElement(element, store)
+			if err != nil {
+				return err
+			}
+		}
+		if target.Rest != nil {
+			c.emit(code.OpIteratorRest)
+			err := c.compilePattern(target.Rest, store)
+			if err != nil {
+				return err
+			}
+		}
+		// a done iterator is not closed
+		c.emit(code.OpIteratorClose)
+	case *ast.ObjectPattern:
+		if len(target.Properties) == 0 {
+			// otherwise reading the first property throws for undefined and null
+			c.emit(code.OpCheckObjectCoercible)
+		}
+		for _, property := range target.Properties {
+			if property.Computed && target.Rest != nil {
+				return fmt.Errorf("computed keys with a rest element are not supported")
+			}
+			c.emit(code.OpDup)
+			err := c.compilePropertyGet(property)
+			if err != nil {
+				return err
+			}
+			err = c.compilePatternElement(property, store)
+			if err != nil {
+				return err
+			}
+		}
+		if target.Rest != nil {
+			c.emit(code.OpDup)
+			for _, property := range target.Properties {
+				err := c.Compile(property.Key)
+				if err != nil {
+					return err
+				}
+			}
+			c.emit(code.OpObjectRest, len(target.Properties))
+			err := store(target.Rest)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpPop)
+	}
+	return nil
+}
+
+// compilePropertyGet replaces the object on top of stack with the value of the key of property, like a.b or a[b]
+func (c *Compiler) compilePropertyGet(property *ast.PatternElement) error {
+	if key, ok := property.Key.(*ast.StringLiteral); ok && !property.Computed {
+		c.emit(code.OpGetProperty, c.addConstant(&object.StringObject{Value: key.Value}))
+		return nil
+	}
+	err := c.Compile(property.Key)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpIndex)
+	return nil
+}
+
+func (c *Compiler) compilePatternElement(element *ast.PatternElement, store func(name *ast.IdentifierExpression) error) error {
+	err := c.compileDefault(element.Default)
+	if err != nil {
+		return err
+	}
+	return c.compilePattern(element.Target, store)
+}
+
+// compileDefault replaces an undefined value on top of stack with the value of expression, if there is one
+func (c *Compiler) compileDefault(expression ast.Expression) error {
+	if expression == nil {
+		return nil
+	}
+	c.emit(code.OpDup)
+	skipPos := c.emit(code.OpJumpNotUndefined, VirtualOffset)
+	c.emit(code.OpPop)
+	err := c.Compile(expression)
+	if err != nil {
+		return err
+	}
+	c.changeOperand(skipPos, len(c.currentInstructions()))
+	return nil
+}
+
+// initialize pops the value of a declared name and ends its temporal dead zone
+func (c *Compiler) initialize(name *ast.IdentifierExpression) error {
+	symbol, _ := c.symbolTable.Resolve(name.Value)
+	c.symbolTable.ResolveBinding(name.Value).Initialized = true
+	c.setSymbol(symbol)
+	return nil
+}
+
+// assign pops the value of an assignment to name
+func (c *Compiler) assign(name *ast.IdentifierExpression) error {
+	_, err := c.assignSymbol(name.Value)
+	return err
+}
+
+// binaryOperators are the opcodes of arithmetic and bitwise operators, also used by compound assignments
+var binaryOperators = map[string]code.Opcode{
+	"+":   code.OpAdd,
+	"-":   code.OpSub,
+	"*":   code.OpMul,
+	"/":   code.OpDiv,
+	"%":   code.OpMod,
+	"**":  code.OpExp,
+	"<<":  code.OpShiftLeft,
+	">>":  code.OpShiftRight,
+	">>>": code.OpUnsignedShiftRight,
+	"&":   code.OpBitwiseAnd,
+	"|":   code.OpBitwiseOr,
+	"^":   code.OpBitwiseXor,
+}
+
+// logicalOperators are the jumps skipping the right operand of a && b, a || b and a ?? b,
+// and the assignment of a &&= b, a ||= b and a ??= b
+var logicalOperators = map[string]code.Opcode{
+	"&&": code.OpJumpFalse,
+	"||": code.OpJumpTrue,
+	"??": code.OpJumpNotNullish,
+}
+
+// compileCompoundAssignment compiles a op= b like a = a op b, a logical assignment is skipped
+// when the jump of the value of a is taken, the value is the result then
+func (c *Compiler) compileCompoundAssignment(node *ast.AssignmentExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+	jump, logical := logicalOperators[strings.TrimSuffix(node.Operator, "=")]
+	skipPos := 0
+	if logical {
+		c.emit(code.OpDup)
+		skipPos = c.emit(jump, VirtualOffset)
+		c.emit(code.OpPop)
+	}
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+	if !logical {
+		op, ok := binaryOperators[strings.TrimSuffix(node.Operator, "=")]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+	}
+	symbol, err := c.assignSymbol(node.Left.(*ast.IdentifierExpression).Value)
+	if err != nil {
+		return err
+	}
+	c.loadSymbol(symbol)
+	if logical {
+		c.changeOperand(skipPos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileCallee compiles the function of a call, a.b() and a[k]() are method calls,
+// which leave the receiver under the function for OpCallMethod
+func (c *Compiler) compileCallee(fn ast.Expression) (method bool, err error) {
+	var receiver, key ast.Expression
+	var optional bool
+	switch fn := fn.(type) {
+	case *ast.MemberExpression:
+		receiver, optional = fn.Object, fn.Optional
+	case *ast.IndexExpression:
+		receiver, key, optional = fn.Left, fn.Index, fn.Optional
+	default:
+		return false, c.Compile(fn)
+	}
+	err = c.Compile(receiver)
+	if err != nil {
+		return false, err
+	}
+	if optional {
+		c.emitOptionalCheck(false)
+	}
+	c.emit(code.OpDup)
+	if key == nil {
+		c.emit(code.OpGetProperty, c.addConstant(&object.StringObject{Value: fn.(*ast.MemberExpression).Property}))
+		return true, nil
+	}
+	err = c.Compile(key)
+	if err != nil {
+		return false, err
+	}
+	c.emit(code.OpIndex)
+	return true, nil
+}
+
+// compileChain compiles an optional chain, a nullish value checked in it skips to the end,
+// where the values left on the stack are replaced with undefined
+func (c *Compiler) compileChain(node *ast.ChainExpression) error {
+	outer := c.currentScope().chain
+	chain := &optionalChain{}
+	c.currentScope().chain = chain
+	err := c.Compile(node.Chain)
+	c.currentScope().chain = outer
+	if err != nil {
+		return err
+	}
+	end := c.emit(code.OpJump, VirtualOffset)
+	// a?.b?.() pops the nullish method, then its receiver like the nullish value of other checks
+	for _, pos := range chain.methodJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	if len(chain.methodJumps) > 0 {
+		c.emit(code.OpPop)
+	}
+	for _, pos := range chain.jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpUndefined)
+	c.changeOperand(end, len(c.currentInstructions()))
+	return nil
+}
+
+// emitOptionalCheck jumps to the end of current chain if the value on top of stack is nullish,
+// method is true if the receiver of a method call is under it
+func (c *Compiler) emitOptionalCheck(method bool) {
+	chain := c.currentScope().chain
+	c.emit(code.OpDup)
+	jump := c.emit(code.OpJumpNullish, VirtualOffset)
+	if method {
+		chain.methodJumps = append(chain.methodJumps, jump)
+	} else {
+		chain.jumps = append(chain.jumps, jump)
+	}
+}
+
+// compileArray builds an array of elements, after the first spread element the values are appended one by one
+func (c *Compiler) compileArray(elements []ast.Expression) error {
+	n := 0
+	for ; n < len(elements); n++ {
+		if _, ok := elements[n].(*ast.SpreadElement); ok {
+			break
+		}
+		err := c.compileElement(elements[n])
+		if err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpArray, n)
+	for _, element := range elements[n:] {
+		if spread, ok := element.(*ast.SpreadElement); ok {
+			err := c.Compile(spread.Argument)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpAppendSpread)
+			continue
+		}
+		err := c.compileElement(element)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpAppend)
+	}
+	return nil
+}
+
+// compileElement compiles an element of an array literal, a hole is undefined
+func (c *Compiler) compileElement(element ast.Expression) error {
+	if element == nil {
+		c.emit(code.OpUndefined)
+		return nil
+	}
+	return c.Compile(element)
+}
+
+func hasSpread(elements []ast.Expression) bool {
+	for _, element := range elements {
+		if _, ok := element.(*ast.SpreadElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSwitch compiles a switch with a jump table when all cases are integers or strings,
+// otherwise with OpCase tests in source order and a jump to default.
+// The clauses follow in source order, so a clause without break falls through to the next one
+func (c *Compiler) compileSwitch(node *ast.SwitchStatement) error {
+	err := c.Compile(node.Discriminant)
+	if err != nil {
+		return err
+	}
+
+	b := c.enterBreakable(false)
+	b.isSwitch = true
+	// all clauses are one block, cases are evaluated in it
+	c.enterBlockScope()
+	var statements []ast.Statement
+	for _, clause := range node.Cases {
+		statements = append(statements, clause.Consequent...)
+	}
+	err = c.declareLexical(statements)
+	if err != nil {
+		return err
+	}
+
+	keys, ok := caseKeys(node.Cases)
+	var table *object.JumpTable
+	casePositions := make([]int, len(node.Cases))
+	var defaultPos int
+	if ok {
+		table = &object.JumpTable{Targets: make(map[object.HashKey]int)}
+		c.emit(code.OpJumpTable, c.addConstant(table))
+	} else {
+		for i, clause := range node.Cases {
+			if clause.Test == nil {
+				continue
+			}
+			err := c.Compile(clause.Test)
+			if err != nil {
+				return err
+			}
+			casePositions[i] = c.emit(code.OpCase, VirtualOffset)
+		}
+		c.emit(code.OpPop)
+		defaultPos = c.emit(code.OpJump, VirtualOffset)
+	}
+
+	defaultTarget := -1
+	// let and const of earlier clauses, a jump to a later clause skips their initialization
+	var declared []string
+	for i, clause := range node.Cases {
+		target := len(c.currentInstructions())
+		switch {
+		case clause.Test == nil:
+			defaultTarget = target
+		case table != nil:
+			// the first of duplicate cases matches
+			if _, ok := table.Targets[keys[i]]; !ok {
+				table.Targets[keys[i]] = target
+			}
+		default:
+			c.changeOperand(casePositions[i], target)
+		}
+		for _, name := range declared {
+			c.symbolTable.ResolveBinding(name).MaybeUninitialized = true
+		}
+		for _, statement := range clause.Consequent {
+			err := c.Compile(statement)
+			if err != nil {
+				return err
+			}
+			if s, ok := statement.(*ast.LetStatement); ok && !s.Token.Is(token.Var) {
+				for _, name := range ast.BoundNames(s.Target()) {
+					declared = append(declared, name.Value)
+				}
+			}
+		}
+	}
+	c.leaveBlockScope()
+	c.leaveBreakable(b, 0)
+
+	if defaultTarget < 0 {
+		defaultTarget = len(c.currentInstructions())
+	}
+	if table != nil {
+		table.Default = defaultTarget
+	} else {
+		c.changeOperand(defaultPos, defaultTarget)
+	}
+	return nil
+}
+
+// caseKeys returns the hash keys of case values for a jump table, false unless all cases are integers or strings
+func caseKeys(cases []*ast.SwitchCase) ([]object.HashKey, bool) {
+	keys := make([]object.HashKey, len(cases))
+	found := false
+	for i, clause := range cases {
+		if clause.Test == nil {
+			continue
+		}
+		var value object.Object
+		switch test := clause.Test.(type) {
+		case *ast.StringLiteral:
+			value = &object.StringObject{Value: test.Value}
+		case *ast.NumericLiteral:
+			value = object.NewNumber(test.Value)
+		case *ast.PrefixExpression:
+			if n, ok := test.Right.(*ast.NumericLiteral); ok && test.Operator == "-" {
+				value = object.NewNumber(-n.Value)
+			}
+		}
+		key, ok := value.(object.Hashable)
+		if !ok || value.Type() != object.TypeInt && value.Type() != object.TypeString {
+			return nil, false
+		}
+		keys[i] = key.HashKey()
+		found = true
+	}
+	return keys, found
+}
+
+func (c *Compiler) addLabel(label string) error {
+	scope := c.currentScope()
+	declared := slices.Contains(scope.labels, label)
+	for _, b := range scope.breakables {
+		declared = declared || slices.Contains(b.labels, label)
+	}
+	if declared {
+		return fmt.Errorf("SyntaxError: label '%s' has already been declared", label)
+	}
+	scope.labels = append(scope.labels, label)
+	return nil
+}
+
+// jumpTarget finds the statement break or continue with label jumps out of,
+// without label it is the innermost loop
+func (c *Compiler) jumpTarget(label *ast.IdentifierExpression, isContinue bool) (*breakable, error) {
+	statement := "break"
+	if isContinue {
+		statement = "continue"
+	}
+	breakables := c.currentScope().breakables
+	for i := len(breakables) - 1; i >= 0; i-- {
+		b := breakables[i]
+		if label == nil && (b.loop || b.isSwitch && !isContinue) {
+			return b, nil
+		}
+		if label != nil && slices.Contains(b.labels, label.Value) {
+			if isContinue && !b.loop {
+				return nil, fmt.Errorf("SyntaxError: illegal continue statement: '%s' does not denote an iteration statement", label.Value)
+			}
+			return b, nil
+		}
+	}
+	if label != nil {
+		return nil, fmt.Errorf("SyntaxError: undefined label '%s'", label.Value)
+	}
+	return nil, fmt.Errorf("SyntaxError: illegal %s statement", statement)
+}
+
+// enterBlockScope starts a symbol table for let and const of a block, the instructions stay in the function
+func (c *Compiler) enterBlockScope() {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlockScope() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
+// compileScope compiles the statements of a function body or program in current symbol table,
+// var declarations anywhere in it are hoisted and undefined from the start
+func (c *Compiler) compileScope(statements []ast.Statement) error {
+	var hoisted []Symbol
+	err := c.hoistVarDeclarations(statements, &hoisted)
+	if err != nil {
+		return err
+	}
+	for _, symbol := range hoisted {
+		c.newCell(symbol)
+		c.emit(code.OpUndefined)
+		c.setSymbol(symbol)
+	}
+	err = c.declareLexical(statements)
+	if err != nil {
+		return err
+	}
+	for _, s := range statements {
+		err := c.Compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hoistVarDeclarations declares var names of statements and nested blocks in the function table,
+// new symbols are appended to hoisted
+func (c *Compiler) hoistVarDeclarations(statements []ast.Statement, hoisted *[]Symbol) error {
+	for _, s := range statements {
+		switch s := s.(type) {
+		case *ast.LetStatement:
+			if !s.Token.Is(token.Var) {
+				continue
+			}
+			for _, name := range ast.BoundNames(s.Target()) {
+				declared := c.symbolTable.ResolveBinding(name.Value)
+				symbol, err := c.symbolTable.Declare(name.Value, VarDeclaration)
+				if err != nil {
+					return err
+				}
+				if declared == nil || !c.symbolTable.InFunction(declared) {
+					*hoisted = append(*hoisted, symbol)
+				}
+			}
+		case *ast.BlockStatement:
+			err := c.hoistVarDeclarations(s.Statements, hoisted)
+			if err != nil {
+				return err
+			}
+		case *ast.WhileStatement:
+			err := c.hoistVarDeclarations([]ast.Statement{s.Body}, hoisted)
+			if err != nil {
+				return err
+			}
+		case *ast.DoWhileStatement:
+			err := c.hoistVarDeclarations([]ast.Statement{s.Body}, hoisted)
+			if err != nil {
+				return err
+			}
+		case *ast.ForStatement:
+			err := c.hoistVarDeclarations([]ast.Statement{s.Init, s.Body}, hoisted)
+			if err != nil {
+				return err
+			}
+		case *ast.ForInStatement:
+			err := c.hoistVarDeclarations([]ast.Statement{s.Left, s.Body}, hoisted)
+			if err != nil {
+				return err
+			}
+		case *ast.ForOfStatement:
+			err := c.hoistVarDeclarations([]ast.Statement{s.Left, s.Body}, hoisted)
+			if err != nil {
+				return err
+			}
+		case *ast.SwitchStatement:
+			for _, clause := range s.Cases {
+				err := c.hoistVarDeclarations(clause.Consequent, hoisted)
+				if err != nil {
+					return err
+				}
+			}
+		case *ast.TryStatement:
+			for _, block := range []*ast.BlockStatement{s.Block, s.Handler, s.Finalizer} {
+				if block == nil {
+					continue
+				}
+				err := c.hoistVarDeclarations(block.Statements, hoisted)
+				if err != nil {
+					return err
+				}
+			}
+		case *ast.LabeledStatement:
+			err := c.hoistVarDeclarations([]ast.Statement{s.Body}, hoisted)
+			if err != nil {
+				return err
+			}
+		case *ast.ExpressionStatement:
+			if e, ok := s.Expression.(*ast.IfExpression); ok {
+				err := c.hoistVarDeclarations(e.Consequence.Statements, hoisted)
+				if err != nil {
+					return err
+				}
+				if e.Alternative != nil {
+					err := c.hoistVarDeclarations(e.Alternative.Statements, hoisted)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// declareLexical declares let and const names of statements in current table, uninitialized until compiled
+func (c *Compiler) declareLexical(statements []ast.Statement) error {
+	for _, s := range statements {
+		s, ok := s.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		kind := LetDeclaration
+		switch {
+		case s.Token.Is(token.Var):
+			continue
+		case s.Token.Is(token.Const):
+			kind = ConstDeclaration
+		}
+		err := c.symbolTable.DeclarePattern(s.Target(), kind)
+		if err != nil {
+			return err
+		}
+		for _, name := range ast.BoundNames(s.Target()) {
+			symbol, _ := c.symbolTable.Resolve(name.Value)
+			c.newCell(symbol)
+		}
+	}
+	return nil
+}
+
+// newParameterCells moves the arguments of parameters in cells to new cells before the defaults and patterns,
+// names bound by patterns get uninitialized cells
+func (c *Compiler) newParameterCells(node *ast.FunctionLiteral) {
+	numArguments := len(node.Parameters)
+	names := make([]*ast.IdentifierExpression, 0, numArguments)
+	for _, parameter := range node.Parameters {
+		names = append(names, ast.BoundNames(parameter.Target())...)
+	}
+	if node.Rest != nil {
+		numArguments++
+		names = append(names, node.Rest)
+	}
+	created := make(map[int]bool)
+	for _, name := range names {
+		symbol, _ := c.symbolTable.Resolve(name.Value)
+		if symbol.Scope != CellScope || created[symbol.Index] {
+			continue
+		}
+		created[symbol.Index] = true
+		if symbol.Index < numArguments {
+			c.emit(code.OpGetLocal, symbol.Index)
+			c.newCell(symbol)
+			c.setSymbol(symbol)
+		} else {
+			c.newCell(symbol)
+		}
+	}
+}
+
+// iterationCells returns the symbols in cells declared by let or const in the head of a for statement
+func (c *Compiler) iterationCells(init ast.Statement) []Symbol {
+	declaration, ok := init.(*ast.LetStatement)
+	if !ok || declaration.Token.Is(token.Var) {
+		return nil
+	}
+	var cells []Symbol
+	for _, name := range ast.BoundNames(declaration.Target()) {
+		if symbol, _ := c.symbolTable.Resolve(name.Value); symbol.Scope == CellScope {
+			cells = append(cells, symbol)
+		}
+	}
+	return cells
+}
+
+// copyCells replaces cells with new ones of the same values, the next iteration of a for statement
+// gets them and closures of the previous one keep the old ones
+func (c *Compiler) copyCells(cells []Symbol) {
+	for _, symbol := range cells {
+		c.loadSymbol(symbol)
+		c.newCell(symbol)
+		c.setSymbol(symbol)
+	}
+}
+
+// usesArguments reports whether function node or the arrow functions in it refer to its arguments object,
+// which a parameter or declaration named arguments shadows
+func usesArguments(node *ast.FunctionLiteral) bool {
+	used, declared := false, false
+	ast.Inspect(node.Body, func(n ast.JSNode) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			// other functions have their own arguments
+			return n.Arrow
+		case *ast.IdentifierExpression:
+			used = used || n.Value == "arguments"
+		case *ast.LetStatement:
+			for _, name := range ast.BoundNames(n.Target()) {
+				declared = declared || name.Value == "arguments"
+			}
+		}
+		return true
+	})
+	for _, parameter := range node.Parameters {
+		for _, name := range ast.BoundNames(parameter.Target()) {
+			declared = declared || name.Value == "arguments"
+		}
+	}
+	if node.Rest != nil && node.Rest.Value == "arguments" {
+		declared = true
+	}
+	return used && !declared
+}
+
+// cellNames returns the names in function or program node which closures in it capture and which are assigned,
+// or declared by let or const after a closure, which may be created before the declaration initializes the name.
+// They are stored in cells shared by the closures and the function. Other captured variables are copied.
+// Names are compared without scopes, so a name shadowing a name in a cell is in a cell too
+func cellNames(node ast.JSNode) map[string]bool {
+	// captured is the start of the first closure capturing a name, functions are not hoisted
+	captured := make(map[string]int)
+	// declared is the start of the last let or const declaration of a name in node, not in its closures
+	declared := make(map[string]int)
+	assigned := make(map[string]bool)
+	assign := func(target ast.Expression) {
+		for _, name := range ast.BoundNames(target) {
+			assigned[name.Value] = true
+		}
+	}
+	ast.Inspect(node, func(n ast.JSNode) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			if n == node {
+				break
+			}
+			start := n.Token.Start
+			ast.Inspect(n, func(n ast.JSNode) bool {
+				if identifier, ok := n.(*ast.IdentifierExpression); ok {
+					if first, ok := captured[identifier.Value]; !ok || first > start {
+						captured[identifier.Value] = start
+					}
+				}
+				return true
+			})
+		case *ast.AssignmentExpression:
+			assign(n.Left)
+		case *ast.UpdateExpression:
+			assign(n.Argument)
+		case *ast.LetStatement:
+			// a var declared again, or in a loop, is assigned by its initializer
+			if n.Token.Is(token.Var) && n.Value != nil {
+				assign(n.Target())
+			}
+		case *ast.ForInStatement:
+			assign(forInOfTarget(n.Left))
+		case *ast.ForOfStatement:
+			assign(forInOfTarget(n.Left))
+		}
+		return true
+	})
+	ast.Inspect(node, func(n ast.JSNode) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return n == node
+		case *ast.LetStatement:
+			if !n.Token.Is(token.Var) {
+				for _, name := range ast.BoundNames(n.Target()) {
+					declared[name.Value] = max(declared[name.Value], n.Token.Start)
+				}
+			}
+		}
+		return true
+	})
+	cells := make(map[string]bool)
+	for name, start := range captured {
+		if declaration, ok := declared[name]; assigned[name] || ok && declaration > start {
+			cells[name] = true
+		}
+	}
+	return cells
+}
+
+// forInOfTarget returns the target assigned by every iteration of a for-in or for-of loop, nil for let and const
+func forInOfTarget(left ast.Statement) ast.Expression {
+	switch left := left.(type) {
+	case *ast.LetStatement:
+		if left.Token.Is(token.Var) {
+			return left.Target()
+		}
+	case *ast.ExpressionStatement:
+		return left.Expression
+	}
+	return nil
+}
+
+// MARK: Utils
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.currentScope().lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.currentScope().lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+	return posNewInstruction
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.currentScope().lastInstruction.Opcode == op
+}
+
+// keepBlockValue leaves the value of the last expression statement of a block on the stack,
+// null if the block doesn't end with one
+func (c *Compiler) keepBlockValue(statements []ast.Statement) {
+	if c.lastInstructionIs(code.OpPop) && endsWithExpression(statements) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
+// endsWithExpression reports whether the last OpPop of statements pops the value of the last one,
+// the last OpPop of other statements may be a jump target, e.g. of a break in a switch
+func endsWithExpression(statements []ast.Statement) bool {
+	if len(statements) == 0 {
+		return false
+	}
+	_, ok := statements[len(statements)-1].(*ast.ExpressionStatement)
+	return ok
 }
 
 func (c *Compiler) removeLastPop() {
@@ -376,6 +1909,63 @@ func (c *Compiler) removeLastPop() {
 	c.scopes[c.scopeIndex].lastInstruction = previous
 }
 
+// templateStrings builds the frozen strings array passed to a template tag,
+// its raw property is the frozen array of raw strings
+func templateStrings(quasi *ast.TemplateLiteral) *object.ArrayObject {
+	cooked := &object.ArrayObject{Frozen: true}
+	raw := &object.ArrayObject{Frozen: true}
+	for _, q := range quasi.Quasis {
+		cooked.Elements = append(cooked.Elements, &object.StringObject{Value: q.Value})
+		raw.Elements = append(raw.Elements, &object.StringObject{Value: q.Raw})
+	}
+	rawKey := &object.StringObject{Value: "raw"}
+	cooked.Properties = map[object.HashKey]object.HashPair{
+		rawKey.HashKey(): {Key: rawKey, Value: raw},
+	}
+	return cooked
+}
+
+// assignSymbol stores the value on top of stack to the variable name
+func (c *Compiler) assignSymbol(name string) (Symbol, error) {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		return symbol, fmt.Errorf("symbol not found: %s", name)
+	}
+	binding := c.symbolTable.ResolveBinding(name)
+	if binding != nil && binding.Kind == ConstDeclaration {
+		return symbol, fmt.Errorf("TypeError: assignment to constant variable '%s'", name)
+	}
+	if binding != nil && !binding.Initialized && c.symbolTable.InFunction(binding) {
+		c.emit(code.OpReferenceError, c.addConstant(&object.StringObject{Value: name}))
+		return symbol, nil
+	}
+	switch symbol.Scope {
+	case BuiltinScope, FreeScope, FunctionScope:
+		return symbol, fmt.Errorf("assignment to %s symbol %s is not supported", symbol.Scope, name)
+	}
+	if binding != nil && (!binding.Initialized || binding.MaybeUninitialized) {
+		// a function declared before the initialization may assign after it
+		c.loadSymbol(symbol)
+		c.emit(code.OpCheckInitialized, c.addConstant(&object.StringObject{Value: name}))
+		c.emit(code.OpPop)
+	}
+	c.setSymbol(symbol)
+	return symbol, nil
+}
+
+func (c *Compiler) setSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case CellScope:
+		c.emit(code.OpSetCell, s.Index)
+	case FreeCellScope:
+		c.emit(code.OpSetFreeCell, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
 func (c *Compiler) loadSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
@@ -388,5 +1978,28 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpGetFree, s.Index)
 	case FunctionScope:
 		c.emit(code.OpCurrentClosure)
+	case CellScope:
+		c.emit(code.OpGetCell, s.Index)
+	case FreeCellScope:
+		c.emit(code.OpGetFreeCell, s.Index)
+	}
+}
+
+// loadCapture pushes the value of a free symbol of a closure being created, the cell of a symbol in a cell
+func (c *Compiler) loadCapture(s Symbol) {
+	switch s.Scope {
+	case CellScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeCellScope:
+		c.emit(code.OpGetFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+// newCell puts a new uninitialized cell in the local of a symbol in a cell, when entering its scope
+func (c *Compiler) newCell(s Symbol) {
+	if s.Scope == CellScope {
+		c.emit(code.OpNewCell, s.Index)
 	}
 }
@@ -153,6 +153,24 @@ func TestLetStatementScopes(t *testing.T) {
 				code.Make(code.OpSetGlobal, 0),
 			},
 		},
+		{
+			// a block declares x again, it shadows the global x until the block ends
+			input: `
+	let x = 1;
+	{ let x = 2; x }
+	x`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 	runCompilerTests(t, tests)
 }
@@ -203,6 +221,53 @@ func TestFunctionCalls(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input: `
+	let f = function(a, ...rest) { rest };
+	f(1, ...f);
+`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpAppendSpread),
+				code.Make(code.OpCallSpread),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let f = function(a, b = a) { b }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 1),
+					// 0002
+					code.Make(code.OpJumpNotUndefined, 9),
+					// 0005
+					code.Make(code.OpGetLocal, 0),
+					// 0007
+					code.Make(code.OpSetLocal, 1),
+					// 0009
+					code.Make(code.OpGetLocal, 1),
+					// 0011
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
 	}
 	runCompilerTests(t, tests)
 }
@@ -284,6 +349,27 @@ func TestFunctions(t *testing.T) {
 				code.Make(code.OpSetGlobal, 0),
 			},
 		},
+		{
+			// arguments is a local after the parameters, arrow functions capture it
+			input: "let a = function(x) { return () => arguments }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpArguments),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
 	}
 	runCompilerTests(t, tests)
 }
@@ -306,72 +392,941 @@ func TestIndex(t *testing.T) {
 			},
 		},
 		{
-			input:             `{a: 2}["a"]`,
-			expectedConstants: []interface{}{"a", 2, "a"},
+			input:             `({a: 2})["a"]`,
+			expectedConstants: []interface{}{"a", 2, "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpObject, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let a = 1; a.b",
+			expectedConstants: []interface{}{1, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpGetProperty, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; a.b(2)",
+			expectedConstants: []interface{}{1, "b", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpGetProperty, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCallMethod, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let a = 1; a["b"](...a)`,
+			expectedConstants: []interface{}{1, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpIndex),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpAppendSpread),
+				code.Make(code.OpCallMethodSpread),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; a?.b.c",
+			expectedConstants: []interface{}{1, "b", "c"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpDup),
+				// 0010
+				code.Make(code.OpJumpNullish, 22),
+				// 0013
+				code.Make(code.OpGetProperty, 1),
+				// 0016
+				code.Make(code.OpGetProperty, 2),
+				// 0019
+				code.Make(code.OpJump, 24),
+				// 0022
+				code.Make(code.OpPop),
+				// 0023
+				code.Make(code.OpUndefined),
+				// 0024
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; a.b?.()",
+			expectedConstants: []interface{}{1, "b"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpDup),
+				// 0010
+				code.Make(code.OpGetProperty, 1),
+				// 0013
+				code.Make(code.OpDup),
+				// 0014
+				code.Make(code.OpJumpNullish, 22),
+				// 0017
+				code.Make(code.OpCallMethod, 0),
+				// 0019
+				code.Make(code.OpJump, 25),
+				// 0022
+				code.Make(code.OpPop),
+				// 0023
+				code.Make(code.OpPop),
+				// 0024
+				code.Make(code.OpUndefined),
+				// 0025
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "this",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpThis),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestObjectLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "({})",
+			expectedConstants: make([]interface{}, 0),
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpObject, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `({"a": 1, "b": 2, "c": 3 + 4})`,
+			expectedConstants: []interface{}{"a", 1, "b", 2, "c", 3, 4},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpConstant, 6),
+				code.Make(code.OpAdd),
+				code.Make(code.OpObject, 6),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let b = 1; ({if: 1, 2: 3, [b]: 4, b})`,
+			expectedConstants: []interface{}{1, "if", 1, 2, 3, 4, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpConstant, 6),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpObject, 8),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[]",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1 + 2, 3- 4]",
+			expectedConstants: []interface{}{1, 2, 3, 4},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSub),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = []; [1, ...a, 2]",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpAppendSpread),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAppend),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"a"`,
+			expectedConstants: []interface{}{"a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"a" + "b"`,
+			expectedConstants: []interface{}{"a", "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "`a${1}`",
+			expectedConstants: []interface{}{"a", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "len`a${1}b`",
+			expectedConstants: []interface{}{[]string{"a", "b"}, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; a.b`c`",
+			expectedConstants: []interface{}{1, "b", []string{"c"}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpGetProperty, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCallMethod, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestRegExpLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "/a+/i",
+			expectedConstants: []interface{}{&object.RegExpObject{Pattern: "a+", Flags: "i"}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpRegExp, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+
+	err := New().Compile(parse("/(?<=a)b/"))
+	assert.Error(t, err)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+	let one = 1;
+	let two = 2;`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			input: `
+	let one = 1;
+	let two = one;
+	two;`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestVarConstDeclarations(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// var is hoisted and undefined from the start
+			input: `
+	a;
+	var a = 1;`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpUndefined),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input: `
+	let a = 1;
+	if (true) { const a = 2; a; }
+	a;`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpFalse, 20),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpJump, 21),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+	let f = function() {
+		if (true) { var a = 1; }
+		let b;
+		a;
+	}`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpUndefined),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpTrue),
+					code.Make(code.OpJumpFalse, 16),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpNull),
+					code.Make(code.OpJump, 17),
+					code.Make(code.OpNull),
+					code.Make(code.OpPop),
+					code.Make(code.OpUndefined),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTemporalDeadZone(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+	a;
+	let a = 1;`,
+			expectedConstants: []interface{}{"a", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpReferenceError, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			// f may be called after a is initialized
+			input: `
+	let f = function() { a };
+	let a = 1;`,
+			expectedConstants: []interface{}{
+				"a",
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpCheckInitialized, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			input: `
+	let x = 1;
+	{ x; let x = 2 }`,
+			expectedConstants: []interface{}{1, "x", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReferenceError, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetLocal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+	let a = 1;
+	a = 2;`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+	let a = 1;
+	a **= 2;`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpExp),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+	let a = 1;
+	a ||= 2;`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpDup),
+				// 0010
+				code.Make(code.OpJumpTrue, 23),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpSetGlobal, 0),
+				// 0020
+				code.Make(code.OpGetGlobal, 0),
+				// 0023
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let f = function(a) { a++; return --a }",
+			expectedConstants: []interface{}{
+				1,
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpToNumeric),
+					code.Make(code.OpDup),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpToNumeric),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSub),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCapturedAssignment(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+	let f = function() {
+		let a = 1;
+		let g = function() { a = 2 };
+		return a
+	}`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetFreeCell, 0),
+					code.Make(code.OpGetFreeCell, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpNewCell, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetCell, 0),
+					// the closure captures the cell
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetCell, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input: "let f = function(a, b) { return function() { return a + b++ } }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetFreeCell, 1),
+					code.Make(code.OpToNumeric),
+					code.Make(code.OpDup),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetFreeCell, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					// the argument moves to a new cell
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpNewCell, 1),
+					code.Make(code.OpSetCell, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpClosure, 1, 2),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = []; let [a, , b = 1] = x",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpArray, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpGetIterator),
+				// 0010
+				code.Make(code.OpIteratorValue),
+				// 0011
+				code.Make(code.OpSetGlobal, 1),
+				// 0014
+				code.Make(code.OpIteratorValue),
+				// 0015
+				code.Make(code.OpPop),
+				// 0016
+				code.Make(code.OpIteratorValue),
+				// 0017
+				code.Make(code.OpDup),
+				// 0018
+				code.Make(code.OpJumpNotUndefined, 25),
+				// 0021
+				code.Make(code.OpPop),
+				// 0022
+				code.Make(code.OpConstant, 0),
+				// 0025
+				code.Make(code.OpSetGlobal, 2),
+				// 0028
+				code.Make(code.OpIteratorClose),
+			},
+		},
+		{
+			input:             "let x = []; let {a, ...r} = x",
+			expectedConstants: []interface{}{"a", "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpGetProperty, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpObjectRest, 1),
+				code.Make(code.OpSetGlobal, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let x = []; let {} = x",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpCheckObjectCoercible),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; [a] = []",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpGetIterator),
+				code.Make(code.OpIteratorValue),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpIteratorClose),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestDeclarationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const a = 1; a = 2;", "TypeError: assignment to constant variable 'a'"},
+		{"const a = 1; let f = function() { a = 2 }", "TypeError: assignment to constant variable 'a'"},
+		{"const a = 1; a += 2;", "TypeError: assignment to constant variable 'a'"},
+		{"let f = function() { const a = 1; return function() { a++ } }", "TypeError: assignment to constant variable 'a'"},
+		{"let a = 1; let a = 2;", "SyntaxError: identifier 'a' has already been declared"},
+		{"let a = 1; var a = 2;", "SyntaxError: identifier 'a' has already been declared"},
+		{"let f = function(a) { let a = 1 }", "SyntaxError: identifier 'a' has already been declared"},
+		{"if (true) { let a = 1; } a;", "symbol not found: a"},
+		{"let [a, {b: a}] = c", "SyntaxError: identifier 'a' has already been declared"},
+		{"let f = function({a}) { let a = 1 }", "SyntaxError: identifier 'a' has already been declared"},
+		{"let a = {}; let {[a]: b, ...c} = a", "computed keys with a rest element are not supported"},
+		{"let b = {}; let a = {...b}", "spread properties in object literals are not supported"},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `while (true) { break; continue; }`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpFalse, 13),
+				// 0004
+				code.Make(code.OpJump, 13),
+				// 0007
+				code.Make(code.OpJump, 0),
+				// 0010
+				code.Make(code.OpJump, 0),
+			},
+		},
+		{
+			input:             `for (let i = 0; i < 1; i = 1) { continue }`,
+			expectedConstants: []interface{}{0, 1, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetLocal, 0),
+				// 0005
+				code.Make(code.OpGetLocal, 0),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpLessThan),
+				// 0011
+				code.Make(code.OpJumpFalse, 28),
+				// 0014
+				code.Make(code.OpJump, 17),
+				// 0017
+				code.Make(code.OpConstant, 2),
+				// 0020
+				code.Make(code.OpSetLocal, 0),
+				// 0022
+				code.Make(code.OpGetLocal, 0),
+				// 0024
+				code.Make(code.OpPop),
+				// 0025
+				code.Make(code.OpJump, 5),
+			},
+		},
+		{
+			input:             `do { 1 } while (false)`,
+			expectedConstants: []interface{}{1},
 			expectedInstructions: []code.Instructions{
+				// 0000
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpObject, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpIndex),
+				// 0003
 				code.Make(code.OpPop),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpFalse, 11),
+				// 0008
+				code.Make(code.OpJump, 0),
 			},
 		},
 	}
+
 	runCompilerTests(t, tests)
 }
 
-func TestObjectLiterals(t *testing.T) {
+func TestForInOf(t *testing.T) {
 	tests := []compilerTestCase{
 		{
-			input:             "{}",
-			expectedConstants: make([]interface{}, 0),
+			input:             `for (const x of [1]) { break }`,
+			expectedConstants: []interface{}{1},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpObject, 0),
-				code.Make(code.OpPop),
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpGetIterator),
+				// 0007
+				code.Make(code.OpIteratorNext, 19),
+				// 0010
+				code.Make(code.OpSetLocal, 0),
+				// 0012
+				code.Make(code.OpJump, 18),
+				// 0015
+				code.Make(code.OpJump, 7),
+				// 0018
+				code.Make(code.OpIteratorClose),
 			},
 		},
 		{
-			input:             `{"a": 1, "b": 2, "c": 3 + 4}`,
-			expectedConstants: []interface{}{"a", 1, "b", 2, "c", 3, 4},
+			input:             `let k; for (k in {}) {}`,
+			expectedConstants: []interface{}{},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpConstant, 6),
-				code.Make(code.OpAdd),
-				code.Make(code.OpObject, 6),
-				code.Make(code.OpPop),
+				// 0000
+				code.Make(code.OpUndefined),
+				// 0001
+				code.Make(code.OpSetGlobal, 0),
+				// 0004
+				code.Make(code.OpObject, 0),
+				// 0007
+				code.Make(code.OpEnumerate),
+				// 0008
+				code.Make(code.OpIteratorNext, 17),
+				// 0011
+				code.Make(code.OpSetGlobal, 0),
+				// 0014
+				code.Make(code.OpJump, 8),
 			},
 		},
 	}
+
 	runCompilerTests(t, tests)
 }
 
-func TestArrayLiterals(t *testing.T) {
+func TestSwitch(t *testing.T) {
 	tests := []compilerTestCase{
 		{
-			input:             "[]",
-			expectedConstants: []interface{}{},
+			input: `switch (1) { case 1: break; default: case "a": 2 }`,
+			expectedConstants: []interface{}{1, &object.JumpTable{
+				Targets: map[object.HashKey]int{
+					(&object.Integer{Value: 1}).HashKey():        6,
+					(&object.StringObject{Value: "a"}).HashKey(): 9,
+				},
+				Default: 9,
+			}, 2},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpArray, 0),
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpJumpTable, 1),
+				// 0006
+				code.Make(code.OpJump, 13),
+				// 0009
+				code.Make(code.OpConstant, 2),
+				// 0012
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input:             "[1 + 2, 3- 4]",
-			expectedConstants: []interface{}{1, 2, 3, 4},
+			input:             `switch (1) { case true: default: 3 }`,
+			expectedConstants: []interface{}{1, 3},
 			expectedInstructions: []code.Instructions{
+				// 0000
 				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpTrue),
+				// 0004
+				code.Make(code.OpCase, 11),
+				// 0007
+				code.Make(code.OpPop),
+				// 0008
+				code.Make(code.OpJump, 11),
+				// 0011
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpAdd),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// a is maybe uninitialized in later clauses
+			input: `switch (1) { case 1: let [a] = [5]; default: a }`,
+			expectedConstants: []interface{}{1, &object.JumpTable{
+				Targets: map[object.HashKey]int{
+					(&object.Integer{Value: 1}).HashKey(): 6,
+				},
+				Default: 17,
+			}, 5, "a"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpJumpTable, 1),
+				// 0006
 				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
-				code.Make(code.OpArray, 2),
+				// 0009
+				code.Make(code.OpArray, 1),
+				// 0012
+				code.Make(code.OpGetIterator),
+				// 0013
+				code.Make(code.OpIteratorValue),
+				// 0014
+				code.Make(code.OpSetLocal, 0),
+				// 0016
+				code.Make(code.OpIteratorClose),
+				// 0017
+				code.Make(code.OpGetLocal, 0),
+				// 0019
+				code.Make(code.OpCheckInitialized, 3),
+				// 0022
 				code.Make(code.OpPop),
 			},
 		},
@@ -380,64 +1335,113 @@ func TestArrayLiterals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
-func TestStringExpressions(t *testing.T) {
+func TestTryCatch(t *testing.T) {
 	tests := []compilerTestCase{
 		{
-			input:             `"a"`,
-			expectedConstants: []interface{}{"a"},
+			input:             `try { 1 } catch (e) { e }`,
+			expectedConstants: []interface{}{1},
 			expectedInstructions: []code.Instructions{
+				// 0000
 				code.Make(code.OpConstant, 0),
+				// 0003
 				code.Make(code.OpPop),
-			},
-		},
-		{
-			input:             `"a" + "b"`,
-			expectedConstants: []interface{}{"a", "b"},
-			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpAdd),
+				// 0004
+				code.Make(code.OpJump, 12),
+				// 0007
+				code.Make(code.OpSetLocal, 0),
+				// 0009
+				code.Make(code.OpGetLocal, 0),
+				// 0011
 				code.Make(code.OpPop),
 			},
+			expectedHandlers: []object.Handler{{Start: 0, End: 4, Target: 7}},
 		},
-	}
-	runCompilerTests(t, tests)
-}
-
-func TestGlobalLetStatements(t *testing.T) {
-	tests := []compilerTestCase{
 		{
-			input: `
-	let one = 1;
-	let two = 2;`,
-			expectedConstants: []interface{}{1, 2},
+			input:             `try { throw 1 } finally { 2 }`,
+			expectedConstants: []interface{}{1, 2, 2},
 			expectedInstructions: []code.Instructions{
+				// 0000
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpSetGlobal, 0),
+				// 0003
+				code.Make(code.OpThrow),
+				// 0004
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpSetGlobal, 1),
+				// 0007
+				code.Make(code.OpPop),
+				// 0008
+				code.Make(code.OpJump, 20),
+				// 0011
+				code.Make(code.OpSetLocal, 0),
+				// 0013
+				code.Make(code.OpConstant, 2),
+				// 0016
+				code.Make(code.OpPop),
+				// 0017
+				code.Make(code.OpGetLocal, 0),
+				// 0019
+				code.Make(code.OpThrow),
 			},
+			expectedHandlers: []object.Handler{{Start: 0, End: 4, Target: 11}},
 		},
 		{
-			input: `
-	let one = 1;
-	let two = one;
-	two;`,
+			// the finally block run by break is not covered by the handler
+			input:             `for (const x of []) { try { break; 1 } finally {} }`,
 			expectedConstants: []interface{}{1},
 			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpArray, 0),
+				// 0003
+				code.Make(code.OpGetIterator),
+				// 0004
+				code.Make(code.OpIteratorNext, 28),
+				// 0007
+				code.Make(code.OpSetLocal, 0),
+				// 0009
+				code.Make(code.OpJump, 27),
+				// 0012
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpSetGlobal, 0),
-				code.Make(code.OpGetGlobal, 0),
-				code.Make(code.OpSetGlobal, 1),
-				code.Make(code.OpGetGlobal, 1),
+				// 0015
 				code.Make(code.OpPop),
+				// 0016
+				code.Make(code.OpJump, 24),
+				// 0019
+				code.Make(code.OpSetLocal, 1),
+				// 0021
+				code.Make(code.OpGetLocal, 1),
+				// 0023
+				code.Make(code.OpThrow),
+				// 0024
+				code.Make(code.OpJump, 4),
+				// 0027
+				code.Make(code.OpIteratorClose),
 			},
+			expectedHandlers: []object.Handler{{Start: 12, End: 16, Target: 19, StackDepth: 1}},
 		},
 	}
 
 	runCompilerTests(t, tests)
 }
 
+func TestJumpErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break", "SyntaxError: illegal break statement"},
+		{"a: { continue }", "SyntaxError: illegal continue statement"},
+		{"while (true) { let f = function() { break } }", "SyntaxError: illegal break statement"},
+		{"while (true) { break a }", "SyntaxError: undefined label 'a'"},
+		{"a: { while (true) { continue a } }", "SyntaxError: illegal continue statement: 'a' does not denote an iteration statement"},
+		{"a: while (true) { a: while (true) {} }", "SyntaxError: label 'a' has already been declared"},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -514,6 +1518,8 @@ type compilerTestCase struct {
 	input                string
 	expectedConstants    []interface{}
 	expectedInstructions []code.Instructions
+	// expectedHandlers of the main frame are checked if not nil
+	expectedHandlers []object.Handler
 }
 
 func TestIntegerPrefix(t *testing.T) {
@@ -531,6 +1537,22 @@ func TestIntegerPrefix(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestNumericLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1.5 + 2.0",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
 func TestIntegerArithmetic(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -587,6 +1609,96 @@ func TestIntegerArithmetic(t *testing.T) {
 	runCompilerTests(t, tests[len(tests)-1:])
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "null ?? undefined",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpDup),
+				// 0002
+				code.Make(code.OpJumpNotNullish, 7),
+				// 0005
+				code.Make(code.OpPop),
+				// 0006
+				code.Make(code.OpUndefined),
+				// 0007
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; a && 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpDup),
+				// 0010
+				code.Make(code.OpJumpFalse, 17),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; a || 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpDup),
+				// 0010
+				code.Make(code.OpJumpTrue, 17),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let a = 1; a ?? 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpDup),
+				// 0010
+				code.Make(code.OpJumpNotNullish, 17),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -690,6 +1802,10 @@ func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 
 		err = testConstants(t, tt.expectedConstants, bytecode.Constants)
 		assert.NoError(t, err, "constants error")
+
+		if tt.expectedHandlers != nil {
+			assert.Equal(t, tt.expectedHandlers, bytecode.Handlers, tt.input)
+		}
 	}
 }
 
@@ -728,9 +1844,29 @@ func testConstants(t *testing.T, expectedConstants []interface{}, constants []ob
 		case int:
 			err := testIntegerObject(int64(constant), constants[i])
 			assert.NoError(t, err)
+		case float64:
+			err := testNumberObject(constant, constants[i])
+			assert.NoError(t, err)
 		case string:
 			err := testStringObject(constant, constants[i])
 			assert.NoError(t, err)
+		case *object.RegExpObject:
+			re, ok := constants[i].(*object.RegExpObject)
+			assert.True(t, ok)
+			assert.Equal(t, constant.Pattern, re.Pattern)
+			assert.Equal(t, constant.Flags, re.Flags)
+		case []string:
+			array, ok := constants[i].(*object.ArrayObject)
+			assert.True(t, ok)
+			assert.Equal(t, len(constant), len(array.Elements))
+			for j, s := range constant {
+				assert.NoError(t, testStringObject(s, array.Elements[j]))
+			}
+		case *object.JumpTable:
+			table, ok := constants[i].(*object.JumpTable)
+			assert.True(t, ok)
+			assert.Equal(t, constant.Targets, table.Targets)
+			assert.Equal(t, constant.Default, table.Default)
 		case []code.Instructions:
 			fn, ok := constants[i].(*object.CompiledFunction)
 			assert.True(t, ok)
@@ -756,6 +1892,17 @@ func testStringObject(expected string, actual object.Object) error {
 	return nil
 }
 
+func testNumberObject(expected float64, o object.Object) error {
+	result, ok := o.(*object.NumberObject)
+	if !ok {
+		return fmt.Errorf("expected number, got %s", o)
+	}
+	if result.Value != expected {
+		return fmt.Errorf("expected %g, got %g", expected, result.Value)
+	}
+	return nil
+}
+
 func testIntegerObject(expected int64, o object.Object) error {
 	result, ok := o.(*object.Integer)
 	if !ok {
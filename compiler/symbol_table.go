@@ -1,5 +1,11 @@
 package compiler
 
+import (
+	"fmt"
+
+	"github.com/Seeingu/coldmoon/ast"
+)
+
 //go:generate stringer -type SymbolScope -trimprefix symboleScope
 type SymbolScope int
 
@@ -9,6 +15,10 @@ const (
 	BuiltinScope
 	FreeScope
 	FunctionScope
+	// CellScope is a local in a cell, closures capture it and it is assigned
+	CellScope
+	// FreeCellScope is a free symbol of a CellScope symbol, the closure has the cell
+	FreeCellScope
 )
 
 type Symbol struct {
@@ -17,17 +27,54 @@ type Symbol struct {
 	Index int
 }
 
+// DeclarationKind is how a name is declared
+type DeclarationKind int
+
+const (
+	// VarDeclaration is function scoped and can be declared again, parameters are declared like var
+	VarDeclaration DeclarationKind = iota
+	// LetDeclaration is block scoped and can't be used before initialization
+	LetDeclaration
+	// ConstDeclaration is a LetDeclaration which can't be assigned
+	ConstDeclaration
+)
+
+// Binding is the declaration of a symbol in its table
+type Binding struct {
+	Kind DeclarationKind
+	// Initialized is set after the declaration is compiled,
+	// a let or const symbol used before is in the temporal dead zone
+	Initialized bool
+	// MaybeUninitialized is set in switch clauses after the declaring one, which a jump may enter
+	// without running the declaration, so uses check the binding at runtime
+	MaybeUninitialized bool
+	// table declaring the binding
+	table *SymbolTable
+}
+
+// SymbolTable is the scope of a function, or a block in a function,
+// symbols of a block table are numbered with the symbols of its function
 type SymbolTable struct {
 	Outer          *SymbolTable
 	store          map[string]Symbol
+	bindings       map[string]*Binding
 	numDefinitions int
-	FreeSymbols    []Symbol
+	// numBlockDefinitions counts symbols of blocks in the program, they are locals of the main frame
+	numBlockDefinitions int
+	FreeSymbols         []Symbol
+	// function is the table of the function containing a block, itself for a function table
+	function *SymbolTable
+	// cells are the names of the function stored in cells, see cellNames
+	cells map[string]bool
 }
 
 func NewSymbolTable() *SymbolTable {
-	return &SymbolTable{
-		store: make(map[string]Symbol),
+	st := &SymbolTable{
+		store:    make(map[string]Symbol),
+		bindings: make(map[string]*Binding),
 	}
+	st.function = st
+	return st
 }
 
 func NewEnclosingSymbolTable(outer *SymbolTable) *SymbolTable {
@@ -36,21 +83,74 @@ func NewEnclosingSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// NewBlockSymbolTable returns the table of a block in the function of outer
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	s.function = outer.function
+	return s
+}
+
 func (st *SymbolTable) Define(name string) Symbol {
-	symbol := Symbol{
-		Name:  name,
-		Index: st.numDefinitions,
-	}
-	if st.Outer == nil {
+	fn := st.function
+	symbol := Symbol{Name: name}
+	switch {
+	case fn.Outer != nil:
+		symbol.Scope = LocalScope
+		symbol.Index = fn.numDefinitions
+		fn.numDefinitions++
+	case st == fn:
 		symbol.Scope = GlobalScope
-	} else {
+		symbol.Index = fn.numDefinitions
+		fn.numDefinitions++
+	default:
+		// not global, so closures in a loop body capture the value of their iteration
 		symbol.Scope = LocalScope
+		symbol.Index = fn.numBlockDefinitions
+		fn.numBlockDefinitions++
+	}
+	if symbol.Scope == LocalScope && fn.cells[name] {
+		symbol.Scope = CellScope
 	}
 	st.store[name] = symbol
-	st.numDefinitions++
 	return symbol
 }
 
+// Declare defines name by kind, var is defined in the function table and let or const in st.
+// Declaring a name again is an error unless both are var
+func (st *SymbolTable) Declare(name string, kind DeclarationKind) (Symbol, error) {
+	table := st
+	if kind == VarDeclaration {
+		table = st.function
+		// a var is in scope of every block up to its function
+		for block := st; block != table; block = block.Outer {
+			if _, ok := block.bindings[name]; ok {
+				return Symbol{}, redeclarationError(name)
+			}
+		}
+	}
+	if b, ok := table.bindings[name]; ok {
+		if kind != VarDeclaration || b.Kind != VarDeclaration {
+			return Symbol{}, redeclarationError(name)
+		}
+		return table.store[name], nil
+	}
+	symbol := table.Define(name)
+	table.bindings[name] = &Binding{Kind: kind, Initialized: kind == VarDeclaration, table: table}
+	return symbol, nil
+}
+
+// DeclarePattern declares the names bound by an identifier or a destructuring pattern
+func (st *SymbolTable) DeclarePattern(target ast.Expression, kind DeclarationKind) error {
+	for _, name := range ast.BoundNames(target) {
+		_, err := st.Declare(name.Value, kind)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := st.store[name]
 	if !ok && st.Outer != nil {
@@ -58,7 +158,7 @@ func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 		if !ok {
 			return obj, ok
 		}
-		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope || st.function != st {
 			return obj, ok
 		}
 		free := st.defineFree(obj)
@@ -67,6 +167,24 @@ func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 	return obj, ok
 }
 
+// ResolveBinding returns the declaration name resolves to,
+// nil for builtins, function names and undeclared names
+func (st *SymbolTable) ResolveBinding(name string) *Binding {
+	for table := st; table != nil; table = table.Outer {
+		symbol, ok := table.store[name]
+		if !ok || symbol.Scope == FreeScope || symbol.Scope == FreeCellScope {
+			continue
+		}
+		return table.bindings[name]
+	}
+	return nil
+}
+
+// InFunction reports whether b is declared in the function of st, not in an enclosing function
+func (st *SymbolTable) InFunction(b *Binding) bool {
+	return b.table.function == st.function
+}
+
 func (st *SymbolTable) DefineBuiltin(i int, name string) Symbol {
 	symbol := Symbol{Name: name, Index: i, Scope: BuiltinScope}
 	st.store[name] = symbol
@@ -86,7 +204,14 @@ func (st *SymbolTable) defineFree(original Symbol) Symbol {
 
 	symbol := Symbol{Name: original.Name, Index: len(st.FreeSymbols) - 1}
 	symbol.Scope = FreeScope
+	if original.Scope == CellScope || original.Scope == FreeCellScope {
+		symbol.Scope = FreeCellScope
+	}
 
 	st.store[original.Name] = symbol
 	return symbol
 }
+
+func redeclarationError(name string) error {
+	return fmt.Errorf("SyntaxError: identifier '%s' has already been declared", name)
+}
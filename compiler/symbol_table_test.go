@@ -151,3 +151,118 @@ func TestResolve(t *testing.T) {
 		assert.Equal(t, symbol, result)
 	}
 }
+
+func TestBlockScopes(t *testing.T) {
+	global := NewSymbolTable()
+	_, err := global.Declare("a", LetDeclaration)
+	assert.NoError(t, err)
+
+	block := NewBlockSymbolTable(global)
+	_, err = block.Declare("a", ConstDeclaration)
+	assert.NoError(t, err, "a block may shadow an outer let")
+	b, err := block.Declare("b", VarDeclaration)
+	assert.NoError(t, err)
+	assert.Equal(t, Symbol{"b", GlobalScope, 1}, b, "var is declared in the function table")
+
+	local := NewEnclosingSymbolTable(block)
+	_, err = local.Declare("c", VarDeclaration)
+	assert.NoError(t, err)
+	localBlock := NewBlockSymbolTable(local)
+	d, err := localBlock.Declare("d", LetDeclaration)
+	assert.NoError(t, err)
+	assert.Equal(t, Symbol{"d", LocalScope, 1}, d, "block symbols are numbered with the function symbols")
+
+	expected := []Symbol{
+		{"a", FreeScope, 0},
+		{"b", GlobalScope, 1},
+		{"c", LocalScope, 0},
+		{"d", LocalScope, 1},
+	}
+	for _, s := range expected {
+		result, ok := localBlock.Resolve(s.Name)
+		assert.True(t, ok)
+		assert.Equal(t, s, result)
+	}
+	assert.Equal(t, []Symbol{{"a", LocalScope, 0}}, local.FreeSymbols, "blocks of the program have locals")
+
+	inner := NewEnclosingSymbolTable(localBlock)
+	free, ok := inner.Resolve("d")
+	assert.True(t, ok)
+	assert.Equal(t, Symbol{"d", FreeScope, 0}, free)
+	assert.Equal(t, []Symbol{d}, inner.FreeSymbols)
+
+	_, ok = global.Resolve("d")
+	assert.False(t, ok)
+
+	assert.Equal(t, ConstDeclaration, localBlock.ResolveBinding("a").Kind)
+	assert.False(t, localBlock.InFunction(localBlock.ResolveBinding("a")))
+	assert.True(t, localBlock.InFunction(localBlock.ResolveBinding("c")))
+}
+
+func TestRedeclaration(t *testing.T) {
+	tests := []struct {
+		first  DeclarationKind
+		second DeclarationKind
+		ok     bool
+	}{
+		{VarDeclaration, VarDeclaration, true},
+		{VarDeclaration, LetDeclaration, false},
+		{LetDeclaration, VarDeclaration, false},
+		{LetDeclaration, LetDeclaration, false},
+		{ConstDeclaration, LetDeclaration, false},
+	}
+	for _, tt := range tests {
+		global := NewSymbolTable()
+		first, err := global.Declare("a", tt.first)
+		assert.NoError(t, err)
+		second, err := global.Declare("a", tt.second)
+		if tt.ok {
+			assert.NoError(t, err)
+			assert.Equal(t, first, second)
+		} else {
+			assert.EqualError(t, err, "SyntaxError: identifier 'a' has already been declared")
+		}
+	}
+
+	global := NewSymbolTable()
+	block := NewBlockSymbolTable(global)
+	_, err := block.Declare("a", LetDeclaration)
+	assert.NoError(t, err)
+	_, err = NewBlockSymbolTable(block).Declare("a", VarDeclaration)
+	assert.Error(t, err, "var is in scope of the block declaring let")
+}
+
+func TestResolveCell(t *testing.T) {
+	global := NewSymbolTable()
+	global.cells = map[string]bool{"a": true}
+	global.Define("a")
+
+	local := NewEnclosingSymbolTable(global)
+	local.cells = map[string]bool{"b": true}
+	local.Define("b")
+	local.Define("c")
+	block := NewBlockSymbolTable(local)
+	block.Define("b")
+
+	inner := NewEnclosingSymbolTable(block)
+	innermost := NewEnclosingSymbolTable(inner)
+
+	expected := []struct {
+		table  *SymbolTable
+		symbol Symbol
+	}{
+		{global, Symbol{"a", GlobalScope, 0}},
+		{local, Symbol{"b", CellScope, 0}},
+		{local, Symbol{"c", LocalScope, 1}},
+		{block, Symbol{"b", CellScope, 2}},
+		{inner, Symbol{"b", FreeCellScope, 0}},
+		{innermost, Symbol{"b", FreeCellScope, 0}},
+	}
+	for _, tt := range expected {
+		result, ok := tt.table.Resolve(tt.symbol.Name)
+		assert.True(t, ok)
+		assert.Equal(t, tt.symbol, result)
+	}
+	assert.Equal(t, []Symbol{{"b", CellScope, 2}}, inner.FreeSymbols)
+	assert.Equal(t, []Symbol{{"b", FreeCellScope, 0}}, innermost.FreeSymbols)
+}
@@ -13,11 +13,13 @@ func _() {
 	_ = x[BuiltinScope-2]
 	_ = x[FreeScope-3]
 	_ = x[FunctionScope-4]
+	_ = x[CellScope-5]
+	_ = x[FreeCellScope-6]
 }
 
-const _SymbolScope_name = "GlobalScopeLocalScopeBuiltinScopeFreeScopeFunctionScope"
+const _SymbolScope_name = "GlobalScopeLocalScopeBuiltinScopeFreeScopeFunctionScopeCellScopeFreeCellScope"
 
-var _SymbolScope_index = [...]uint8{0, 11, 21, 33, 42, 55}
+var _SymbolScope_index = [...]uint8{0, 11, 21, 33, 42, 55, 64, 77}
 
 func (i SymbolScope) String() string {
 	if i < 0 || i >= SymbolScope(len(_SymbolScope_index)-1) {
@@ -2,14 +2,10 @@ module github.com/Seeingu/coldmoon
 
 go 1.23.1
 
-require (
-	github.com/samber/lo v1.47.0
-	github.com/stretchr/testify v1.9.0
-)
+require github.com/stretchr/testify v1.9.0
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/text v0.16.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,16 @@
+package lexer
+
+import "fmt"
+
+// Diagnostic is a scan error, the scanner keeps going after it
+type Diagnostic struct {
+	Message string
+	Line    uint
+	Col     uint
+	// Offset is the byte offset in the scanned File
+	Offset int
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("line %d, col %d: %s", d.Line, d.Col, d.Message)
+}
@@ -0,0 +1,94 @@
+package lexer
+
+import (
+	"fmt"
+	t "github.com/Seeingu/coldmoon/token"
+	"io"
+	"math"
+	"strings"
+)
+
+// readSize is the minimum number of bytes read from reader at a time
+const readSize = 4096
+
+// NewReaderScanner scans source of file read from r, producing the same tokens as NewFileScanner.
+// Only the part of source from the start of current token is buffered,
+// so memory grows with the longest token instead of the whole source.
+// The size of file is the most bytes read from r, e.g. the size of the file on disk,
+// a longer source is a read error. A nil file is added to a new FileSet with math.MaxInt32
+func NewReaderScanner(file *t.File, r io.Reader) *Scanner {
+	if file == nil {
+		file = t.NewFileSet().AddFile("", math.MaxInt32)
+	}
+	s := &Scanner{
+		reader: r,
+		file:   file,
+		line:   1,
+		col:    1,
+	}
+	s.start()
+	return s
+}
+
+// fill reads until n bytes are buffered after index, or reader is done.
+// It is small enough to be inlined into the hot paths of the scanner
+func (s *Scanner) fill(n int) {
+	if s.reader != nil && !s.readDone && len(s.source)-s.index < n {
+		s.read(n)
+	}
+}
+
+func (s *Scanner) read(n int) {
+	for !s.readDone && len(s.source)-s.index < n {
+		// grow with the buffer, so a long token is read in amortized linear time
+		if size := max(readSize, len(s.source)); len(s.readBuffer) < size {
+			s.readBuffer = make([]byte, size)
+		}
+		read, err := s.reader.Read(s.readBuffer)
+		if size := s.file.Size() - s.base - len(s.source); read > size {
+			read, err = size, fmt.Errorf("source is longer than file size %d", s.file.Size())
+		}
+		s.source += string(s.readBuffer[:read])
+		if err != nil {
+			s.readDone = true
+		}
+		if err != io.EOF {
+			s.readErr = err
+		}
+	}
+}
+
+// readError reports the error of reader as an Error token at the end of read source
+func (s *Scanner) readError() (t.Token, bool) {
+	if s.readErr == nil {
+		return t.Token{}, false
+	}
+	s.error(fmt.Sprintf("read error: %s", s.readErr))
+	s.readErr = nil
+	return s.newToken(t.Error, ""), true
+}
+
+// discard drops the scanned part of buffered source,
+// it must be called between tokens when no index into source is kept
+func (s *Scanner) discard() {
+	if s.reader == nil || s.index == 0 {
+		return
+	}
+	s.base += s.index
+	s.source = s.source[s.index:]
+	s.index = 0
+}
+
+// hasPrefix reports whether source at current position starts with str
+func (s *Scanner) hasPrefix(str string) bool {
+	s.fill(len(str))
+	return strings.HasPrefix(s.source[s.index:], str)
+}
+
+// detach copies str out of the buffer of reader, so tokens kept by the parser don't keep the buffer alive
+func (s *Scanner) detach(str string) string {
+	if s.reader == nil {
+		return str
+	}
+	return strings.Clone(str)
+}
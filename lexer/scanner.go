@@ -2,87 +2,165 @@ package lexer
 
 import (
 	"errors"
+	"fmt"
 	t "github.com/Seeingu/coldmoon/token"
-	"github.com/samber/lo"
-	"log"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type Scanner struct {
+	// source is the whole program, or the buffered part of reader from the start of current token
 	source string
 	index  int
-	// line starts with 1
-	line         uint
-	col          uint
+	// reader streams source when not nil, readBuffer is reused between reads
+	reader     io.Reader
+	readBuffer []byte
+	// base is the offset of source[0] in file
+	base int
+	// readDone is true after reader returns an error or io.EOF
+	readDone bool
+	// readErr is reported when scanning reaches the end of read source
+	readErr error
+	// file records line starts, so token offsets map to positions across a FileSet
+	file *t.File
+	// line and col start with 1, col counts code points
+	line uint
+	col  uint
+	// tokenLine and tokenCol are the start position of the token being scanned
+	tokenLine    uint
+	tokenCol     uint
+	tokenStart   int
 	currentToken t.Token
 	nextToken    t.Token
-	inObject     bool
+	// strict rejects sloppy mode only syntax, e.g. legacy octal literals
+	strict      bool
+	diagnostics []Diagnostic
+	// names interns identifier names, so equal names share one string
+	names map[string]string
+	// templateBraces counts open { inside each nested template substitution,
+	// a } closes the substitution when the count of the innermost one is 0
+	templateBraces []int
 }
 
 func NewScanner(source string) *Scanner {
+	return newScanner(nil, source, false)
+}
+
+// NewStrictScanner scans source as strict mode code
+func NewStrictScanner(source string) *Scanner {
+	return newScanner(nil, source, true)
+}
+
+// NewFileScanner scans source of file, which is added to a FileSet with the size of source
+func NewFileScanner(file *t.File, source string) *Scanner {
+	return newScanner(file, source, false)
+}
+
+func newScanner(file *t.File, source string, strict bool) *Scanner {
+	if file == nil {
+		file = t.NewFileSet().AddFile("", len(source))
+	}
+	if file.Size() != len(source) {
+		panic(fmt.Sprintf("file size %d does not match source size %d", file.Size(), len(source)))
+	}
 	s := &Scanner{
 		source: source,
 		index:  0,
+		file:   file,
 		line:   1,
+		col:    1,
+		strict: strict,
 	}
+	s.start()
+	return s
+}
+
+func (s *Scanner) start() {
 	// LR(1)
 	// Scan after init, make sure currentToken always exist
 	s.Scan()
 	s.Scan()
-	return s
 }
 
+// eof is returned when peeking after the end of source
+const eof rune = -1
+
+// Peek returns the code point at current position
 func (s *Scanner) Peek() rune {
-	return rune(s.source[s.index])
+	s.fill(utf8.UTFMax)
+	if s.index >= len(s.source) {
+		return eof
+	}
+	if c := s.source[s.index]; c < utf8.RuneSelf {
+		return rune(c)
+	}
+	r, _ := utf8.DecodeRuneInString(s.source[s.index:])
+	return r
 }
 
+// PeekNext returns the code point after current one
 func (s *Scanner) PeekNext() rune {
-	return rune(s.source[s.index+1])
+	return s.peekAfter(1)
 }
 
 var (
 	errorIndexOutOfSource = errors.New("index is out of source")
 )
 
+// PeekNextMany returns the i-th code point after current one
 func (s *Scanner) PeekNextMany(i int) (r rune, err error) {
-	ii := s.index + i
-	if !s.indexIsValid(ii) {
+	s.fill((i + 1) * utf8.UTFMax)
+	ii := s.index
+	for ; i > 0 && ii < len(s.source); i-- {
+		if s.source[ii] < utf8.RuneSelf {
+			ii++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s.source[ii:])
+		ii += size
+	}
+	if ii >= len(s.source) {
 		err = errorIndexOutOfSource
 		return
 	}
-	r = rune(s.source[s.index+i])
+	if c := s.source[ii]; c < utf8.RuneSelf {
+		return rune(c), nil
+	}
+	r, _ = utf8.DecodeRuneInString(s.source[ii:])
 	return
 }
 
 // MARK: Scanner utils
 
+// error records a diagnostic at current position,
+// the token being scanned becomes an Error token
 func (s *Scanner) error(m string) {
-	log.Printf("scan failed: %s\n", m)
-	log.Printf("line: %d, col: %d\n", s.line, s.col)
-	log.Fatalf("scan error")
+	s.diagnostics = append(s.diagnostics, Diagnostic{
+		Message: m,
+		Line:    s.line,
+		Col:     s.col,
+		Offset:  s.base + s.index,
+	})
 }
 
 // match will update index if matched target str
 func (s *Scanner) match(str string) bool {
-	end := s.index + len(str)
-	if !s.indexIsValid(end) {
+	if !s.hasPrefix(str) {
 		return false
 	}
-	if s.source[s.index:end] == str {
-		s.index = end
-		return true
-	} else {
-		return false
+	for end := s.index + len(str); s.index < end; {
+		s.nextIndex()
 	}
-}
-
-func (s *Scanner) newLine() {
-	s.line++
-	s.col = 1
+	return true
 }
 
 func (s *Scanner) newToken(tokenType t.TokenType, literal string) t.Token {
-	return t.NewToken(tokenType, literal, s.line, s.col)
+	return t.NewToken(tokenType, literal, s.tokenLine, s.tokenCol)
 }
 
 // matchUntil match until f(rune) not satisfy
@@ -90,18 +168,40 @@ func (s *Scanner) newToken(tokenType t.TokenType, literal string) t.Token {
 func (s *Scanner) matchUntil(f func(c rune) bool) string {
 	start := s.index
 	for !s.isAtEnd() && f(s.Peek()) {
-		if s.Peek() == '\n' {
-			s.newLine()
-		}
 		s.nextIndex()
 	}
 	l := s.source[start:s.index]
 	return l
 }
 
+// nextIndex moves to the next code point,
+// line and col are updated after a line terminator, \r\n counts as one line
 func (s *Scanner) nextIndex() {
+	s.fill(utf8.UTFMax)
+	if s.index < len(s.source) && s.source[s.index] < utf8.RuneSelf && !isLineTerminator(rune(s.source[s.index])) {
+		s.index++
+		s.col++
+		return
+	}
+	c, size := utf8.DecodeRuneInString(s.source[s.index:])
+	s.file.AddRune(s.base+s.index, size)
+	s.index += size
+	if isLineTerminator(c) && !(c == '\r' && s.Peek() == '\n') {
+		s.line++
+		s.col = 1
+		s.file.AddLine(s.base + s.index)
+		return
+	}
 	s.col++
-	s.index++
+}
+
+// peekAfter returns the i-th code point after current one, or eof
+func (s *Scanner) peekAfter(i int) rune {
+	r, err := s.PeekNextMany(i)
+	if err != nil {
+		return eof
+	}
+	return r
 }
 
 func (s *Scanner) matchUntilCharMatched(c rune) string {
@@ -110,255 +210,788 @@ func (s *Scanner) matchUntilCharMatched(c rune) string {
 	})
 }
 
+// isLineTerminator reports LF, CR, LS and PS
+func isLineTerminator(c rune) bool {
+	return c == '\n' || c == '\r' || c == '\u2028' || c == '\u2029'
+}
+
+// isWhitespace reports TAB, VT, FF, ZWNBSP and Unicode space separators
+func isWhitespace(c rune) bool {
+	if c < utf8.RuneSelf {
+		return c == ' ' || c == '\t' || c == '\v' || c == '\f'
+	}
+	switch c {
+	case '\t', '\v', '\f', '\ufeff':
+		return true
+	}
+	return unicode.Is(unicode.Zs, c)
+}
+
+// isIdentifierStart reports ID_Start code points, $ and _
+func isIdentifierStart(c rune) bool {
+	if c < utf8.RuneSelf {
+		return c >= 0 && asciiIdentifierStart[c]
+	}
+	return unicode.In(c, unicode.L, unicode.Nl, unicode.Other_ID_Start)
+}
+
+// isIdentifierPart reports ID_Continue code points, $, ZWNJ and ZWJ
+func isIdentifierPart(c rune) bool {
+	if c < utf8.RuneSelf {
+		return c >= 0 && asciiIdentifierPart[c]
+	}
+	if isIdentifierStart(c) || c == '\u200c' || c == '\u200d' {
+		return true
+	}
+	return unicode.In(c, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue)
+}
+
+// asciiIdentifierStart and asciiIdentifierPart look up ASCII code points without Unicode tables
+var asciiIdentifierStart, asciiIdentifierPart = func() (start, part [utf8.RuneSelf]bool) {
+	for c := range utf8.RuneSelf {
+		letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '$' || c == '_'
+		start[c] = letter
+		part[c] = letter || (c >= '0' && c <= '9')
+	}
+	return
+}()
+
 // MARK: token generation
 
-func (s *Scanner) multilineComment() string {
-	comment := s.matchUntil(func(c rune) bool {
-		return s.Peek() != '*' && s.PeekNext() != '/'
+// trivia skips whitespace and comments before the next token,
+// newLine reports whether a line terminator is skipped.
+// Comments before the first line terminator trail the previous token,
+// the others lead the next token
+func (s *Scanner) trivia() (trailing []t.Comment, leading []t.Comment, newLine bool) {
+	// nothing to trail before the first token
+	first := s.tokenLine == 0
+	for !s.isAtEnd() {
+		s.discard()
+		c := s.Peek()
+		var comment t.Comment
+		switch {
+		case c == ' ' || c == '\t':
+			// fast path for indentation
+			for s.index < len(s.source) && (s.source[s.index] == ' ' || s.source[s.index] == '\t') {
+				s.index++
+				s.col++
+			}
+			continue
+		case c == '\n':
+			newLine = true
+			s.index++
+			s.line++
+			s.col = 1
+			s.file.AddLine(s.base + s.index)
+			continue
+		case isLineTerminator(c):
+			newLine = true
+			s.nextIndex()
+			continue
+		case isWhitespace(c):
+			s.nextIndex()
+			continue
+		case c == '/' && s.PeekNext() == '/':
+			comment = s.singleLineComment()
+		case c == '/' && s.PeekNext() == '*':
+			comment = s.multilineComment()
+		default:
+			return
+		}
+		comment.Text = s.detach(comment.Text)
+		if first || newLine {
+			leading = append(leading, comment)
+		} else {
+			trailing = append(trailing, comment)
+		}
+		if comment.Multiline && strings.IndexFunc(comment.Text, isLineTerminator) >= 0 {
+			newLine = true
+		}
+	}
+	return
+}
+
+func (s *Scanner) singleLineComment() t.Comment {
+	comment := t.Comment{Line: s.line, Col: s.col, Start: s.base + s.index}
+	// skip //
+	s.nextIndex()
+	s.nextIndex()
+	comment.Text = s.matchUntil(func(c rune) bool {
+		return !isLineTerminator(c)
 	})
+	comment.End = s.base + s.index
+	return comment
+}
+
+func (s *Scanner) multilineComment() t.Comment {
+	comment := t.Comment{Multiline: true, Line: s.line, Col: s.col, Start: s.base + s.index}
+	// skip /*
 	s.nextIndex()
+	s.nextIndex()
+	start := s.index
+	for !s.isAtEnd() && !s.hasPrefix("*/") {
+		s.nextIndex()
+	}
+	comment.Text = s.source[start:s.index]
+	if !s.match("*/") {
+		s.error("unterminated comment")
+	}
+	comment.End = s.base + s.index
 	return comment
 }
 
-// TODO: float, double
+// number scans a NumericLiteral, the token literal keeps the source text
+// e.g. 1_000, .5, 5., 1e-7, 0x1F, 0o17, 0b11, 017
 func (s *Scanner) number() t.Token {
-	l := s.matchUntil(func(c rune) bool {
-		return unicode.IsDigit(c)
-	})
-	return s.newToken(t.Number, l)
+	start := s.index
+	if s.Peek() == '0' && s.indexIsValid(s.index+1) {
+		switch s.PeekNext() {
+		case 'x', 'X':
+			return s.radixNumber(start, isHexDigit)
+		case 'o', 'O':
+			return s.radixNumber(start, isOctalDigit)
+		case 'b', 'B':
+			return s.radixNumber(start, isBinaryDigit)
+		case '_':
+			s.error("numeric separator is not allowed after leading 0")
+		}
+		if isDecimalDigit(s.PeekNext()) {
+			return s.legacyOctalNumber(start)
+		}
+	}
+
+	if s.Peek() != '.' {
+		s.digits(isDecimalDigit)
+	}
+	s.fractionAndExponent()
+	s.checkNumberEnd()
+	return s.newToken(t.Number, s.source[start:s.index])
 }
 
-func (s *Scanner) keyword(v string) (tt t.Token, ok bool) {
-	switch v {
-	case "var":
-		tt = s.newToken(t.Var, v)
-	case "const":
-		tt = s.newToken(t.Const, v)
-	case "return":
-		tt = s.newToken(t.Return, v)
-	case "let":
-		tt = s.newToken(t.Let, v)
-	case "true":
-		tt = s.newToken(t.True, v)
-	case "false":
-		tt = s.newToken(t.False, v)
-	case "for":
-		tt = s.newToken(t.For, v)
-	case "while":
-		tt = s.newToken(t.While, v)
-	case "if":
-		tt = s.newToken(t.If, v)
-	case "function":
-		tt = s.newToken(t.Function, v)
-	case "else":
-		tt = s.newToken(t.Else, v)
-	case "null":
-		tt = s.newToken(t.Null, v)
-	case "undefined":
-		tt = s.newToken(t.Undefined, v)
-	case "throw":
-		tt = s.newToken(t.Throw, v)
-	case "new":
-		tt = s.newToken(t.New, v)
-	case "error":
-		tt = s.newToken(t.Error, v)
-	case "this":
-		tt = s.newToken(t.This, v)
-	case "super":
-		tt = s.newToken(t.Super, v)
+// radixNumber scans 0x, 0o and 0b literals
+func (s *Scanner) radixNumber(start int, isDigit func(c rune) bool) t.Token {
+	// skip prefix
+	s.nextIndex()
+	s.nextIndex()
+	s.digits(isDigit)
+	s.checkNumberEnd()
+	return s.newToken(t.Number, s.source[start:s.index])
+}
+
+// legacyOctalNumber scans literals with a leading 0, e.g. 017 or 089.
+// Only digits 0-7 make an octal literal, otherwise it is a decimal literal
+func (s *Scanner) legacyOctalNumber(start int) t.Token {
+	if s.strict {
+		s.error("legacy octal literals are not allowed in strict mode")
+	}
+	l := s.matchUntil(isDecimalDigit)
+	if strings.ContainsAny(l, "89") {
+		s.fractionAndExponent()
+	}
+	s.checkNumberEnd()
+	return s.newToken(t.Number, s.source[start:s.index])
+}
+
+func (s *Scanner) fractionAndExponent() {
+	if !s.isAtEnd() && s.Peek() == '.' {
+		s.nextIndex()
+		if !s.isAtEnd() && isDecimalDigit(s.Peek()) {
+			s.digits(isDecimalDigit)
+		}
+	}
+	if !s.isAtEnd() && (s.Peek() == 'e' || s.Peek() == 'E') {
+		s.nextIndex()
+		if !s.isAtEnd() && (s.Peek() == '+' || s.Peek() == '-') {
+			s.nextIndex()
+		}
+		s.digits(isDecimalDigit)
+	}
+}
+
+// digits consumes digits accepted by isDigit,
+// a single numeric separator _ is allowed between two digits
+func (s *Scanner) digits(isDigit func(c rune) bool) {
+	if s.isAtEnd() || !isDigit(s.Peek()) {
+		s.error("number, expected digit")
+		return
+	}
+	for !s.isAtEnd() {
+		c := s.Peek()
+		if c == '_' {
+			next, err := s.PeekNextMany(1)
+			if err != nil || !isDigit(next) {
+				s.error("numeric separator must be between digits")
+			}
+			s.nextIndex()
+			continue
+		}
+		if !isDigit(c) {
+			break
+		}
+		s.nextIndex()
+	}
+}
+
+// checkNumberEnd reports numbers followed by an identifier or digit, e.g. 3in, 0b12
+func (s *Scanner) checkNumberEnd() {
+	if s.isAtEnd() {
+		return
+	}
+	c := s.Peek()
+	if isDecimalDigit(c) || isIdentifierStart(c) {
+		s.error("number, unexpected char: " + string(c))
+		// the rest belongs to the invalid number, e.g. 3in, 0b12
+		s.matchUntil(isIdentifierPart)
+	}
+}
+
+func isDecimalDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c rune) bool {
+	return isDecimalDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c rune) rune {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
 	default:
-		// ignore
+		return c - '0'
+	}
+}
+
+func isOctalDigit(c rune) bool {
+	return c >= '0' && c <= '7'
+}
+
+func isBinaryDigit(c rune) bool {
+	return c == '0' || c == '1'
+}
+
+func (s *Scanner) keyword(v string) (tt t.Token, ok bool) {
+	tokenType, ok := t.LookupKeyword(v)
+	if !ok {
 		return tt, false
 	}
-	return tt, true
+	return s.newToken(tokenType, keywordLiterals[tokenType]), true
+}
+
+// keywordLiterals are the names of keyword token types, they don't keep source alive
+var keywordLiterals = func() (literals [t.EOF + 1]string) {
+	for name, tokenType := range t.Keywords {
+		literals[tokenType] = name
+	}
+	return
+}()
+
+// intern returns the shared copy of name, it doesn't keep source or the buffer of reader alive
+func (s *Scanner) intern(name string) string {
+	if interned, ok := s.names[name]; ok {
+		return interned
+	}
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+	name = strings.Clone(name)
+	s.names[name] = name
+	return name
 }
 
+// string scans a StringLiteral,
+// token Literal is the cooked value and Raw is the source text between quotes
 func (s *Scanner) string(endChar rune) t.Token {
 	// skip begin quote
 	s.nextIndex()
-	l := s.matchUntil(func(c rune) bool {
-		return c != endChar
-	})
-	// skip end quote
-	s.nextIndex()
-	return s.newToken(t.String, l)
+	start := s.index
+	// cooked is only built after an escape, otherwise it is raw
+	var cooked strings.Builder
+	escaped := false
+	terminated := false
+	for !s.isAtEnd() {
+		c := s.Peek()
+		if c == endChar {
+			terminated = true
+			break
+		}
+		if c == '\n' || c == '\r' {
+			break
+		}
+		if c == '\\' {
+			if !escaped {
+				escaped = true
+				cooked.WriteString(s.source[start:s.index])
+			}
+			if err := s.escapeSequence(&cooked, !s.strict); err != nil {
+				s.error(err.Error())
+			}
+			continue
+		}
+		i := s.index
+		s.nextIndex()
+		if escaped {
+			cooked.WriteString(s.source[i:s.index])
+		}
+	}
+	raw := s.source[start:s.index]
+	value := raw
+	if escaped {
+		value = cooked.String()
+	}
+	if terminated {
+		// skip end quote
+		s.nextIndex()
+	} else {
+		s.error("unterminated string literal")
+	}
+	token := s.newToken(t.String, value)
+	token.Raw = raw
+	return token
 }
 
-var identifierSupportSpecialChars = []rune{'_', '$'}
-
-func (s *Scanner) identifier() t.Token {
+// template scans template characters after ` or the } closing a substitution,
+// head is true for the first part of a template.
+// token Literal is the cooked value and Raw is the source text
+func (s *Scanner) template(head bool) t.Token {
+	// skip ` or }
+	s.nextIndex()
 	start := s.index
-	for !s.isAtEnd() {
-		c := rune(s.source[s.index])
-		if unicode.IsSpace(c) || c == '(' || c == ')' || c == '.' || c == ';' || c == '[' || c == ',' {
+	var cooked strings.Builder
+	tokenType := t.TemplateTail
+	if head {
+		tokenType = t.NoSubstitutionTemplate
+	}
+	end := s.index
+	for {
+		if s.isAtEnd() {
+			s.error("unterminated template literal")
+			end = s.index
 			break
 		}
-		if unicode.IsLetter(c) || unicode.IsDigit(c) || lo.Contains(identifierSupportSpecialChars, c) {
-			s.index++
-		} else if s.inObject && c == ':' {
-			l := s.source[start:s.index]
-			return s.newToken(t.String, l)
-		} else {
-			s.error("identifier, unexpected char: " + string(c))
+		c := s.Peek()
+		if c == '`' {
+			end = s.index
+			s.nextIndex()
+			break
+		}
+		if c == '$' {
+			if next, err := s.PeekNextMany(1); err == nil && next == '{' {
+				end = s.index
+				s.nextIndex()
+				s.nextIndex()
+				s.templateBraces = append(s.templateBraces, 0)
+				tokenType = t.TemplateMiddle
+				if head {
+					tokenType = t.TemplateHead
+				}
+				break
+			}
+		}
+		switch c {
+		case '\\':
+			if err := s.escapeSequence(&cooked, false); err != nil {
+				s.error(err.Error())
+			}
+		case '\r':
+			// \r\n and \r are normalized to \n
+			if s.PeekNext() == '\n' {
+				s.nextIndex()
+			}
+			cooked.WriteByte('\n')
+			s.nextIndex()
+		default:
+			i := s.index
+			s.nextIndex()
+			cooked.WriteString(s.source[i:s.index])
 		}
 	}
+	raw := strings.ReplaceAll(s.source[start:end], "\r\n", "\n")
+	raw = strings.ReplaceAll(raw, "\r", "\n")
 
-	l := s.source[start:s.index]
+	token := s.newToken(tokenType, cooked.String())
+	token.Raw = raw
+	return token
+}
 
-	if token, ok := s.keyword(l); ok {
-		return token
+// divisionPrecedingTokens end an expression, a / after them is division
+var divisionPrecedingTokens = []t.TokenType{
+	t.Identifier, t.Number, t.String, t.NoSubstitutionTemplate, t.TemplateTail, t.RegExp,
+	t.RightParenthesis, t.RightSquareBracket, t.RightBracket,
+	t.True, t.False, t.Null, t.Undefined, t.This, t.Super,
+	t.PlusPlus, t.MinusMinus,
+}
+
+// regExpAllowed decides from the previous token whether / starts a regular expression,
+// e.g. a / b is division while (/b/) or = /b/ is a RegExp
+func (s *Scanner) regExpAllowed() bool {
+	// nextToken is the previous token here, it is zero value at the start of source
+	if s.nextToken.Line == 0 {
+		return true
 	}
-	return s.newToken(t.Identifier, l)
+	return !s.nextToken.IsOneOf(divisionPrecedingTokens)
 }
 
-func (s *Scanner) scanToken() t.Token {
-	c := s.Peek()
-	if unicode.IsDigit(c) {
-		return s.number()
+// regExp scans /pattern/flags, the token literal keeps the source text
+func (s *Scanner) regExp() t.Token {
+	start := s.index
+	// skip /
+	s.nextIndex()
+	inClass := false
+	for {
+		c := s.Peek()
+		if s.isAtEnd() || isLineTerminator(c) {
+			s.error("unterminated regular expression")
+			return s.newToken(t.RegExp, s.source[start:s.index])
+		}
+		s.nextIndex()
+		switch {
+		case c == '\\':
+			if s.isAtEnd() || isLineTerminator(s.Peek()) {
+				s.error("unterminated regular expression")
+				return s.newToken(t.RegExp, s.source[start:s.index])
+			}
+			s.nextIndex()
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			flags := s.matchUntil(isIdentifierPart)
+			s.regExpFlags(flags)
+			return s.newToken(t.RegExp, s.source[start:s.index])
+		}
 	}
-	if unicode.IsLetter(c) || lo.Contains(identifierSupportSpecialChars, c) {
-		return s.identifier()
+}
+
+// regExpFlags reports unknown or repeated flags
+func (s *Scanner) regExpFlags(flags string) {
+	for i, flag := range flags {
+		if !strings.ContainsRune("dgimsuvy", flag) {
+			s.error("invalid regular expression flag: " + string(flag))
+		} else if strings.ContainsRune(flags[:i], flag) {
+			s.error("duplicate regular expression flag: " + string(flag))
+		}
 	}
+	if strings.ContainsRune(flags, 'u') && strings.ContainsRune(flags, 'v') {
+		s.error("regular expression flags u and v can't be used together")
+	}
+}
+
+var (
+	errorUnterminatedEscape = errors.New("unterminated escape sequence")
+	errorInvalidHexEscape   = errors.New("invalid hexadecimal escape sequence")
+	errorInvalidUnicode     = errors.New("invalid Unicode escape sequence")
+	errorOctalEscape        = errors.New("octal escape sequences are not allowed here")
+)
+
+// escapeSequence decodes the escape sequence starting at \ into b,
+// legacy octal escapes and \8 \9 are only accepted if allowLegacyOctal
+func (s *Scanner) escapeSequence(b *strings.Builder, allowLegacyOctal bool) error {
+	// skip \
+	s.nextIndex()
+	if s.isAtEnd() {
+		return errorUnterminatedEscape
+	}
+	c := s.Peek()
 	switch c {
-	case '"', '\'':
-		token := s.string(c)
-		return token
-	case '&':
-		if s.match("&&") {
-			return s.newToken(t.AmpersandAmpersand, "&&")
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'v':
+		b.WriteByte('\v')
+	case '\n', '\u2028', '\u2029':
+		// line continuation
+	case '\r':
+		// line continuation, \r\n is a single line terminator
+		if s.PeekNext() == '\n' {
+			s.nextIndex()
 		}
+	case 'x':
 		s.nextIndex()
-		return s.newToken(t.AmpersandAmpersand, "&")
-	case '|':
-		if s.match("||") {
-			return s.newToken(t.BarBar, "||")
+		r, ok := s.hexDigits(2)
+		if !ok {
+			return errorInvalidHexEscape
 		}
+		b.WriteRune(r)
+		return nil
+	case 'u':
 		s.nextIndex()
-		return s.newToken(t.BarBar, "|")
-	case '+':
-		if s.match("+=") {
-			return s.newToken(t.PlusEqual, "+=")
-		} else if s.match("++") {
-			return s.newToken(t.PlusPlus, "++")
+		r, err := s.unicodeEscape()
+		if err != nil {
+			return err
 		}
-		s.nextIndex()
-		return s.newToken(t.Plus, "+")
-	case '-':
-		if s.match("-=") {
-			return s.newToken(t.MinusEqual, "-=")
-		} else if s.match("--") {
-			return s.newToken(t.MinusMinus, "--")
+		b.WriteRune(r)
+		return nil
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		if next, err := s.PeekNextMany(1); c == '0' && (err != nil || !isDecimalDigit(next)) {
+			b.WriteByte(0)
+			break
 		}
-		s.nextIndex()
-		return s.newToken(t.Minus, "-")
-	case '*':
-		if s.match("*=") {
-			return s.newToken(t.StarEqual, "*=")
-		} else if s.match("**") {
-			return s.newToken(t.StarStar, "**")
+		if !allowLegacyOctal {
+			return errorOctalEscape
 		}
-		s.nextIndex()
-		return s.newToken(t.Star, "*")
-	case '/':
-		if s.match("//") {
-			comment := s.matchUntilCharMatched('\n')
-			s.newLine()
-			return s.newToken(t.SlashSlash, comment)
-		} else if s.match("/*") {
-			comment := s.multilineComment()
-			return s.newToken(t.SlashStar, comment)
-		} else if s.match("/=") {
-			return s.newToken(t.SlashEqual, "/=")
+		b.WriteRune(s.legacyOctalEscape())
+		return nil
+	case '8', '9':
+		if !allowLegacyOctal {
+			return errorOctalEscape
 		}
+		b.WriteRune(c)
+	default:
+		// NonEscapeCharacter, e.g. \' \" \\
+		i := s.index
 		s.nextIndex()
-		return s.newToken(t.Slash, "/")
-	case '!':
-		if s.match("!=") {
-			return s.newToken(t.BangEqual, "!=")
+		b.WriteString(s.source[i:s.index])
+		return nil
+	}
+	s.nextIndex()
+	return nil
+}
+
+// unicodeEscape decodes \uHHHH and \u{H...} after the u,
+// a surrogate pair written as two escapes is combined into one code point
+func (s *Scanner) unicodeEscape() (rune, error) {
+	if !s.isAtEnd() && s.Peek() == '{' {
+		s.nextIndex()
+		start := s.index
+		s.matchUntil(isHexDigit)
+		digits := s.source[start:s.index]
+		if digits == "" || s.isAtEnd() || s.Peek() != '}' {
+			return 0, errorInvalidUnicode
 		}
 		s.nextIndex()
-		return s.newToken(t.Bang, "!")
-	case '=':
-		if s.match("===") {
-			return s.newToken(t.EqualEqualEqual, "===")
-		} else if s.match("==") {
-			return s.newToken(t.EqualEqual, "==")
-		} else if s.match("=>") {
-			return s.newToken(t.EqualGreater, "=>")
+		value, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || value > unicode.MaxRune {
+			return 0, errorInvalidUnicode
 		}
+		return rune(value), nil
+	}
 
-		s.nextIndex()
-		return s.newToken(t.Equal, "=")
-	case '>':
-		if s.match(">=") {
-			return s.newToken(t.GreaterEqual, ">=")
-		} else if s.match(">>=") {
-			return s.newToken(t.GreaterGreaterEqual, ">>=")
-		} else if s.match(">>") {
-			return s.newToken(t.GreaterGreater, ">>")
-		} else if s.match(">>>") {
-			return s.newToken(t.GreaterGreaterGreater, ">>>")
+	r, ok := s.hexDigits(4)
+	if !ok {
+		return 0, errorInvalidUnicode
+	}
+	if utf16.IsSurrogate(r) && s.hasPrefix(`\u`) && s.indexIsValid(s.index+5) {
+		low, err := strconv.ParseUint(s.source[s.index+2:s.index+6], 16, 16)
+		if pair := utf16.DecodeRune(r, rune(low)); err == nil && pair != unicode.ReplacementChar {
+			for i := 0; i < 6; i++ {
+				s.nextIndex()
+			}
+			return pair, nil
 		}
-		s.nextIndex()
-		return s.newToken(t.Greater, ">")
-	case '<':
-		if s.match("<=") {
-			return s.newToken(t.LessEqual, "<=")
-		} else if s.match("<<=") {
-			return s.newToken(t.LessEqual, "<<=")
-		} else if s.match("<<") {
-			return s.newToken(t.LessLess, "<<")
-		} else if s.match("<<<") {
-			return s.newToken(t.LessLessLess, "<<<")
+	}
+	return r, nil
+}
+
+// hexDigits reads exactly n hex digits
+func (s *Scanner) hexDigits(n int) (rune, bool) {
+	var r rune
+	for i := 0; i < n; i++ {
+		if s.isAtEnd() || !isHexDigit(s.Peek()) {
+			return 0, false
 		}
+		r = r*16 + hexValue(s.Peek())
 		s.nextIndex()
-		return s.newToken(t.Less, "<")
-	case '?':
+	}
+	return r, true
+}
+
+// legacyOctalEscape reads up to 3 octal digits, the value is at most \377
+func (s *Scanner) legacyOctalEscape() rune {
+	maxDigits := 3
+	if s.Peek() >= '4' {
+		maxDigits = 2
+	}
+	var r rune
+	for i := 0; i < maxDigits && !s.isAtEnd() && isOctalDigit(s.Peek()); i++ {
+		r = r*8 + s.Peek() - '0'
 		s.nextIndex()
-		return s.newToken(t.Question, "?")
-	case '(':
+	}
+	return r
+}
+
+// identifier scans IdentifierName, \u escapes are decoded into the token literal
+func (s *Scanner) identifier() t.Token {
+	// fast path for ASCII names, which are a slice of source
+	start := s.index
+	for {
+		s.fill(1)
+		if s.index >= len(s.source) {
+			break
+		}
+		c := s.source[s.index]
+		if c >= utf8.RuneSelf || c == '\\' {
+			return s.unicodeIdentifier(start)
+		}
+		if !asciiIdentifierPart[c] {
+			break
+		}
+		s.index++
+		s.col++
+	}
+	l := s.source[start:s.index]
+	if token, ok := s.keyword(l); ok {
+		return token
+	}
+	return s.newToken(t.Identifier, s.intern(l))
+}
+
+// unicodeIdentifier scans an identifier with non-ASCII code points or escapes,
+// the name from start to current position is already scanned
+func (s *Scanner) unicodeIdentifier(start int) t.Token {
+	var name strings.Builder
+	name.WriteString(s.source[start:s.index])
+	escaped := false
+	for !s.isAtEnd() {
+		c := s.Peek()
+		if c == '\\' {
+			escaped = true
+			r, err := s.identifierEscape()
+			if err != nil {
+				s.error(err.Error())
+			} else if !isIdentifierPart(r) || (name.Len() == 0 && !isIdentifierStart(r)) {
+				s.error("invalid identifier escape: " + string(r))
+			}
+			name.WriteRune(r)
+			continue
+		}
+		if !isIdentifierPart(c) {
+			break
+		}
+		i := s.index
 		s.nextIndex()
-		return s.newToken(t.LeftParenthesis, "(")
-	case ')':
+		name.WriteString(s.source[i:s.index])
+	}
+
+	l := name.String()
+
+	if token, ok := s.keyword(l); ok {
+		if escaped {
+			s.error("keyword must not contain escaped characters: " + l)
+		}
+		return token
+	}
+	return s.newToken(t.Identifier, s.intern(l))
+}
+
+// identifierEscape decodes \uHHHH or \u{H...} in IdentifierName
+func (s *Scanner) identifierEscape() (rune, error) {
+	// skip \
+	s.nextIndex()
+	if s.Peek() != 'u' {
+		return 0, errorInvalidUnicode
+	}
+	s.nextIndex()
+	return s.unicodeEscape()
+}
+
+func (s *Scanner) scanToken() t.Token {
+	c := s.Peek()
+	if isDecimalDigit(c) {
+		return s.number()
+	}
+	if isIdentifierStart(c) || c == '\\' {
+		return s.identifier()
+	}
+	switch c {
+	case '"', '\'':
+		token := s.string(c)
+		return token
+	case '`':
+		return s.template(true)
+	case '.':
+		if isDecimalDigit(s.PeekNext()) {
+			return s.number()
+		}
+	case '/':
+		if s.regExpAllowed() {
+			return s.regExp()
+		}
+		if s.match("/=") {
+			return s.newToken(t.SlashEqual, "/=")
+		}
 		s.nextIndex()
-		return s.newToken(t.RightParenthesis, ")")
+		return s.newToken(t.Slash, "/")
 	case '{':
-		s.inObject = true
-		s.nextIndex()
-		return s.newToken(t.LeftBracket, "{")
+		if n := len(s.templateBraces); n > 0 {
+			s.templateBraces[n-1]++
+		}
 	case '}':
-		s.inObject = false
+		if n := len(s.templateBraces); n > 0 {
+			if s.templateBraces[n-1] == 0 {
+				// end of substitution, continue the template
+				s.templateBraces = s.templateBraces[:n-1]
+				return s.template(false)
+			}
+			s.templateBraces[n-1]--
+		}
 		s.nextIndex()
 		return s.newToken(t.RightBracket, "}")
-	case '[':
-		s.nextIndex()
-		return s.newToken(t.LeftSquareBracket, "[")
-	case ']':
-		s.nextIndex()
-		return s.newToken(t.RightSquareBracket, "]")
-	case ',':
-		s.nextIndex()
-		return s.newToken(t.Comma, ",")
-	case ':':
-		s.nextIndex()
-		return s.newToken(t.Colon, ":")
-	case ';':
-		s.nextIndex()
-		return s.newToken(t.Semicolon, ";")
-	case '.':
-		if s.match("...") {
-			return s.newToken(t.DotDotDot, "...")
+	}
+
+	if token, ok := s.punctuator(); ok {
+		return token
+	}
+
+	// report at the start of the char
+	s.error("unexpected char: " + string(s.Peek()))
+	start := s.index
+	s.nextIndex()
+	l := s.source[start:s.index]
+	return s.newToken(t.Error, l)
+}
+
+// punctuator scans the longest punctuator at current position
+func (s *Scanner) punctuator() (t.Token, bool) {
+	s.fill(t.MaxPunctuatorLength)
+	c := s.source[s.index]
+	if c >= utf8.RuneSelf {
+		return t.Token{}, false
+	}
+	for _, p := range punctuatorTable[c] {
+		if !strings.HasPrefix(s.source[s.index:], p.literal) {
+			continue
 		}
-		s.nextIndex()
-		return s.newToken(t.Dot, ".")
-	case '~':
-		s.nextIndex()
-		return s.newToken(t.Tilde, "~")
+		if p.tokenType == t.QuestionDot && isDecimalDigit(s.peekAfter(2)) {
+			// a?.5:b is a conditional
+			continue
+		}
+		// punctuators are ASCII without line terminators
+		s.index += len(p.literal)
+		s.col += uint(len(p.literal))
+		return s.newToken(p.tokenType, p.literal), true
 	}
+	return t.Token{}, false
+}
 
-	panic("unreachable")
+type punctuatorEntry struct {
+	literal   string
+	tokenType t.TokenType
 }
 
+// punctuatorTable lists Punctuators by their first byte, longest first
+var punctuatorTable = func() (table [utf8.RuneSelf][]punctuatorEntry) {
+	for literal, tokenType := range t.Punctuators {
+		table[literal[0]] = append(table[literal[0]], punctuatorEntry{literal, tokenType})
+	}
+	for _, entries := range table {
+		slices.SortFunc(entries, func(a, b punctuatorEntry) int {
+			return len(b.literal) - len(a.literal)
+		})
+	}
+	return
+}()
+
 // MARK: Public
 
 func (s *Scanner) CurrentToken() t.Token {
@@ -375,31 +1008,123 @@ func (s *Scanner) Scan() t.Token {
 		s.currentToken = s.nextToken
 		return s.currentToken
 	}
-	for !s.isAtEnd() && unicode.IsSpace(s.Peek()) {
-		if s.Peek() == '\n' {
-			s.newLine()
+	trailing, leading, newLine := s.trivia()
+	s.nextToken.TrailingComments = trailing
+	s.tokenLine = s.line
+	s.tokenCol = s.col
+	s.tokenStart = s.index
+	var token t.Token
+	if s.isAtEnd() {
+		if errorToken, ok := s.readError(); ok {
+			token = errorToken
+		} else {
+			token = s.newToken(t.EOF, "")
+		}
+	} else {
+		numDiagnostics := len(s.diagnostics)
+		token = s.scanToken()
+		if len(s.diagnostics) > numDiagnostics {
+			token.TokenType = t.Error
 		}
-		s.nextIndex()
 	}
-	if s.isAtEnd() {
-		s.currentToken = s.nextToken
-		s.nextToken = s.newToken(t.EOF, "")
-		return s.currentToken
+	switch token.TokenType {
+	case t.Number, t.String, t.NoSubstitutionTemplate, t.TemplateHead, t.TemplateMiddle, t.TemplateTail, t.RegExp, t.Error:
+		// literals of other tokens are interned names or constants
+		token.Literal = s.detach(token.Literal)
+		token.Raw = s.detach(token.Raw)
 	}
-	token := s.scanToken()
+	token.Start = s.base + s.tokenStart
+	token.End = s.base + s.index
+	token.LeadingComments = leading
+	token.NewLineBefore = newLine
 	s.currentToken = s.nextToken
 	s.nextToken = token
 	return s.currentToken
 }
 
 func (s *Scanner) isAtEnd() bool {
+	s.fill(1)
 	return s.index >= len(s.source)
 }
 
+// Strict reports whether tokens are scanned as strict mode code
+func (s *Scanner) Strict() bool {
+	return s.strict
+}
+
+// SetStrict switches strict mode for the tokens after the next one, e.g. after a "use strict" directive,
+// the next token is scanned already so it is checked with CheckStrict
+func (s *Scanner) SetStrict(strict bool) {
+	if strict && !s.strict {
+		s.CheckStrict(s.nextToken)
+	}
+	s.strict = strict
+}
+
+// CheckStrict reports a legacy octal literal or escape sequence in token scanned in sloppy mode,
+// e.g. a directive before "use strict"
+func (s *Scanner) CheckStrict(token t.Token) {
+	var message string
+	switch {
+	case token.Is(t.Number) && len(token.Literal) > 1 && token.Literal[0] == '0' && isDecimalDigit(rune(token.Literal[1])):
+		message = "legacy octal literals are not allowed in strict mode"
+	case token.Is(t.String) && hasLegacyOctalEscape(token.Raw):
+		message = errorOctalEscape.Error()
+	default:
+		return
+	}
+	// keep diagnostics in source order, token may be before diagnostics of later tokens
+	i := len(s.diagnostics)
+	for i > 0 && s.diagnostics[i-1].Offset > token.Start {
+		i--
+	}
+	s.diagnostics = slices.Insert(s.diagnostics, i, Diagnostic{
+		Message: message,
+		Line:    token.Line,
+		Col:     token.Col,
+		Offset:  token.Start,
+	})
+}
+
+// hasLegacyOctalEscape reports whether raw has an escape sequence rejected by escapeSequence in strict mode
+func hasLegacyOctalEscape(raw string) bool {
+	for i := 0; i < len(raw)-1; i++ {
+		if raw[i] != '\\' {
+			continue
+		}
+		i++
+		switch c := raw[i]; {
+		case c == '0':
+			if i+1 < len(raw) && isDecimalDigit(rune(raw[i+1])) {
+				return true
+			}
+		case isDecimalDigit(rune(c)):
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostics returns errors found while scanning, in source order
+func (s *Scanner) Diagnostics() []Diagnostic {
+	return s.diagnostics
+}
+
+// File returns the file of source, its lines are known up to the scanned position
+func (s *Scanner) File() *t.File {
+	return s.file
+}
+
+// Position returns the position of a byte offset, e.g. Token.Start
+func (s *Scanner) Position(offset int) t.Position {
+	return s.file.Position(s.file.Pos(offset))
+}
+
 func (s *Scanner) HasNextToken() bool {
 	return !s.nextToken.Is(t.EOF)
 }
 
 func (s *Scanner) indexIsValid(index int) bool {
+	s.fill(index - s.index + 1)
 	return index >= 0 && index < len(s.source)
 }
@@ -1,10 +1,15 @@
 package lexer
 
 import (
+	"errors"
 	"fmt"
 	tt "github.com/Seeingu/coldmoon/token"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"unsafe"
 )
 
 func TestScannerTokens(t *testing.T) {
@@ -33,12 +38,12 @@ let o = {a: 1, 'b': 2, "c": 3}
 		tt.String,
 		tt.Semicolon,
 		// let o = ...
-		tt.Let,
+		tt.Identifier,
 		tt.Identifier,
 		// index: 10
 		tt.Equal,
 		tt.LeftBracket,
-		tt.String,
+		tt.Identifier,
 		tt.Colon,
 		tt.Number,
 		tt.Comma,
@@ -52,7 +57,7 @@ let o = {a: 1, 'b': 2, "c": 3}
 		tt.Number,
 		tt.RightBracket,
 		// sum = ...
-		tt.Let,
+		tt.Identifier,
 		tt.Identifier,
 		tt.Equal,
 		tt.Function,
@@ -78,3 +83,554 @@ let o = {a: 1, 'b': 2, "c": 3}
 		assert.Equal(t, tokenType, tokens[i].TokenType, fmt.Sprintf("index: %d, expected: %s, actual: %s", i, tokenType.String(), tokens[i].TokenType.String()))
 	}
 }
+
+func TestScannerNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1.5 .5 5.", []string{"1.5", ".5", "5."}},
+		{"1e-7 2E+3 3e4", []string{"1e-7", "2E+3", "3e4"}},
+		{"0x1F 0o17 0B11", []string{"0x1F", "0o17", "0B11"}},
+		{"1_000_000 0b1_0", []string{"1_000_000", "0b1_0"}},
+		{"017 08.5", []string{"017", "08.5"}},
+	}
+	for _, test := range tests {
+		s := NewScanner(test.input)
+		for _, literal := range test.expected {
+			token := s.CurrentToken()
+			assert.Equal(t, tt.Number, token.TokenType)
+			assert.Equal(t, literal, token.Literal)
+			s.Scan()
+		}
+		assert.True(t, s.CurrentToken().Is(tt.EOF))
+	}
+}
+
+func TestScannerStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		raw      string
+	}{
+		{`"a\nb\tc"`, "a\nb\tc", `a\nb\tc`},
+		{`'it\'s "ok"'`, `it's "ok"`, `it\'s "ok"`},
+		{`"\\ \0 \b\f\v\r"`, "\\ \x00 \b\f\v\r", `\\ \0 \b\f\v\r`},
+		{`"\x41\u0042\u{43}\u{1F600}"`, "ABC\U0001F600", `\x41\u0042\u{43}\u{1F600}`},
+		{`"\uD83D\uDE00"`, "\U0001F600", `\uD83D\uDE00`},
+		{"\"line \\\ncontinuation\"", "line continuation", "line \\\ncontinuation"},
+		{"\"crlf \\\r\ncontinuation\"", "crlf continuation", "crlf \\\r\ncontinuation"},
+		{`"\101\7\08"`, "A\x07\x008", `\101\7\08`},
+		{`"\a\8"`, "a8", `\a\8`},
+		{`"日本"`, "日本", "日本"},
+	}
+	for _, test := range tests {
+		s := NewScanner(test.input)
+		token := s.CurrentToken()
+		assert.Equal(t, tt.String, token.TokenType)
+		assert.Equal(t, test.expected, token.Literal)
+		assert.Equal(t, test.raw, token.Raw)
+	}
+}
+
+func TestScannerTemplates(t *testing.T) {
+	s := NewScanner("`a${b + `c${d}`}e${ {f: 1} }\\n\r\n`")
+	expected := []struct {
+		tokenType tt.TokenType
+		literal   string
+		raw       string
+	}{
+		{tt.TemplateHead, "a", "a"},
+		{tt.Identifier, "b", ""},
+		{tt.Plus, "+", ""},
+		{tt.TemplateHead, "c", "c"},
+		{tt.Identifier, "d", ""},
+		{tt.TemplateTail, "", ""},
+		{tt.TemplateMiddle, "e", "e"},
+		{tt.LeftBracket, "{", ""},
+		{tt.Identifier, "f", ""},
+		{tt.Colon, ":", ""},
+		{tt.Number, "1", ""},
+		{tt.RightBracket, "}", ""},
+		{tt.TemplateTail, "\n\n", "\\n\n"},
+		{tt.EOF, "", ""},
+	}
+	for i, e := range expected {
+		token := s.CurrentToken()
+		assert.Equal(t, e.tokenType, token.TokenType, fmt.Sprintf("index: %d", i))
+		assert.Equal(t, e.literal, token.Literal, fmt.Sprintf("index: %d", i))
+		assert.Equal(t, e.raw, token.Raw, fmt.Sprintf("index: %d", i))
+		s.Scan()
+	}
+}
+
+func TestScannerDiagnostics(t *testing.T) {
+	tests := []struct {
+		input       string
+		tokenTypes  []tt.TokenType
+		diagnostics []string
+	}{
+		{
+			"a # b",
+			[]tt.TokenType{tt.Identifier, tt.Error, tt.Identifier},
+			[]string{"unexpected char: #"},
+		},
+		{
+			"'abc\n1",
+			[]tt.TokenType{tt.Error, tt.Number},
+			[]string{"unterminated string literal"},
+		},
+		{
+			"3in 0b12 1__0",
+			[]tt.TokenType{tt.Error, tt.Error, tt.Error},
+			[]string{
+				"number, unexpected char: i",
+				"number, unexpected char: 2",
+				"numeric separator must be between digits",
+			},
+		},
+		{
+			`"\x4" "\u{110000}"`,
+			[]tt.TokenType{tt.Error, tt.Error},
+			[]string{
+				"invalid hexadecimal escape sequence",
+				"invalid Unicode escape sequence",
+			},
+		},
+		{
+			"`a${b}",
+			[]tt.TokenType{tt.TemplateHead, tt.Identifier, tt.Error},
+			[]string{"unterminated template literal"},
+		},
+	}
+	for _, test := range tests {
+		s := NewScanner(test.input)
+		for i, tokenType := range test.tokenTypes {
+			assert.Equal(t, tokenType, s.CurrentToken().TokenType, fmt.Sprintf("%s index: %d", test.input, i))
+			s.Scan()
+		}
+		assert.True(t, s.CurrentToken().Is(tt.EOF))
+		var messages []string
+		for _, d := range s.Diagnostics() {
+			assert.Equal(t, uint(1), d.Line)
+			messages = append(messages, d.Message)
+		}
+		assert.Equal(t, test.diagnostics, messages, test.input)
+	}
+}
+
+func TestScannerStrict(t *testing.T) {
+	s := NewStrictScanner(`017 "\07"`)
+	assert.Equal(t, tt.Error, s.CurrentToken().TokenType)
+	assert.Equal(t, tt.Error, s.Scan().TokenType)
+	assert.Equal(t, 2, len(s.Diagnostics()))
+
+	s = NewScanner(`010 "\07" 011`)
+	first := s.CurrentToken()
+	assert.False(t, s.Strict())
+	s.SetStrict(true)
+	s.Scan()
+	s.CheckStrict(first)
+	var cols []uint
+	for _, d := range s.Diagnostics() {
+		cols = append(cols, d.Col)
+	}
+	assert.Equal(t, []uint{1, 5, 11}, cols)
+	assert.Equal(t, tt.Error, s.NextToken().TokenType)
+}
+
+func TestScannerUnicode(t *testing.T) {
+	s := NewScanner("let café = \"日本語\"\u2028ĳ\\u0061\\u{62}$_\u200d\u00a0\ufeffπ2\r\n  ℮")
+	expected := []struct {
+		tokenType tt.TokenType
+		literal   string
+		line      uint
+		col       uint
+	}{
+		{tt.Identifier, "let", 1, 1},
+		{tt.Identifier, "café", 1, 5},
+		{tt.Equal, "=", 1, 10},
+		{tt.String, "日本語", 1, 12},
+		{tt.Identifier, "ĳab$_\u200d", 2, 1},
+		{tt.Identifier, "π2", 2, 19},
+		{tt.Identifier, "℮", 3, 3},
+		{tt.EOF, "", 3, 4},
+	}
+	for i, e := range expected {
+		token := s.CurrentToken()
+		message := fmt.Sprintf("index: %d", i)
+		assert.Equal(t, e.tokenType, token.TokenType, message)
+		assert.Equal(t, e.literal, token.Literal, message)
+		assert.Equal(t, e.line, token.Line, message)
+		assert.Equal(t, e.col, token.Col, message)
+		s.Scan()
+	}
+	assert.Empty(t, s.Diagnostics())
+}
+
+func TestScannerIdentifierEscapeErrors(t *testing.T) {
+	tests := []string{
+		`\u0069f`,
+		`\u0031a`,
+		`a\x61`,
+	}
+	for _, input := range tests {
+		s := NewScanner(input)
+		assert.Equal(t, tt.Error, s.CurrentToken().TokenType, input)
+		assert.Equal(t, 1, len(s.Diagnostics()), input)
+	}
+}
+
+func TestScannerComments(t *testing.T) {
+	s := NewScanner(`// leading
+/* block */ a /* same line */ // trailing
+/** multi
+ * line */ b /* a*b/c **/
+/* unterminated`)
+	a := s.CurrentToken()
+	assert.Equal(t, "a", a.Literal)
+	assert.Equal(t, []tt.Comment{
+		{Text: " leading", Line: 1, Col: 1, Start: 0, End: 10},
+		{Text: " block ", Multiline: true, Line: 2, Col: 1, Start: 11, End: 22},
+	}, a.LeadingComments)
+	assert.Equal(t, []tt.Comment{
+		{Text: " same line ", Multiline: true, Line: 2, Col: 15, Start: 25, End: 40},
+		{Text: " trailing", Line: 2, Col: 31, Start: 41, End: 52},
+	}, a.TrailingComments)
+
+	b := s.Scan()
+	assert.Equal(t, "b", b.Literal)
+	assert.Equal(t, []tt.Comment{
+		{Text: "* multi\n * line ", Multiline: true, Line: 3, Col: 1, Start: 53, End: 73},
+	}, b.LeadingComments)
+	assert.Equal(t, []tt.Comment{
+		{Text: " a*b/c *", Multiline: true, Line: 4, Col: 14, Start: 76, End: 88},
+	}, b.TrailingComments)
+
+	eof := s.Scan()
+	assert.True(t, eof.Is(tt.EOF))
+	assert.Equal(t, " unterminated", eof.LeadingComments[0].Text)
+	assert.Equal(t, "unterminated comment", s.Diagnostics()[0].Message)
+}
+
+func TestScannerNewLineBefore(t *testing.T) {
+	s := NewScanner("a b\nc /*\n*/ d /* */ e\u2028f")
+	expected := []bool{false, false, true, true, false, true}
+	for i, newLine := range expected {
+		assert.Equal(t, newLine, s.CurrentToken().NewLineBefore, fmt.Sprintf("index: %d", i))
+		s.Scan()
+	}
+}
+
+func TestScannerRegExp(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []tt.Token
+	}{
+		{"/ab+c/gi", []tt.Token{{TokenType: tt.RegExp, Literal: "/ab+c/gi"}}},
+		{"a / b / c", []tt.Token{
+			{TokenType: tt.Identifier, Literal: "a"},
+			{TokenType: tt.Slash, Literal: "/"},
+			{TokenType: tt.Identifier, Literal: "b"},
+			{TokenType: tt.Slash, Literal: "/"},
+			{TokenType: tt.Identifier, Literal: "c"},
+		}},
+		{"x = /[/\\]]\\//;", []tt.Token{
+			{TokenType: tt.Identifier, Literal: "x"},
+			{TokenType: tt.Equal, Literal: "="},
+			{TokenType: tt.RegExp, Literal: "/[/\\]]\\//"},
+			{TokenType: tt.Semicolon, Literal: ";"},
+		}},
+		{"(/=/)", []tt.Token{
+			{TokenType: tt.LeftParenthesis, Literal: "("},
+			{TokenType: tt.RegExp, Literal: "/=/"},
+			{TokenType: tt.RightParenthesis, Literal: ")"},
+		}},
+		{"a[0] /= 2", []tt.Token{
+			{TokenType: tt.Identifier, Literal: "a"},
+			{TokenType: tt.LeftSquareBracket, Literal: "["},
+			{TokenType: tt.Number, Literal: "0"},
+			{TokenType: tt.RightSquareBracket, Literal: "]"},
+			{TokenType: tt.SlashEqual, Literal: "/="},
+			{TokenType: tt.Number, Literal: "2"},
+		}},
+	}
+	for _, test := range tests {
+		s := NewScanner(test.input)
+		for i, e := range test.expected {
+			token := s.CurrentToken()
+			message := fmt.Sprintf("%s index: %d", test.input, i)
+			assert.Equal(t, e.TokenType, token.TokenType, message)
+			assert.Equal(t, e.Literal, token.Literal, message)
+			s.Scan()
+		}
+		assert.True(t, s.CurrentToken().Is(tt.EOF), test.input)
+		assert.Empty(t, s.Diagnostics(), test.input)
+	}
+}
+
+func TestScannerRegExpErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{"/abc", "unterminated regular expression"},
+		{"/a\nb/", "unterminated regular expression"},
+		{"/a/x", "invalid regular expression flag: x"},
+		{"/a/gg", "duplicate regular expression flag: g"},
+		{"/a/uv", "regular expression flags u and v can't be used together"},
+	}
+	for _, test := range tests {
+		s := NewScanner(test.input)
+		assert.Equal(t, tt.Error, s.CurrentToken().TokenType, test.input)
+		assert.Equal(t, test.message, s.Diagnostics()[0].Message, test.input)
+	}
+}
+
+func TestScannerPunctuators(t *testing.T) {
+	for punctuator, tokenType := range tt.Punctuators {
+		s := NewScanner(punctuator)
+		token := s.CurrentToken()
+		assert.Equal(t, tokenType, token.TokenType, punctuator)
+		assert.Equal(t, punctuator, token.Literal)
+		assert.True(t, s.Scan().Is(tt.EOF), punctuator)
+	}
+	for _, slash := range []string{"/", "/="} {
+		s := NewScanner("a " + slash)
+		assert.Equal(t, slash, s.Scan().Literal)
+	}
+}
+
+func TestScannerKeywords(t *testing.T) {
+	for keyword, tokenType := range tt.Keywords {
+		s := NewScanner(keyword + " " + keyword + "s")
+		assert.Equal(t, tokenType, s.CurrentToken().TokenType, keyword)
+		assert.Equal(t, tt.Identifier, s.Scan().TokenType, keyword+"s")
+	}
+}
+
+func TestScannerLongestMatch(t *testing.T) {
+	tests := []struct {
+		input      string
+		tokenTypes []tt.TokenType
+	}{
+		{"a & b", []tt.TokenType{tt.Identifier, tt.Ampersand, tt.Identifier}},
+		{"a >>= b", []tt.TokenType{tt.Identifier, tt.GreaterGreaterEqual, tt.Identifier}},
+		{"a <<= b", []tt.TokenType{tt.Identifier, tt.LessLessEqual, tt.Identifier}},
+		{"a >>>= b >>> c", []tt.TokenType{
+			tt.Identifier, tt.GreaterGreaterGreaterEqual, tt.Identifier, tt.GreaterGreaterGreater, tt.Identifier,
+		}},
+		{"a?.b ?? c", []tt.TokenType{tt.Identifier, tt.QuestionDot, tt.Identifier, tt.QuestionQuestion, tt.Identifier}},
+		{"a&&b||c&&=d", []tt.TokenType{
+			tt.Identifier, tt.AmpersandAmpersand, tt.Identifier, tt.BarBar, tt.Identifier, tt.AmpersandAmpersandEqual, tt.Identifier,
+		}},
+		{"a?.5:1", []tt.TokenType{tt.Identifier, tt.Question, tt.Number, tt.Colon, tt.Number}},
+		{"a!==b===c", []tt.TokenType{tt.Identifier, tt.BangEqualEqual, tt.Identifier, tt.EqualEqualEqual, tt.Identifier}},
+		{"a**=b", []tt.TokenType{tt.Identifier, tt.StarStarEqual, tt.Identifier}},
+		{"a+++b", []tt.TokenType{tt.Identifier, tt.PlusPlus, tt.Plus, tt.Identifier}},
+		{"a....5", []tt.TokenType{tt.Identifier, tt.DotDotDot, tt.Number}},
+		{"class A extends B", []tt.TokenType{tt.Class, tt.Identifier, tt.Extends, tt.Identifier}},
+	}
+	for _, test := range tests {
+		s := NewScanner(test.input)
+		for i, tokenType := range test.tokenTypes {
+			assert.Equal(t, tokenType, s.CurrentToken().TokenType, fmt.Sprintf("%s index: %d", test.input, i))
+			s.Scan()
+		}
+		assert.True(t, s.CurrentToken().Is(tt.EOF), test.input)
+	}
+}
+
+func TestScannerOffsets(t *testing.T) {
+	s := NewScanner("let a = 'é';\r\n  b\u2028/* c */ d")
+	expected := []struct {
+		literal    string
+		start, end int
+		line, col  int
+	}{
+		{"let", 0, 3, 1, 1},
+		{"a", 4, 5, 1, 5},
+		{"=", 6, 7, 1, 7},
+		{"é", 8, 12, 1, 9},
+		{";", 12, 13, 1, 12},
+		{"b", 17, 18, 2, 3},
+		{"d", 29, 30, 3, 9},
+		{"", 30, 30, 3, 10},
+	}
+	for _, e := range expected {
+		token := s.CurrentToken()
+		assert.Equal(t, e.literal, token.Literal)
+		assert.Equal(t, e.start, token.Start, e.literal)
+		assert.Equal(t, e.end, token.End, e.literal)
+		position := s.Position(token.Start)
+		assert.Equal(t, e.line, position.Line, e.literal)
+		assert.Equal(t, e.col, position.Column, e.literal)
+		assert.Equal(t, uint(position.Column), token.Col, e.literal)
+		s.Scan()
+	}
+	assert.Equal(t, 3, s.File().LineCount())
+}
+
+func TestScannerFileSet(t *testing.T) {
+	fileSet := tt.NewFileSet()
+	sources := []string{"a\nb", "", "c\n\nd"}
+	var scanners []*Scanner
+	for i, source := range sources {
+		file := fileSet.AddFile(fmt.Sprintf("%d.js", i), len(source))
+		scanners = append(scanners, NewFileScanner(file, source))
+	}
+	b := scanners[0].Scan()
+	assert.Equal(t, "0.js:2:1", fileSet.Position(scanners[0].File().Pos(b.Start)).String())
+	assert.Equal(t, "1.js:1:1", fileSet.Position(scanners[1].File().Pos(0)).String())
+	scanners[2].Scan()
+	d := scanners[2].CurrentToken()
+	assert.Equal(t, "d", d.Literal)
+	assert.Equal(t, "2.js:3:1", fileSet.Position(scanners[2].File().Pos(d.Start)).String())
+	assert.Nil(t, fileSet.File(tt.NoPos))
+	assert.Nil(t, fileSet.File(tt.Pos(fileSet.Base())))
+	assert.Equal(t, "-", fileSet.Position(tt.NoPos).String())
+
+	assert.Panics(t, func() {
+		NewFileScanner(fileSet.AddFile("", 1), "ab")
+	})
+}
+
+func TestReaderScanner(t *testing.T) {
+	source := "// héllo\r\nlet a = 0x1F + .5e3 / b; /* 你好\n */ `x${ {a: '\\uD83D\\uDE00'} }y` /re[/]g/i c?.5:d >>>= \\u0061 // end"
+	readers := map[string]io.Reader{
+		"one byte": iotest.OneByteReader(strings.NewReader(source)),
+		"half":     iotest.HalfReader(strings.NewReader(source)),
+		"data err": iotest.DataErrReader(strings.NewReader(source)),
+	}
+	for name, reader := range readers {
+		expected := NewScanner(source)
+		actual := NewReaderScanner(nil, reader)
+		for {
+			assert.Equal(t, expected.CurrentToken(), actual.CurrentToken(), name)
+			if !expected.CurrentToken().Is(tt.EOF) {
+				expected.Scan()
+				actual.Scan()
+				continue
+			}
+			break
+		}
+		assert.Equal(t, expected.Diagnostics(), actual.Diagnostics(), name)
+		assert.Equal(t, expected.File().LineCount(), actual.File().LineCount(), name)
+	}
+}
+
+func TestReaderScannerBuffer(t *testing.T) {
+	line := "let a = 'abc' + b; // comment\n"
+	n := 10000
+	s := NewReaderScanner(nil, strings.NewReader(strings.Repeat(line, n)))
+	count := 0
+	for s.CurrentToken().Literal == "let" {
+		for i := 0; i < 7; i++ {
+			s.Scan()
+		}
+		assert.LessOrEqual(t, len(s.source), 2*readSize)
+		count++
+	}
+	assert.Equal(t, n, count)
+	assert.True(t, s.CurrentToken().Is(tt.EOF))
+	assert.Equal(t, fmt.Sprintf("%d:1", n+1), s.Position(s.CurrentToken().Start).String())
+}
+
+func TestReaderScannerError(t *testing.T) {
+	s := NewReaderScanner(nil, io.MultiReader(strings.NewReader("a b"), iotest.ErrReader(errors.New("broken"))))
+	assert.Equal(t, "a", s.CurrentToken().Literal)
+	assert.Equal(t, "b", s.Scan().Literal)
+	assert.True(t, s.Scan().Is(tt.Error))
+	assert.True(t, s.Scan().Is(tt.EOF))
+	assert.Equal(t, []Diagnostic{{Message: "read error: broken", Line: 1, Col: 4, Offset: 3}}, s.Diagnostics())
+}
+
+func TestReaderScannerFileSet(t *testing.T) {
+	fileSet := tt.NewFileSet()
+	fileSet.AddFile("a.js", 3)
+	source := "x\ny + 1"
+	s := NewReaderScanner(fileSet.AddFile("b.js", len(source)), iotest.OneByteReader(strings.NewReader(source)))
+	y := s.Scan()
+	assert.Equal(t, "y", y.Literal)
+	assert.Equal(t, "b.js:2:1", fileSet.Position(s.File().Pos(y.Start)).String())
+	for !s.CurrentToken().Is(tt.EOF) {
+		s.Scan()
+	}
+	assert.Empty(t, s.Diagnostics())
+
+	s = NewReaderScanner(fileSet.AddFile("c.js", 3), strings.NewReader("a b c"))
+	assert.Equal(t, "b", s.Scan().Literal)
+	assert.True(t, s.Scan().Is(tt.Error))
+	assert.True(t, s.Scan().Is(tt.EOF))
+	assert.Equal(t, []Diagnostic{{Message: "read error: source is longer than file size 3", Line: 1, Col: 4, Offset: 3}}, s.Diagnostics())
+}
+
+func TestScannerInternedNames(t *testing.T) {
+	source := "name + café + name + nam\\u0065 + café"
+	for _, s := range []*Scanner{NewScanner(source), NewReaderScanner(nil, iotest.OneByteReader(strings.NewReader(source)))} {
+		var names []string
+		for token := s.CurrentToken(); !token.Is(tt.EOF); token = s.Scan() {
+			if token.Is(tt.Identifier) {
+				names = append(names, token.Literal)
+			}
+		}
+		assert.Equal(t, []string{"name", "café", "name", "name", "café"}, names)
+		assert.Same(t, unsafe.StringData(names[0]), unsafe.StringData(names[2]))
+		assert.Same(t, unsafe.StringData(names[0]), unsafe.StringData(names[3]))
+		assert.Same(t, unsafe.StringData(names[1]), unsafe.StringData(names[4]))
+	}
+}
+
+func TestLookupKeyword(t *testing.T) {
+	for keyword, tokenType := range tt.Keywords {
+		actual, ok := tt.LookupKeyword(keyword)
+		assert.True(t, ok, keyword)
+		assert.Equal(t, tokenType, actual, keyword)
+		for _, name := range []string{keyword[1:], keyword + "_", strings.ToUpper(keyword), "x" + keyword[1:]} {
+			_, ok := tt.LookupKeyword(name)
+			_, isKeyword := tt.Keywords[name]
+			assert.Equal(t, isKeyword, ok, name)
+		}
+	}
+	_, ok := tt.LookupKeyword("")
+	assert.False(t, ok)
+}
+
+// benchmarkSource is a multi-megabyte program of typical bundle code
+var benchmarkSource = strings.Repeat(`// module
+function createStore(reducer, initialState) {
+	let state = initialState, listeners = [];
+	/* subscribe adds a listener */
+	const subscribe = function(listener) {
+		listeners = listeners.concat([listener]);
+		return function() { listeners = listeners.filter(function(l) { return l !== listener }) }
+	};
+	if (typeof reducer !== "function" && state != null) {
+		throw new Error('Expected the reducer to be a function, got ' + typeof reducer);
+	}
+	for (let i = 0; i < listeners.length; i++) { state = reducer(state, { type: "@@INIT", payload: 0x1F * 3.25e2 }) }
+	return { getState: function() { return state }, subscribe, message: `+"`state: ${state} of ${listeners.length}`"+` };
+}
+`, 8000)
+
+func BenchmarkScanner(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkSource)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := NewScanner(benchmarkSource)
+		for s.HasNextToken() {
+			s.Scan()
+		}
+	}
+}
+
+func BenchmarkReaderScanner(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkSource)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := NewReaderScanner(nil, strings.NewReader(benchmarkSource))
+		for s.HasNextToken() {
+			s.Scan()
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
 
-func GetBuiltinByName(name string) *Builtin {
+func GetBuiltinByName(name string) Object {
 	for _, def := range Builtins {
 		if def.Name == name {
 			return def.Builtin
@@ -12,8 +16,9 @@ func GetBuiltinByName(name string) *Builtin {
 }
 
 var Builtins = []struct {
-	Name    string
-	Builtin *Builtin
+	Name string
+	// Builtin is a *Builtin function or an object of constants like Symbol
+	Builtin Object
 }{
 	{
 		"len",
@@ -31,6 +36,21 @@ var Builtins = []struct {
 			}
 		}},
 	},
+	{
+		"Symbol",
+		newNamespace(map[string]Object{
+			"iterator": SymbolIterator,
+		}),
+	},
+}
+
+// newNamespace returns an object of properties, in sorted order of names
+func newNamespace(properties map[string]Object) *ObjectObject {
+	o := NewObject()
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		o.Set(&StringObject{Value: name}, properties[name])
+	}
+	return o
 }
 
 func newError(format string, a ...interface{}) *Error {
@@ -3,6 +3,12 @@ package object
 import (
 	"github.com/Seeingu/coldmoon/code"
 	"hash/fnv"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 //go:generate stringer -type Type -trimprefix type
@@ -16,6 +22,13 @@ const (
 	TypeObject
 	TypeCompiledFunction
 	TypeClosure
+	TypeNumber
+	TypeRegExp
+	TypeSymbol
+	TypeIterator
+	TypeJumpTable
+	TypeCell
+	TypePromise
 )
 
 type Object interface {
@@ -30,9 +43,27 @@ type Integer struct {
 
 func (i Integer) Type() Type { return TypeInt }
 
+func (i Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// NumberObject is a number that can't be represented as an Integer, e.g. 0.5, 1e300
 type NumberObject struct {
 	Object
-	value int
+	Value float64
+}
+
+func (n NumberObject) Type() Type { return TypeNumber }
+
+// maxSafeInteger is Number.MAX_SAFE_INTEGER
+const maxSafeInteger = 1<<53 - 1
+
+// NewNumber returns an Integer if value is a safe integer, otherwise a NumberObject
+func NewNumber(value float64) Object {
+	if value == math.Trunc(value) && math.Abs(value) <= maxSafeInteger && !(value == 0 && math.Signbit(value)) {
+		return &Integer{Value: int64(value)}
+	}
+	return &NumberObject{Value: value}
 }
 
 type StringObject struct {
@@ -49,6 +80,28 @@ func (s StringObject) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// SymbolObject is a unique property key, symbols with the same Description are different
+type SymbolObject struct {
+	Object
+	Hashable
+	Description string
+	id          uint64
+}
+
+var symbolCount atomic.Uint64
+
+func NewSymbol(description string) *SymbolObject {
+	return &SymbolObject{Description: description, id: symbolCount.Add(1)}
+}
+
+func (s *SymbolObject) Type() Type { return TypeSymbol }
+func (s *SymbolObject) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Value: s.id}
+}
+
+// SymbolIterator is Symbol.iterator, the key of the method returning an iterator of an object
+var SymbolIterator = NewSymbol("Symbol.iterator")
+
 type BooleanObject struct {
 	Object
 	Value bool
@@ -59,6 +112,10 @@ func (b BooleanObject) Type() Type { return TypeBool }
 type ArrayObject struct {
 	Object
 	Elements []Object
+	// Properties are named properties besides elements, e.g. raw of a template strings array
+	Properties map[HashKey]HashPair
+	// Frozen arrays can't be modified
+	Frozen bool
 }
 
 func (a ArrayObject) Type() Type { return TypeArray }
@@ -82,6 +139,38 @@ type FunctionObject struct {
 	args      map[string]Object
 }
 
+// RegExpObject is created by a regular expression literal,
+// Regexp is shared by objects of the same literal
+type RegExpObject struct {
+	Object
+	Pattern   string
+	Flags     string
+	Regexp    *regexp.Regexp
+	LastIndex int
+}
+
+func (r *RegExpObject) Type() Type { return TypeRegExp }
+
+// NewRegExp compiles pattern, the i, m and s flags are supported by the regexp package,
+// other flags are kept in Flags only
+func NewRegExp(pattern string, flags string) (*RegExpObject, error) {
+	var goFlags string
+	for _, f := range flags {
+		if strings.ContainsRune("ims", f) {
+			goFlags += string(f)
+		}
+	}
+	source := pattern
+	if goFlags != "" {
+		source = "(?" + goFlags + ")" + pattern
+	}
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	return &RegExpObject{Pattern: pattern, Flags: flags, Regexp: re}, nil
+}
+
 type HashKey struct {
 	Type  Type
 	Value uint64
@@ -95,18 +184,112 @@ type HashPair struct {
 	Value Object
 }
 
+// PropertyKey is a StringObject or a SymbolObject
+type PropertyKey interface {
+	Object
+	Hashable
+}
+
 type ObjectObject struct {
 	Object
 	Pairs map[HashKey]HashPair
+	// Prototype is the object inheriting properties to this one, nil at the end of the chain
+	Prototype *ObjectObject
+	// keys of Pairs in insertion order
+	keys []HashKey
 }
 
 func (o ObjectObject) Type() Type { return TypeObject }
 
+func NewObject() *ObjectObject {
+	return &ObjectObject{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Get returns the value of key on o or its prototype chain
+func (o *ObjectObject) Get(key PropertyKey) (Object, bool) {
+	hashKey := key.HashKey()
+	for ; o != nil; o = o.Prototype {
+		if pair, ok := o.Pairs[hashKey]; ok {
+			return pair.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set adds or replaces an own property, a replaced property keeps its position
+func (o *ObjectObject) Set(key PropertyKey, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := o.Pairs[hashKey]; !ok {
+		o.keys = append(o.keys, hashKey)
+	}
+	o.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+// Keys returns the own property keys in the order of OrdinaryOwnPropertyKeys:
+// array indices ascending, other strings in insertion order, then symbols in insertion order
+func (o *ObjectObject) Keys() []PropertyKey {
+	var indices []uint32
+	var indexKeys = map[uint32]PropertyKey{}
+	var names, symbols []PropertyKey
+	for _, hashKey := range o.keys {
+		key := o.Pairs[hashKey].Key.(PropertyKey)
+		switch key := key.(type) {
+		case *StringObject:
+			if index, ok := ArrayIndex(key.Value); ok {
+				indices = append(indices, index)
+				indexKeys[index] = key
+			} else {
+				names = append(names, key)
+			}
+		default:
+			symbols = append(symbols, key)
+		}
+	}
+	slices.Sort(indices)
+	keys := make([]PropertyKey, 0, len(o.keys))
+	for _, index := range indices {
+		keys = append(keys, indexKeys[index])
+	}
+	keys = append(keys, names...)
+	return append(keys, symbols...)
+}
+
+// ArrayIndex returns the index of a canonical array index string, e.g. "1" but not "01" or "4294967295"
+func ArrayIndex(key string) (uint32, bool) {
+	if key == "" || len(key) > 1 && key[0] == '0' {
+		return 0, false
+	}
+	index, err := strconv.ParseUint(key, 10, 32)
+	if err != nil || index == math.MaxUint32 {
+		return 0, false
+	}
+	return uint32(index), true
+}
+
 type CompiledFunction struct {
 	Object
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	// Rest collects the arguments after NumParameters in an array, the local after the parameters
+	Rest bool
+	// Handlers are the exception handlers of try statements, inner ones first
+	Handlers []Handler
+	// Arrow functions take this of the function creating them
+	Arrow bool
+	// Arguments is true if the function uses its arguments object, which is built for each call then
+	Arguments bool
+	// Async functions run until an await and return a promise of their result
+	Async bool
+}
+
+// Handler catches exceptions thrown by the instructions in [Start, End),
+// it cuts the stack to StackDepth values above the locals, pushes the exception and jumps to Target
+type Handler struct {
+	Start      int
+	End        int
+	Target     int
+	StackDepth int
 }
 
 func (c *CompiledFunction) Type() Type { return TypeCompiledFunction }
@@ -132,6 +315,64 @@ type Closure struct {
 	Object
 	Fn   *CompiledFunction
 	Free []Object
+	// This is the this of an arrow function
+	This Object
 }
 
 func (c *Closure) Type() Type { return TypeClosure }
+
+// Iterator is the iterator record of a for-in or for-of loop, it is never a value of the language.
+// Built-in iterables are iterated by Step, objects by the Next method of their iterator object
+type Iterator struct {
+	Object
+	// Step returns the next value, false when done
+	Step func() (Object, bool)
+	// Target is the iterator object of the iterator protocol, Next is its next method
+	Target Object
+	Next   Object
+	// Done is set when destructuring has taken all values, a done iterator is not closed
+	Done bool
+}
+
+func (i *Iterator) Type() Type { return TypeIterator }
+
+// JumpTable is the constant of a switch whose cases are all integers or strings,
+// it maps a case value to the offset of its clause
+type JumpTable struct {
+	Object
+	Targets map[HashKey]int
+	// Default is the offset of the default clause, or the end of the switch
+	Default int
+}
+
+func (j *JumpTable) Type() Type { return TypeJumpTable }
+
+// Cell holds a variable which closures capture and which is assigned, it is never a value of the language.
+// The closures and the function declaring the variable share the cell, Value is nil before initialization
+type Cell struct {
+	Object
+	Value Object
+}
+
+func (c *Cell) Type() Type { return TypeCell }
+
+// PromiseState is pending until a promise is settled, once
+type PromiseState int
+
+const (
+	PromisePending PromiseState = iota
+	PromiseFulfilled
+	PromiseRejected
+)
+
+// PromiseObject is the eventual result of an async function call
+type PromiseObject struct {
+	Object
+	State PromiseState
+	// Value is the fulfillment value or the rejection reason
+	Value Object
+	// Reactions are called once the promise is settled
+	Reactions []func()
+}
+
+func (p *PromiseObject) Type() Type { return TypePromise }
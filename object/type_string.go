@@ -15,11 +15,18 @@ func _() {
 	_ = x[TypeObject-4]
 	_ = x[TypeCompiledFunction-5]
 	_ = x[TypeClosure-6]
+	_ = x[TypeNumber-7]
+	_ = x[TypeRegExp-8]
+	_ = x[TypeSymbol-9]
+	_ = x[TypeIterator-10]
+	_ = x[TypeJumpTable-11]
+	_ = x[TypeCell-12]
+	_ = x[TypePromise-13]
 }
 
-const _Type_name = "TypeIntTypeBoolTypeStringTypeArrayTypeObjectTypeCompiledFunctionTypeClosure"
+const _Type_name = "TypeIntTypeBoolTypeStringTypeArrayTypeObjectTypeCompiledFunctionTypeClosureTypeNumberTypeRegExpTypeSymbolTypeIteratorTypeJumpTableTypeCellTypePromise"
 
-var _Type_index = [...]uint8{0, 7, 15, 25, 34, 44, 64, 75}
+var _Type_index = [...]uint8{0, 7, 15, 25, 34, 44, 64, 75, 85, 95, 105, 117, 130, 138, 149}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_Type_index)-1) {
@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"fmt"
+	t "github.com/Seeingu/coldmoon/token"
+)
+
+// ParseError is a syntax error at Actual token, or a scan error without Actual
+type ParseError struct {
+	// Pos locates the error in the FileSet of the scanned File
+	Pos t.Pos
+	// Line and Col start with 1, Col counts code points
+	Line uint
+	Col  uint
+	// Expected are the token types allowed instead of Actual, empty if any other token is wrong too
+	Expected []t.TokenType
+	Actual   t.Token
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, col %d: %s", e.Line, e.Col, e.Message)
+}
+
+// bailout unwinds the statement being parsed after a ParseError, see parseStatementOrRecover
+type bailout struct{}
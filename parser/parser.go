@@ -1,11 +1,15 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Seeingu/coldmoon/ast"
 	"github.com/Seeingu/coldmoon/lexer"
 	t "github.com/Seeingu/coldmoon/token"
+	"math/big"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -15,7 +19,14 @@ type (
 
 type Parser struct {
 	scanner *lexer.Scanner
-	errors  []string
+	errors  []*ParseError
+	// depth counts open brackets up to current token, recovery skips to the depth of the failed statement
+	depth int
+	// coverInitializers are the = of shorthand properties {a = 1} not reinterpreted as patterns yet,
+	// they are errors at the end of their statement
+	coverInitializers []t.Token
+	// async is true in the body of an async function, where await is an operator
+	async bool
 
 	prefixParseFns map[t.TokenType]prefixParseFn
 	infixParseFns  map[t.TokenType]infixParseFn
@@ -26,18 +37,26 @@ func New(l *lexer.Scanner) *Parser {
 		scanner: l,
 	}
 	p.prefixParseFns = make(map[t.TokenType]prefixParseFn)
-	p.registerPrefix(t.Identifier, p.parseIdentifier)
-	p.registerPrefix(t.Number, p.parseIntegerLiteral)
+	p.registerPrefix(t.Identifier, p.parseIdentifierOrArrow)
+	p.registerPrefix(t.Number, p.parseNumericLiteral)
 	p.registerPrefix(t.String, p.parseStringLiteral)
+	p.registerPrefix(t.RegExp, p.parseRegExpLiteral)
+	p.registerPrefix(t.NoSubstitutionTemplate, p.parseTemplateLiteral)
+	p.registerPrefix(t.TemplateHead, p.parseTemplateLiteral)
 	p.registerPrefix(t.True, p.parseBoolean)
 	p.registerPrefix(t.False, p.parseBoolean)
+	p.registerPrefix(t.Null, p.parseNullLiteral)
+	p.registerPrefix(t.Undefined, p.parseUndefinedLiteral)
 	p.registerPrefix(t.LeftParenthesis, p.parseGroupedExpression)
 	p.registerPrefix(t.LeftSquareBracket, p.parseArrayLiteral)
 	p.registerPrefix(t.LeftBracket, p.parseObjectLiteral)
 	p.registerPrefix(t.Minus, p.parsePrefixExpression)
 	p.registerPrefix(t.Bang, p.parsePrefixExpression)
+	p.registerPrefix(t.PlusPlus, p.parseUpdateExpression)
+	p.registerPrefix(t.MinusMinus, p.parseUpdateExpression)
 	p.registerPrefix(t.If, p.parseIfExpression)
 	p.registerPrefix(t.Function, p.parseFunctionLiteral)
+	p.registerPrefix(t.This, p.parseThisExpression)
 
 	p.infixParseFns = make(map[t.TokenType]infixParseFn)
 	p.registerInfix(t.Plus, p.parseInfixExpression)
@@ -50,26 +69,76 @@ func New(l *lexer.Scanner) *Parser {
 	p.registerInfix(t.GreaterEqual, p.parseInfixExpression)
 	p.registerInfix(t.LessEqual, p.parseInfixExpression)
 	p.registerInfix(t.BangEqual, p.parseInfixExpression)
+	p.registerInfix(t.Percent, p.parseInfixExpression)
+	p.registerInfix(t.StarStar, p.parseInfixExpression)
+	p.registerInfix(t.LessLess, p.parseInfixExpression)
+	p.registerInfix(t.GreaterGreater, p.parseInfixExpression)
+	p.registerInfix(t.GreaterGreaterGreater, p.parseInfixExpression)
+	p.registerInfix(t.Ampersand, p.parseInfixExpression)
+	p.registerInfix(t.Bar, p.parseInfixExpression)
+	p.registerInfix(t.Caret, p.parseInfixExpression)
+	p.registerInfix(t.AmpersandAmpersand, p.parseLogicalExpression)
+	p.registerInfix(t.BarBar, p.parseLogicalExpression)
+	p.registerInfix(t.QuestionQuestion, p.parseLogicalExpression)
+	p.registerInfix(t.PlusPlus, p.parsePostfixExpression)
+	p.registerInfix(t.MinusMinus, p.parsePostfixExpression)
 	p.registerInfix(t.LeftSquareBracket, p.parseIndexExpression)
 	p.registerInfix(t.LeftParenthesis, p.parseCallExpression)
+	p.registerInfix(t.Dot, p.parseMemberExpression)
+	p.registerInfix(t.QuestionDot, p.parseOptionalChain)
+	p.registerInfix(t.NoSubstitutionTemplate, p.parseTaggedTemplateExpression)
+	p.registerInfix(t.TemplateHead, p.parseTaggedTemplateExpression)
+	for _, operator := range assignmentOperators {
+		p.registerInfix(operator, p.parseAssignmentExpression)
+	}
+	p.countBracket()
 	return p
 }
 
+// Errors returns messages of ParseErrors
 func (p *Parser) Errors() []string {
-	return p.errors
+	var messages []string
+	for _, e := range p.ParseErrors() {
+		messages = append(messages, e.Error())
+	}
+	return messages
+}
+
+// ParseErrors returns scan errors and syntax errors in source order
+func (p *Parser) ParseErrors() []*ParseError {
+	file := p.scanner.File()
+	var errors []*ParseError
+	for _, d := range p.scanner.Diagnostics() {
+		errors = append(errors, &ParseError{
+			Pos:     file.Pos(d.Offset),
+			Line:    d.Line,
+			Col:     d.Col,
+			Message: d.Message,
+		})
+	}
+	errors = append(errors, p.errors...)
+	slices.SortStableFunc(errors, func(a, b *ParseError) int {
+		return int(a.Pos - b.Pos)
+	})
+	return errors
 }
 
+// ParseProgram parses every statement, a statement with a syntax error is skipped
+// so errors in the rest of source are reported too
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{
 		Statements: []ast.Statement{},
 	}
 
+	var prologue directivePrologue
 	for !p.currentToken().Is(t.EOF) {
-		stmt := p.parseStatement()
+		first := p.currentToken()
+		stmt := p.parseStatementOrRecover()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
-		p.scanner.Scan()
+		p.directive(&prologue, first, stmt)
+		p.next()
 	}
 	return program
 
@@ -82,76 +151,488 @@ type precedenceType int
 const (
 	_ precedenceType = iota
 	PLowest
+	PAssign
+	PNullish
+	PLogicalOr
+	PLogicalAnd
+	PBitwiseOr
+	PBitwiseXor
+	PBitwiseAnd
 	PEquals
 	PLessOrGreater
+	PShift
 	PSum
 	PProduct
+	PExponent
 	PPrefix
+	PPostfix
 	PCall
 	PIndex
 )
 
 var precedences = map[t.TokenType]precedenceType{
-	t.EqualEqual:        PEquals,
-	t.EqualEqualEqual:   PEquals,
-	t.BangEqual:         PEquals,
-	t.LessEqual:         PLessOrGreater,
-	t.Less:              PLessOrGreater,
-	t.Greater:           PLessOrGreater,
-	t.Plus:              PSum,
-	t.Minus:             PSum,
-	t.Star:              PProduct,
-	t.Slash:             PProduct,
-	t.LeftParenthesis:   PCall,
-	t.LeftSquareBracket: PIndex,
+	t.Equal:                      PAssign,
+	t.PlusEqual:                  PAssign,
+	t.MinusEqual:                 PAssign,
+	t.StarEqual:                  PAssign,
+	t.SlashEqual:                 PAssign,
+	t.PercentEqual:               PAssign,
+	t.StarStarEqual:              PAssign,
+	t.LessLessEqual:              PAssign,
+	t.GreaterGreaterEqual:        PAssign,
+	t.GreaterGreaterGreaterEqual: PAssign,
+	t.AmpersandEqual:             PAssign,
+	t.BarEqual:                   PAssign,
+	t.CaretEqual:                 PAssign,
+	t.AmpersandAmpersandEqual:    PAssign,
+	t.BarBarEqual:                PAssign,
+	t.QuestionQuestionEqual:      PAssign,
+	t.QuestionQuestion:           PNullish,
+	t.BarBar:                     PLogicalOr,
+	t.AmpersandAmpersand:         PLogicalAnd,
+	t.Bar:                        PBitwiseOr,
+	t.Caret:                      PBitwiseXor,
+	t.Ampersand:                  PBitwiseAnd,
+	t.EqualEqual:                 PEquals,
+	t.EqualEqualEqual:            PEquals,
+	t.BangEqual:                  PEquals,
+	t.LessEqual:                  PLessOrGreater,
+	t.Less:                       PLessOrGreater,
+	t.Greater:                    PLessOrGreater,
+	t.LessLess:                   PShift,
+	t.GreaterGreater:             PShift,
+	t.GreaterGreaterGreater:      PShift,
+	t.Plus:                       PSum,
+	t.Minus:                      PSum,
+	t.Star:                       PProduct,
+	t.Slash:                      PProduct,
+	t.Percent:                    PProduct,
+	t.StarStar:                   PExponent,
+	t.PlusPlus:                   PPostfix,
+	t.MinusMinus:                 PPostfix,
+	t.LeftParenthesis:            PCall,
+	t.LeftSquareBracket:          PIndex,
+	t.Dot:                        PIndex,
+	t.QuestionDot:                PIndex,
+
+	t.NoSubstitutionTemplate: PCall,
+	t.TemplateHead:           PCall,
+}
+
+// assignmentOperators are = and the compound assignment operators
+var assignmentOperators = []t.TokenType{
+	t.Equal, t.PlusEqual, t.MinusEqual, t.StarEqual, t.SlashEqual, t.PercentEqual, t.StarStarEqual,
+	t.LessLessEqual, t.GreaterGreaterEqual, t.GreaterGreaterGreaterEqual, t.AmpersandEqual, t.BarEqual, t.CaretEqual,
+	t.AmpersandAmpersandEqual, t.BarBarEqual, t.QuestionQuestionEqual,
 }
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currentToken().TokenType {
-	case t.Let:
+	case t.Const, t.Var:
 		return p.parseLetStatement()
 	case t.Return:
 		return p.parseReturnStatement()
+	case t.While:
+		return p.parseWhileStatement()
+	case t.Do:
+		return p.parseDoWhileStatement()
+	case t.For:
+		return p.parseForStatement()
+	case t.Switch:
+		return p.parseSwitchStatement()
+	case t.Throw:
+		return p.parseThrowStatement()
+	case t.Try:
+		return p.parseTryStatement()
+	case t.Break:
+		return p.parseBreakStatement()
+	case t.Continue:
+		return p.parseContinueStatement()
+	case t.LeftBracket:
+		// { starts a block, an object literal statement needs parentheses
+		return p.parseBlockStatement()
+	case t.Semicolon:
+		// empty statement
+		return nil
 	default:
+		if p.isLetDeclaration() {
+			return p.parseLetStatement()
+		}
+		if p.currentToken().Is(t.Identifier) && p.nextToken().Is(t.Colon) {
+			return p.parseLabeledStatement()
+		}
 		return p.parseExpressionStatement()
 	}
 }
 
+// parseStatementOrRecover returns nil after a syntax error in the statement,
+// current token is then the last one skipped by synchronize
+func (p *Parser) parseStatementOrRecover() (stmt ast.Statement) {
+	depth := p.depth
+	start := p.currentToken().Start
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			stmt = nil
+			p.dropCoverInitializers(start)
+			p.synchronize(depth)
+		}
+	}()
+	stmt = p.parseStatement()
+	p.checkCoverInitializers(start)
+	return stmt
+}
+
+// checkCoverInitializers fails on a shorthand property initializer of the statement starting at start,
+// the ones before belong to enclosing statements
+func (p *Parser) checkCoverInitializers(start int) {
+	for _, token := range p.coverInitializers {
+		if token.Start >= start {
+			p.fail(token, nil, "invalid shorthand property initializer")
+		}
+	}
+}
+
+// dropCoverInitializers forgets the shorthand property initializers after start
+func (p *Parser) dropCoverInitializers(start int) {
+	for i, token := range p.coverInitializers {
+		if token.Start >= start {
+			p.coverInitializers = p.coverInitializers[:i]
+			return
+		}
+	}
+}
+
+// synchronize skips tokens to a statement boundary at depth:
+// after a ; or before a statement keyword, a } or EOF.
+// Keywords which never start an expression also end unclosed ( and [, e.g. let in (1 + let a = 1,
+// but not inside blocks skipped here, e.g. return in f(function() { return 1 })
+func (p *Parser) synchronize(depth int) {
+	blocks := 0
+	for !p.nextToken().Is(t.EOF) {
+		next := p.nextToken()
+		if p.depth <= depth && (p.currentToken().Is(t.Semicolon) || next.Is(t.RightBracket) || next.IsOneOf(statementKeywords)) {
+			break
+		}
+		// let is an identifier, on a new line it most likely starts a declaration
+		if blocks == 0 && (next.IsOneOf(statementOnlyKeywords) || next.NewLineBefore && isContextualKeyword(next, "let")) {
+			break
+		}
+		p.next()
+		switch p.currentToken().TokenType {
+		case t.LeftBracket:
+			blocks++
+		case t.RightBracket:
+			if blocks > 0 {
+				blocks--
+			}
+		}
+	}
+	p.depth = depth
+}
+
+// statementOnlyKeywords start a statement but never an expression
+var statementOnlyKeywords = []t.TokenType{
+	t.Var, t.Const, t.If, t.For, t.While, t.Do, t.Return,
+	t.Switch, t.Try, t.Throw, t.Break, t.Continue, t.Debugger, t.Export,
+}
+
+var statementKeywords = append([]t.TokenType{t.Function, t.Class, t.Import}, statementOnlyKeywords...)
+
+// isLetDeclaration reports whether the Identifier let at current token starts a declaration,
+// let is an identifier everywhere else
+func (p *Parser) isLetDeclaration() bool {
+	return isContextualKeyword(p.currentToken(), "let") &&
+		p.nextToken().IsOneOf([]t.TokenType{t.Identifier, t.LeftSquareBracket, t.LeftBracket})
+}
+
+// isContextualKeyword reports whether token is the Identifier name, contextual keywords are not scanned as keywords
+func isContextualKeyword(token t.Token, name string) bool {
+	return token.Is(t.Identifier) && token.Literal == name
+}
+
+// parseLetStatement parses let, const and var declarations, only const requires an initializer
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.currentToken()}
+	stmt := p.parseLetDeclaration()
+	p.expectInitializer(stmt)
+	p.expectSemicolon()
+	return stmt
+}
 
-	if !p.expectNextToken(t.Identifier) {
-		return nil
+// parseLetDeclaration parses a declaration without the ending ;
+func (p *Parser) parseLetDeclaration() *ast.LetStatement {
+	stmt := &ast.LetStatement{Token: p.currentToken()}
+	if stmt.Token.Is(t.Identifier) {
+		stmt.Token.TokenType = t.Let
 	}
 
-	stmt.Name = &ast.IdentifierExpression{Token: p.currentToken(), Value: p.currentToken().Literal}
+	if p.nextToken().IsOneOf([]t.TokenType{t.LeftSquareBracket, t.LeftBracket}) {
+		p.next()
+		stmt.Pattern = p.parseBindingPattern()
+	} else {
+		p.expectNextToken(t.Identifier)
+		stmt.Name = &ast.IdentifierExpression{Token: p.currentToken(), Value: p.currentToken().Literal}
+	}
 
-	if !p.expectNextToken(t.Equal) {
-		return nil
+	if !p.matchNextToken(t.Equal) {
+		return stmt
 	}
-	p.scanner.Scan()
+	p.next()
 
 	stmt.Value = p.parseExpression(PLowest)
 
-	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
 		fn.Name = stmt.Name
 	}
 
-	if p.nextToken().Is(t.Semicolon) {
-		p.scanner.Scan()
+	return stmt
+}
+
+// expectInitializer fails for const or a pattern without initializer, next token is the one after the declaration
+func (p *Parser) expectInitializer(stmt *ast.LetStatement) {
+	switch {
+	case stmt.Value != nil:
+	case stmt.Pattern != nil:
+		p.fail(p.nextToken(), []t.TokenType{t.Equal}, "missing initializer in destructuring declaration")
+	case stmt.Token.Is(t.Const):
+		p.fail(p.nextToken(), []t.TokenType{t.Equal}, "missing initializer in const declaration")
+	}
+}
+
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.currentToken()}
+
+	p.expectNextToken(t.LeftParenthesis)
+	p.next()
+	stmt.Condition = p.parseExpression(PLowest)
+	p.expectNextToken(t.RightParenthesis)
+
+	p.next()
+	stmt.Body = p.parseStatement()
+	return stmt
+}
+
+func (p *Parser) parseSwitchStatement() *ast.SwitchStatement {
+	stmt := &ast.SwitchStatement{Token: p.currentToken()}
+
+	p.expectNextToken(t.LeftParenthesis)
+	p.next()
+	stmt.Discriminant = p.parseExpression(PLowest)
+	p.expectNextToken(t.RightParenthesis)
+	p.expectNextToken(t.LeftBracket)
+
+	p.next()
+	hasDefault := false
+	for !p.currentToken().Is(t.RightBracket) {
+		clause := &ast.SwitchCase{Token: p.currentToken()}
+		switch p.currentToken().TokenType {
+		case t.Case:
+			p.next()
+			clause.Test = p.parseExpression(PLowest)
+		case t.Default:
+			if hasDefault {
+				p.fail(p.currentToken(), nil, "more than one default clause in switch statement")
+			}
+			hasDefault = true
+		default:
+			p.unexpected(p.currentToken(), t.Case, t.Default, t.RightBracket)
+		}
+		p.expectNextToken(t.Colon)
+
+		p.next()
+		for !p.currentToken().IsOneOf(switchClauseEnds) {
+			if p.currentToken().Is(t.EOF) {
+				p.unexpected(p.currentToken(), t.RightBracket)
+			}
+			s := p.parseStatementOrRecover()
+			if s != nil {
+				clause.Consequent = append(clause.Consequent, s)
+			}
+			p.next()
+		}
+		stmt.Cases = append(stmt.Cases, clause)
+	}
+	return stmt
+}
+
+var switchClauseEnds = []t.TokenType{t.Case, t.Default, t.RightBracket}
+
+func (p *Parser) parseDoWhileStatement() *ast.DoWhileStatement {
+	stmt := &ast.DoWhileStatement{Token: p.currentToken()}
+
+	p.next()
+	stmt.Body = p.parseStatement()
+
+	p.expectNextToken(t.While)
+	p.expectNextToken(t.LeftParenthesis)
+	p.next()
+	stmt.Condition = p.parseExpression(PLowest)
+	p.expectNextToken(t.RightParenthesis)
+
+	// a ; is inserted after do-while even without a line terminator
+	p.matchNextToken(t.Semicolon)
+	return stmt
+}
+
+// for (init; condition; update) body, for (left in right) body or for (left of right) body
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.currentToken()}
+
+	p.expectNextToken(t.LeftParenthesis)
+	p.next()
+	switch {
+	case p.currentToken().Is(t.Semicolon):
+		// no init
+	case p.currentToken().IsOneOf([]t.TokenType{t.Const, t.Var}) || p.isLetDeclaration():
+		init := p.parseLetDeclaration()
+		if init.Value == nil && p.isForInOf() {
+			return p.parseForInOfStatement(stmt.Token, init)
+		}
+		p.expectInitializer(init)
+		stmt.Init = init
+		p.expectNextToken(t.Semicolon)
+	default:
+		init := p.parseExpression(PLowest)
+		if p.isForInOf() {
+			switch init.(type) {
+			case *ast.IdentifierExpression:
+			case *ast.ArrayLiteralExpression, *ast.ObjectLiteralExpression:
+				init = p.toPattern(init)
+			default:
+				p.fail(p.nextToken(), nil, "invalid left-hand side in for-in or for-of")
+			}
+			return p.parseForInOfStatement(stmt.Token, &ast.ExpressionStatement{Expression: init})
+		}
+		stmt.Init = &ast.ExpressionStatement{Expression: init}
+		p.expectNextToken(t.Semicolon)
+	}
+
+	if !p.nextToken().Is(t.Semicolon) {
+		p.next()
+		stmt.Condition = p.parseExpression(PLowest)
+	}
+	p.expectNextToken(t.Semicolon)
+
+	if !p.nextToken().Is(t.RightParenthesis) {
+		p.next()
+		stmt.Update = p.parseExpression(PLowest)
+	}
+	p.expectNextToken(t.RightParenthesis)
+
+	p.next()
+	stmt.Body = p.parseStatement()
+	return stmt
+}
+
+// isForInOf reports whether next token is in or of after the left side in a for head
+func (p *Parser) isForInOf() bool {
+	next := p.nextToken()
+	return next.Is(t.In) || next.Is(t.Identifier) && next.Literal == "of"
+}
+
+// startToken: end of left
+// endToken: end of body
+func (p *Parser) parseForInOfStatement(token t.Token, left ast.Statement) ast.Statement {
+	p.next()
+	of := p.currentToken().Is(t.Identifier)
+	p.next()
+	right := p.parseExpression(PLowest)
+	p.expectNextToken(t.RightParenthesis)
+
+	p.next()
+	body := p.parseStatement()
+	if of {
+		return &ast.ForOfStatement{Token: token, Left: left, Right: right, Body: body}
 	}
+	return &ast.ForInStatement{Token: token, Left: left, Right: right, Body: body}
+}
 
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.currentToken()}
+	stmt.Label = p.parseJumpLabel()
+	p.expectSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.currentToken()}
+	stmt.Label = p.parseJumpLabel()
+	p.expectSemicolon()
+	return stmt
+}
+
+// parseJumpLabel parses the optional label of break and continue,
+// break [no LineTerminator here] LabelIdentifier
+func (p *Parser) parseJumpLabel() *ast.IdentifierExpression {
+	if !p.nextToken().Is(t.Identifier) || p.nextToken().NewLineBefore {
+		return nil
+	}
+	p.next()
+	return p.parseIdentifier().(*ast.IdentifierExpression)
+}
+
+// label: body
+func (p *Parser) parseLabeledStatement() *ast.LabeledStatement {
+	stmt := &ast.LabeledStatement{Token: p.currentToken()}
+	stmt.Label = p.parseIdentifier().(*ast.IdentifierExpression)
+
+	// skip label and :
+	p.next()
+	p.next()
+	stmt.Body = p.parseStatement()
 	return stmt
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.currentToken()}
-	// skip return
-	p.scanner.Scan()
+	// return [no LineTerminator here] Expression
+	if !p.canInsertSemicolon() {
+		// skip return
+		p.next()
+		stmt.ReturnValue = p.parseExpression(PLowest)
+	}
+
+	p.expectSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.currentToken()}
+	// throw [no LineTerminator here] Expression
+	if p.nextToken().NewLineBefore {
+		p.fail(p.nextToken(), nil, "illegal newline after throw")
+	}
+	p.next()
+	stmt.Argument = p.parseExpression(PLowest)
+
+	p.expectSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	stmt := &ast.TryStatement{Token: p.currentToken()}
+
+	p.expectNextToken(t.LeftBracket)
+	stmt.Block = p.parseBlockStatement()
 
-	stmt.ReturnValue = p.parseExpression(PLowest)
-	if p.nextToken().Is(t.Semicolon) {
-		p.scanner.Scan()
+	if p.matchNextToken(t.Catch) {
+		// the binding is optional, catch { ... }
+		if p.matchNextToken(t.LeftParenthesis) {
+			p.expectNextToken(t.Identifier)
+			stmt.Param = p.parseIdentifier().(*ast.IdentifierExpression)
+			p.expectNextToken(t.RightParenthesis)
+		}
+		p.expectNextToken(t.LeftBracket)
+		stmt.Handler = p.parseBlockStatement()
+	}
+	if p.matchNextToken(t.Finally) {
+		p.expectNextToken(t.LeftBracket)
+		stmt.Finalizer = p.parseBlockStatement()
+	}
+	if stmt.Handler == nil && stmt.Finalizer == nil {
+		p.unexpected(p.nextToken(), t.Catch, t.Finally)
 	}
 	return stmt
 }
@@ -160,26 +641,43 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{}
 	stmt.Expression = p.parseExpression(PLowest)
 
-	if p.nextToken().Is(t.Semicolon) {
-		p.scanner.Scan()
+	switch e := stmt.Expression.(type) {
+	case *ast.IfExpression:
+		// if statements and function declarations end with a block, no ; needed
+		p.matchNextToken(t.Semicolon)
+	case *ast.FunctionLiteral:
+		if e.Name == nil {
+			p.expectSemicolon()
+		} else {
+			p.matchNextToken(t.Semicolon)
+		}
+	default:
+		p.expectSemicolon()
 	}
 	return stmt
 }
 
 func (p *Parser) parseExpression(precedence precedenceType) ast.Expression {
+	if p.currentToken().Is(t.Error) {
+		// already reported by the scanner
+		panic(bailout{})
+	}
 	prefixFn := p.prefixParseFns[p.currentToken().TokenType]
 	if prefixFn == nil {
-		p.noPrefixParseFnError(p.currentToken().TokenType)
-		return nil
+		p.fail(p.currentToken(), nil, fmt.Sprintf("unexpected %s, expected an expression", p.currentToken().TokenType))
 	}
 	leftExp := prefixFn()
 	for !p.nextToken().Is(t.Semicolon) && precedence < p.nextTokenPrecedence() {
+		if p.nextToken().IsOneOf(postfixOperators) && p.nextToken().NewLineBefore {
+			// LeftHandSideExpression [no LineTerminator here] ++
+			break
+		}
 		infix := p.infixParseFns[p.nextToken().TokenType]
 		if infix == nil {
 			return leftExp
 		}
 
-		p.scanner.Scan()
+		p.next()
 		leftExp = infix(leftExp)
 	}
 
@@ -196,16 +694,15 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseStringLiteral() ast.Expression {
 	literal := &ast.StringLiteral{Token: p.currentToken()}
 	literal.Value = p.currentToken().Literal
+	literal.Raw = p.currentToken().Raw
 	return literal
 }
 
-func (p *Parser) parseIntegerLiteral() ast.Expression {
-	literal := &ast.IntegerLiteral{}
-	value, err := strconv.ParseInt(p.currentToken().Literal, 0, 64)
+func (p *Parser) parseNumericLiteral() ast.Expression {
+	literal := &ast.NumericLiteral{Token: p.currentToken()}
+	value, err := numericValue(p.currentToken().Literal)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.currentToken().Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.fail(p.currentToken(), nil, fmt.Sprintf("could not parse %q as number", p.currentToken().Literal))
 	}
 	literal.Value = value
 
@@ -213,41 +710,134 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 }
 
+func (p *Parser) parseRegExpLiteral() ast.Expression {
+	literal := &ast.RegExpLiteral{Token: p.currentToken()}
+	// the last / ends the pattern, flags never contain /
+	source := p.currentToken().Literal
+	end := strings.LastIndex(source, "/")
+	literal.Pattern = source[1:end]
+	literal.Flags = source[end+1:]
+	return literal
+}
+
+// startToken: NoSubstitutionTemplate or TemplateHead
+// endToken: NoSubstitutionTemplate or TemplateTail
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	literal := &ast.TemplateLiteral{Token: p.currentToken()}
+	literal.Quasis = append(literal.Quasis, p.parseStringLiteral().(*ast.StringLiteral))
+
+	for !p.currentToken().IsOneOf([]t.TokenType{t.NoSubstitutionTemplate, t.TemplateTail}) {
+		// skip TemplateHead or TemplateMiddle
+		p.next()
+		literal.Expressions = append(literal.Expressions, p.parseExpression(PLowest))
+
+		if !p.nextToken().IsOneOf([]t.TokenType{t.TemplateMiddle, t.TemplateTail}) {
+			p.unexpected(p.nextToken(), t.TemplateMiddle, t.TemplateTail)
+		}
+		p.next()
+		literal.Quasis = append(literal.Quasis, p.parseStringLiteral().(*ast.StringLiteral))
+	}
+
+	return literal
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	literal := &ast.ArrayLiteralExpression{Token: p.currentToken()}
-	literal.Elements = p.parseExpressionList(t.RightSquareBracket)
+	for !p.nextToken().Is(t.RightSquareBracket) {
+		p.next()
+		if p.currentToken().Is(t.Comma) {
+			// elision [a, , b]
+			literal.Elements = append(literal.Elements, nil)
+			continue
+		}
+		literal.Elements = append(literal.Elements, p.parseListElement())
+		if !p.nextToken().Is(t.RightSquareBracket) && !p.matchNextToken(t.Comma) {
+			p.unexpected(p.nextToken(), t.Comma, t.RightSquareBracket)
+		}
+	}
+	p.next()
 	return literal
 }
 
 func (p *Parser) parseObjectLiteral() ast.Expression {
 	o := &ast.ObjectLiteralExpression{Token: p.currentToken()}
-	o.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.nextToken().Is(t.RightBracket) {
 		// skip { or ,
-		p.scanner.Scan()
+		p.next()
 
-		key := p.parseExpression(PLowest)
+		o.Properties = append(o.Properties, p.parseProperty())
 
-		if !p.expectNextToken(t.Colon) {
-			return nil
+		if !p.nextToken().Is(t.RightBracket) && !p.matchNextToken(t.Comma) {
+			p.unexpected(p.nextToken(), t.Comma, t.RightBracket)
 		}
-		// skip :
-		p.scanner.Scan()
+	}
 
-		value := p.parseExpression(PLowest)
-		o.Pairs[key] = value
+	p.expectNextToken(t.RightBracket)
 
-		if !p.nextToken().Is(t.RightBracket) && !p.expectNextToken(t.Comma) {
-			return nil
-		}
+	return o
+}
+
+// startToken: property name
+// endToken: end of value
+func (p *Parser) parseProperty() *ast.Property {
+	property := &ast.Property{Token: p.currentToken()}
+	token := p.currentToken()
+	switch {
+	case token.Is(t.DotDotDot):
+		property.Spread = true
+		property.Value = p.parseListElement()
+		return property
+	case token.Is(t.LeftSquareBracket):
+		property.Computed = true
+		// skip [
+		p.next()
+		property.Key = p.parseExpression(PLowest)
+		p.expectNextToken(t.RightSquareBracket)
+	case token.Is(t.String):
+		property.Key = p.parseStringLiteral()
+	case token.Is(t.Number):
+		property.Key = p.parseNumericLiteral()
+	case isIdentifierName(token):
+		property.Key = &ast.StringLiteral{Token: token, Value: token.Literal, Raw: token.Literal}
+	default:
+		p.unexpected(token, t.Identifier, t.String, t.Number, t.LeftSquareBracket)
 	}
 
-	if !p.expectNextToken(t.RightBracket) {
-		return nil
+	switch {
+	case p.nextToken().Is(t.Colon):
+		// skip name and :
+		p.next()
+		p.next()
+		property.Value = p.parseExpression(PLowest)
+	case p.nextToken().Is(t.LeftParenthesis):
+		property.Method = true
+		property.Value = p.parseFunctionTail(&ast.FunctionLiteral{Token: token})
+	case token.Is(t.Identifier) && p.nextToken().IsOneOf([]t.TokenType{t.Comma, t.RightBracket}):
+		property.Shorthand = true
+		property.Value = p.parseIdentifier()
+	case token.Is(t.Identifier) && p.nextToken().Is(t.Equal):
+		// {a = 1} is only valid as a pattern
+		property.Shorthand = true
+		name := p.parseIdentifier()
+		p.next()
+		p.coverInitializers = append(p.coverInitializers, p.currentToken())
+		property.Value = p.parseAssignmentExpression(name)
+	case token.Is(t.Identifier):
+		p.unexpected(p.nextToken(), t.Colon, t.LeftParenthesis, t.Comma, t.RightBracket)
+	default:
+		p.unexpected(p.nextToken(), t.Colon, t.LeftParenthesis)
 	}
+	return property
+}
 
-	return o
+// isIdentifierName reports identifiers and reserved words, which are property names after { or .
+func isIdentifierName(token t.Token) bool {
+	if token.Is(t.Identifier) {
+		return true
+	}
+	tokenType, ok := t.LookupKeyword(token.Literal)
+	return ok && token.Is(tokenType)
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
@@ -257,39 +847,218 @@ func (p *Parser) parseBoolean() ast.Expression {
 	}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.currentToken()}
+}
+
+func (p *Parser) parseUndefinedLiteral() ast.Expression {
+	return &ast.UndefinedLiteral{Token: p.currentToken()}
+}
+
 func (p *Parser) parseExpressionList(end t.TokenType) []ast.Expression {
 	var list []ast.Expression
 
 	if p.nextToken().Is(end) {
-		p.scanner.Scan()
+		p.next()
 		return list
 	}
 
-	p.scanner.Scan()
-	list = append(list, p.parseExpression(PLowest))
+	p.next()
+	list = append(list, p.parseListElement())
 
 	for p.nextToken().Is(t.Comma) {
-		p.scanner.Scan()
-		p.scanner.Scan()
-		list = append(list, p.parseExpression(PLowest))
+		p.next()
+		p.next()
+		list = append(list, p.parseListElement())
 	}
 
-	if !p.expectNextToken(end) {
-		return nil
-	}
+	p.expectNextToken(end)
 
 	return list
 
 }
 
+// parseListElement parses an argument or an array element, which may be the spread ...expression
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.currentToken().Is(t.DotDotDot) {
+		return p.parseExpression(PLowest)
+	}
+	spread := &ast.SpreadElement{Token: p.currentToken()}
+	p.next()
+	spread.Argument = p.parseExpression(PLowest)
+	return spread
+}
+
+// parseGroupedExpression parses (expression) and the parameters of arrow functions (a, b) => body,
+// which are parsed as expressions and reinterpreted when => follows
 func (p *Parser) parseGroupedExpression() ast.Expression {
-	p.scanner.Scan()
+	token := p.currentToken()
+	items, comma := p.parseParenthesizedList()
+	if p.nextToken().Is(t.EqualGreater) {
+		return p.parseArrowFunction(token, items, false)
+	}
+	switch {
+	case len(items) == 0:
+		p.unexpected(p.nextToken(), t.EqualGreater)
+	case comma.Is(t.Comma):
+		// there is no comma operator
+		p.unexpected(comma, t.RightParenthesis)
+	}
+	if _, ok := items[0].expression.(*ast.SpreadElement); ok {
+		// (...rest) is only the parameters of an arrow function
+		p.unexpected(p.nextToken(), t.EqualGreater)
+	}
+	return items[0].expression
+}
 
-	exp := p.parseExpression(PLowest)
-	if !p.expectNextToken(t.RightParenthesis) {
-		return nil
+// arrowItem is an expression of a parenthesized list, maybe a parameter of an arrow function
+type arrowItem struct {
+	token      t.Token
+	expression ast.Expression
+}
+
+// parseParenthesizedList parses expressions separated by commas, comma is the first comma
+// startToken: (
+// endToken: )
+func (p *Parser) parseParenthesizedList() (items []arrowItem, comma t.Token) {
+	for !p.nextToken().Is(t.RightParenthesis) {
+		p.next()
+		item := arrowItem{token: p.currentToken()}
+		item.expression = p.parseListElement()
+		items = append(items, item)
+		if _, ok := item.expression.(*ast.SpreadElement); ok && p.nextToken().Is(t.Comma) {
+			p.fail(p.nextToken(), nil, "rest parameter must be last formal parameter")
+		}
+
+		if p.nextToken().Is(t.RightParenthesis) {
+			break
+		}
+		if !p.matchNextToken(t.Comma) {
+			p.unexpected(p.nextToken(), t.RightParenthesis)
+		}
+		if !comma.Is(t.Comma) {
+			comma = p.currentToken()
+		}
+	}
+	p.next()
+	return items, comma
+}
+
+// parseIdentifierOrArrow parses an identifier, or the arrow function x => body
+func (p *Parser) parseIdentifierOrArrow() ast.Expression {
+	token := p.currentToken()
+	if p.async && isContextualKeyword(token, "await") {
+		return p.parseAwaitExpression()
+	}
+	identifier := p.parseIdentifier()
+	if p.nextToken().Is(t.EqualGreater) {
+		return p.parseArrowFunction(token, []arrowItem{{token: token, expression: identifier}}, false)
+	}
+	// async [no LineTerminator here] starts an async function, async is an identifier otherwise
+	if isContextualKeyword(token, "async") && !p.nextToken().NewLineBefore &&
+		p.nextToken().IsOneOf([]t.TokenType{t.Function, t.Identifier, t.LeftParenthesis}) {
+		return p.parseAsyncFunction(identifier)
+	}
+	return identifier
+}
+
+// parseAsyncFunction parses async function () {}, async x => body and async (a, b) => body,
+// async (a, b) without => is a call of async
+func (p *Parser) parseAsyncFunction(async ast.Expression) ast.Expression {
+	token := p.currentToken()
+	var items []arrowItem
+	switch {
+	case p.matchNextToken(t.Function):
+		return p.parseFunction(&ast.FunctionLiteral{Token: p.currentToken(), Async: true})
+	case p.matchNextToken(t.Identifier):
+		items = []arrowItem{{token: p.currentToken(), expression: p.parseIdentifier()}}
+	default:
+		p.expectNextToken(t.LeftParenthesis)
+		call := &ast.CallExpression{Token: p.currentToken(), FunctionName: async}
+		items, _ = p.parseParenthesizedList()
+		if !p.nextToken().Is(t.EqualGreater) {
+			for _, item := range items {
+				call.Arguments = append(call.Arguments, item.expression)
+			}
+			return call
+		}
+	}
+	if !p.nextToken().Is(t.EqualGreater) {
+		p.unexpected(p.nextToken(), t.EqualGreater)
+	}
+	return p.parseArrowFunction(token, items, true)
+}
+
+// parseAwaitExpression parses await x in an async function
+func (p *Parser) parseAwaitExpression() ast.Expression {
+	e := &ast.AwaitExpression{Token: p.currentToken()}
+	p.next()
+	e.Argument = p.parseExpression(PPrefix)
+	return e
+}
+
+// parseArrowFunction reinterprets items as the parameters of an arrow function, a concise body is returned
+// startToken: end of parameters
+// endToken: end of body
+func (p *Parser) parseArrowFunction(token t.Token, items []arrowItem, async bool) ast.Expression {
+	f := &ast.FunctionLiteral{Token: token, Arrow: true, Async: async}
+	// ArrowParameters [no LineTerminator here] =>
+	if p.nextToken().NewLineBefore {
+		p.fail(p.nextToken(), nil, "illegal newline before =>")
+	}
+	var names []*ast.IdentifierExpression
+	for _, item := range items {
+		param := &ast.Parameter{Token: item.token}
+		switch e := item.expression.(type) {
+		case *ast.IdentifierExpression:
+			param.Name = e
+		case *ast.ArrayLiteralExpression, *ast.ObjectLiteralExpression:
+			param.Pattern = p.toPattern(e)
+		case *ast.AssignmentExpression:
+			// (a = 1) => a is the parameter a with the default value 1
+			switch left := e.Left.(type) {
+			case *ast.IdentifierExpression:
+				param.Name = left
+			case *ast.ArrayPattern, *ast.ObjectPattern:
+				param.Pattern = left
+			}
+			if e.Operator != "=" {
+				param.Name, param.Pattern = nil, nil
+			}
+			param.Default = e.Right
+		case *ast.SpreadElement:
+			f.Rest, _ = e.Argument.(*ast.IdentifierExpression)
+			param.Name = f.Rest
+		}
+		if param.Name == nil && param.Pattern == nil {
+			p.fail(item.token, nil, "invalid arrow function parameter")
+		}
+		for _, name := range ast.BoundNames(param.Target()) {
+			for _, other := range names {
+				if other.Value == name.Value {
+					p.fail(item.token, nil, fmt.Sprintf("duplicate parameter '%s' in arrow function", other.Value))
+				}
+			}
+			names = append(names, name)
+		}
+		if f.Rest == nil {
+			f.Parameters = append(f.Parameters, param)
+		}
+	}
+
+	// skip =>
+	p.next()
+	p.next()
+	outer := p.async
+	p.async = async
+	defer func() { p.async = outer }()
+	if p.currentToken().Is(t.LeftBracket) {
+		f.Body = p.parseFunctionBody()
+		return f
 	}
-	return exp
+	body := &ast.ReturnStatement{Token: p.currentToken(), ReturnValue: p.parseExpression(PLowest)}
+	f.Body = &ast.BlockStatement{Token: body.Token, Statements: []ast.Statement{body}}
+	return f
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -298,7 +1067,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 		Operator: p.currentToken().Literal,
 	}
 
-	p.scanner.Scan()
+	p.next()
 
 	e.Right = p.parseExpression(PPrefix)
 	return e
@@ -309,29 +1078,21 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		Token: p.currentToken(),
 	}
 
-	if !p.expectNextToken(t.LeftParenthesis) {
-		return nil
-	}
+	p.expectNextToken(t.LeftParenthesis)
 
-	p.scanner.Scan()
+	p.next()
 	e.Condition = p.parseExpression(PLowest)
 
-	if !p.expectNextToken(t.RightParenthesis) {
-		return nil
-	}
+	p.expectNextToken(t.RightParenthesis)
 
-	if !p.expectNextToken(t.LeftBracket) {
-		return nil
-	}
+	p.expectNextToken(t.LeftBracket)
 
 	e.Consequence = p.parseBlockStatement()
 
 	if p.nextToken().Is(t.Else) {
-		p.scanner.Scan()
+		p.next()
 
-		if !p.expectNextToken(t.LeftBracket) {
-			return nil
-		}
+		p.expectNextToken(t.LeftBracket)
 
 		e.Alternative = p.parseBlockStatement()
 	}
@@ -341,76 +1102,248 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 // function <identifier> params block
 func (p *Parser) parseFunctionLiteral() ast.Expression {
-	f := &ast.FunctionLiteral{Token: p.currentToken()}
+	return p.parseFunction(&ast.FunctionLiteral{Token: p.currentToken()})
+}
 
+// parseFunction parses the optional name, parameters and body of f
+// startToken: function
+// endToken: }
+func (p *Parser) parseFunction(f *ast.FunctionLiteral) ast.Expression {
 	if !p.nextToken().Is(t.LeftParenthesis) {
-		p.scanner.Scan()
+		p.next()
 		f.Name = p.parseIdentifier().(*ast.IdentifierExpression)
 	}
 
-	if !p.expectNextToken(t.LeftParenthesis) {
-		return nil
-	}
-	f.Parameters = p.parseFunctionParameters()
+	return p.parseFunctionTail(f)
+}
 
-	if !p.expectNextToken(t.LeftBracket) {
-		return nil
-	}
+// parseFunctionTail parses parameters and body of f
+// startToken: before (
+// endToken: }
+func (p *Parser) parseFunctionTail(f *ast.FunctionLiteral) ast.Expression {
+	p.expectNextToken(t.LeftParenthesis)
+	p.parseFunctionParameters(f)
 
-	f.Body = p.parseBlockStatement()
+	p.expectNextToken(t.LeftBracket)
+
+	outer := p.async
+	p.async = f.Async
+	defer func() { p.async = outer }()
+	f.Body = p.parseFunctionBody()
 
 	return f
 }
 
+// parseFunctionParameters parses identifiers with optional defaults a = 1, the last may be the rest ...args
 // startToken: (
 // endToken: after )
-func (p *Parser) parseFunctionParameters() []*ast.IdentifierExpression {
-	var params []*ast.IdentifierExpression
+func (p *Parser) parseFunctionParameters(f *ast.FunctionLiteral) {
+	for !p.nextToken().Is(t.RightParenthesis) {
+		if p.matchNextToken(t.DotDotDot) {
+			p.expectNextToken(t.Identifier)
+			f.Rest = p.parseIdentifier().(*ast.IdentifierExpression)
+			if !p.nextToken().Is(t.RightParenthesis) {
+				p.fail(p.nextToken(), nil, "rest parameter must be last formal parameter")
+			}
+			break
+		}
+
+		param := &ast.Parameter{Token: p.nextToken()}
+		if p.nextToken().IsOneOf([]t.TokenType{t.LeftSquareBracket, t.LeftBracket}) {
+			p.next()
+			param.Pattern = p.parseBindingPattern()
+		} else {
+			p.expectNextToken(t.Identifier)
+			param.Name = p.parseIdentifier().(*ast.IdentifierExpression)
+		}
+		if p.matchNextToken(t.Equal) {
+			p.next()
+			param.Default = p.parseExpression(PLowest)
+		}
+		f.Parameters = append(f.Parameters, param)
 
-	if p.nextToken().Is(t.RightParenthesis) {
-		p.scanner.Scan()
-		return params
+		if !p.nextToken().Is(t.RightParenthesis) && !p.matchNextToken(t.Comma) {
+			p.unexpected(p.nextToken(), t.Comma, t.RightParenthesis)
+		}
 	}
 
-	// Skip (
-	p.scanner.Scan()
+	p.expectNextToken(t.RightParenthesis)
+}
 
-	param := &ast.IdentifierExpression{Token: p.currentToken(), Value: p.currentToken().Literal}
-	params = append(params, param)
+// parseBindingPattern parses the pattern of a declaration or a parameter
+// startToken: [ or {
+// endToken: ] or }
+func (p *Parser) parseBindingPattern() ast.Expression {
+	if p.currentToken().Is(t.LeftSquareBracket) {
+		return p.toPattern(p.parseArrayLiteral())
+	}
+	return p.toPattern(p.parseObjectLiteral())
+}
 
-	for p.nextToken().Is(t.Comma) {
-		// Skip current literal
-		p.scanner.Scan()
-		// Skip ,
-		p.scanner.Scan()
-		param := &ast.IdentifierExpression{Token: p.currentToken(), Value: p.currentToken().Literal}
-		params = append(params, param)
+// toPattern reinterprets an array or object literal as a pattern, e.g. the left side of [a, b] = [b, a]
+func (p *Parser) toPattern(literal ast.Expression) ast.Expression {
+	switch literal := literal.(type) {
+	case *ast.ArrayLiteralExpression:
+		pattern := &ast.ArrayPattern{Token: literal.Token}
+		for i, element := range literal.Elements {
+			if spread, ok := element.(*ast.SpreadElement); ok {
+				if i != len(literal.Elements)-1 {
+					p.fail(spread.Token, nil, "rest element must be last element")
+				}
+				pattern.Rest = p.toTarget(spread.Token, spread.Argument)
+				break
+			}
+			if element == nil {
+				pattern.Elements = append(pattern.Elements, nil)
+				continue
+			}
+			pattern.Elements = append(pattern.Elements, p.toPatternElement(literal.Token, element))
+		}
+		return pattern
+	case *ast.ObjectLiteralExpression:
+		pattern := &ast.ObjectPattern{Token: literal.Token}
+		for i, property := range literal.Properties {
+			switch {
+			case property.Spread:
+				spread := property.Value.(*ast.SpreadElement)
+				if i != len(literal.Properties)-1 {
+					p.fail(spread.Token, nil, "rest element must be last element")
+				}
+				rest, ok := spread.Argument.(*ast.IdentifierExpression)
+				if !ok {
+					p.fail(spread.Token, nil, "invalid destructuring target")
+				}
+				pattern.Rest = rest
+			case property.Method:
+				p.fail(property.Token, nil, "invalid destructuring target")
+			default:
+				element := p.toPatternElement(property.Token, property.Value)
+				element.Key = property.Key
+				element.Computed = property.Computed
+				pattern.Properties = append(pattern.Properties, element)
+			}
+		}
+		return pattern
 	}
+	return literal
+}
 
-	if !p.expectNextToken(t.RightParenthesis) {
-		return nil
+// toPatternElement reinterprets e as an element of a pattern, a = 1 is the target a with a default value
+func (p *Parser) toPatternElement(token t.Token, e ast.Expression) *ast.PatternElement {
+	element := &ast.PatternElement{Token: token}
+	if assignment, ok := e.(*ast.AssignmentExpression); ok && assignment.Operator == "=" {
+		p.removeCoverInitializer(assignment.Token)
+		element.Target = p.toTarget(token, assignment.Left)
+		element.Default = assignment.Right
+		return element
 	}
+	element.Target = p.toTarget(token, e)
+	return element
+}
+
+// toTarget reinterprets e as the target of a pattern element, an identifier or a nested pattern
+func (p *Parser) toTarget(token t.Token, e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.IdentifierExpression, *ast.ArrayPattern, *ast.ObjectPattern:
+		return e
+	case *ast.ArrayLiteralExpression, *ast.ObjectLiteralExpression:
+		return p.toPattern(e)
+	}
+	p.fail(token, nil, "invalid destructuring target")
+	return nil
+}
 
-	return params
+// removeCoverInitializer forgets the shorthand property initializer at token, its object literal is a pattern
+func (p *Parser) removeCoverInitializer(token t.Token) {
+	for i, pending := range p.coverInitializers {
+		if pending.Start == token.Start {
+			p.coverInitializers = append(p.coverInitializers[:i], p.coverInitializers[i+1:]...)
+			return
+		}
+	}
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	b := &ast.BlockStatement{Token: p.currentToken()}
 	b.Statements = []ast.Statement{}
 
-	p.scanner.Scan()
+	p.next()
+
+	for !p.currentToken().Is(t.RightBracket) {
+		if p.currentToken().Is(t.EOF) {
+			p.unexpected(p.currentToken(), t.RightBracket)
+		}
+		stmt := p.parseStatementOrRecover()
+		if stmt != nil {
+			b.Statements = append(b.Statements, stmt)
+		}
+		p.next()
+	}
+
+	return b
+}
 
-	for !p.currentToken().Is(t.RightBracket) && !p.currentToken().Is(t.EOF) {
-		stmt := p.parseStatement()
+// parseFunctionBody parses a block with a directive prologue, "use strict" makes the body strict mode code
+// startToken: {
+// endToken: }
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	strict := p.scanner.Strict()
+	// the token after } is scanned in the mode of the enclosing code
+	defer p.scanner.SetStrict(strict)
+	b := &ast.BlockStatement{Token: p.currentToken()}
+	b.Statements = []ast.Statement{}
+
+	p.next()
+
+	var prologue directivePrologue
+	for !p.currentToken().Is(t.RightBracket) {
+		if p.currentToken().Is(t.EOF) {
+			p.unexpected(p.currentToken(), t.RightBracket)
+		}
+		first := p.currentToken()
+		stmt := p.parseStatementOrRecover()
 		if stmt != nil {
 			b.Statements = append(b.Statements, stmt)
 		}
-		p.scanner.Scan()
+		p.directive(&prologue, first, stmt)
+		if p.nextToken().Is(t.RightBracket) {
+			p.scanner.SetStrict(strict)
+		}
+		p.next()
 	}
 
 	return b
 }
 
+// directivePrologue is the string literal statements at the start of a program or function body
+type directivePrologue struct {
+	done       bool
+	directives []t.Token
+}
+
+// directive switches the scanner to strict mode after a "use strict" directive, first is the first token of stmt,
+// directives before it are checked again, the prologue ends at the first other statement
+func (p *Parser) directive(prologue *directivePrologue, first t.Token, stmt ast.Statement) {
+	if prologue.done {
+		return
+	}
+	var literal *ast.StringLiteral
+	if e, ok := stmt.(*ast.ExpressionStatement); ok && first.Is(t.String) {
+		literal, _ = e.Expression.(*ast.StringLiteral)
+	}
+	if literal == nil {
+		prologue.done = true
+		return
+	}
+	if literal.Raw == "use strict" && !p.scanner.Strict() {
+		p.scanner.SetStrict(true)
+		for _, directive := range prologue.directives {
+			p.scanner.CheckStrict(directive)
+		}
+	}
+	prologue.directives = append(prologue.directives, first)
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	e := &ast.InfixExpression{
 		Token:    p.currentToken(),
@@ -419,7 +1352,11 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.currentPrecedence()
-	p.scanner.Scan()
+	if e.Token.Is(t.StarStar) {
+		// right-associative, a ** b ** c is a ** (b ** c)
+		precedence--
+	}
+	p.next()
 
 	e.Right = p.parseExpression(precedence)
 
@@ -427,15 +1364,95 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 }
 
+var logicalOperators = []t.TokenType{t.AmpersandAmpersand, t.BarBar}
+
+// parseLogicalExpression parses a && b, a || b and a ?? b.
+// ?? can't be mixed with && or || without parentheses, the operands of ?? are parsed above && so they stop at them
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	e := &ast.LogicalExpression{
+		Token:    p.currentToken(),
+		Operator: p.currentToken().Literal,
+		Left:     left,
+	}
+
+	precedence := p.currentPrecedence()
+	if e.Token.Is(t.QuestionQuestion) {
+		precedence = PLogicalAnd
+	}
+	p.next()
+
+	e.Right = p.parseExpression(precedence)
+
+	next := p.nextToken()
+	if e.Token.Is(t.QuestionQuestion) && next.IsOneOf(logicalOperators) || !e.Token.Is(t.QuestionQuestion) && next.Is(t.QuestionQuestion) {
+		p.fail(next, nil, fmt.Sprintf("cannot mix %s and %s without parentheses", e.Operator, next.Literal))
+	}
+	return e
+}
+
+// parseAssignmentExpression parses the right-associative a = b += c, the target is an identifier,
+// or a pattern for =
+func (p *Parser) parseAssignmentExpression(left ast.Expression) ast.Expression {
+	e := &ast.AssignmentExpression{
+		Token:    p.currentToken(),
+		Operator: p.currentToken().Literal,
+		Left:     left,
+	}
+	switch left.(type) {
+	case *ast.IdentifierExpression:
+	case *ast.ArrayLiteralExpression, *ast.ObjectLiteralExpression:
+		if !e.Token.Is(t.Equal) {
+			p.fail(p.currentToken(), nil, "invalid assignment target")
+		}
+		e.Left = p.toPattern(left)
+	default:
+		p.fail(p.currentToken(), nil, "invalid assignment target")
+	}
+
+	p.next()
+
+	// PLowest, not PAssign, so a = b = c is a = (b = c)
+	e.Right = p.parseExpression(PLowest)
+
+	return e
+}
+
+// parseUpdateExpression parses ++a and --a
+func (p *Parser) parseUpdateExpression() ast.Expression {
+	e := &ast.UpdateExpression{
+		Token:    p.currentToken(),
+		Operator: p.currentToken().Literal,
+		Prefix:   true,
+	}
+	p.next()
+
+	e.Argument = p.parseExpression(PPrefix)
+	if _, ok := e.Argument.(*ast.IdentifierExpression); !ok {
+		p.fail(e.Token, nil, "invalid left-hand side in prefix operation")
+	}
+	return e
+}
+
+// parsePostfixExpression parses a++ and a--, parseExpression doesn't call it after a line terminator
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	e := &ast.UpdateExpression{
+		Token:    p.currentToken(),
+		Operator: p.currentToken().Literal,
+		Argument: left,
+	}
+	if _, ok := left.(*ast.IdentifierExpression); !ok {
+		p.fail(e.Token, nil, "invalid left-hand side in postfix operation")
+	}
+	return e
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	e := &ast.IndexExpression{Token: p.currentToken(), Left: left}
-	p.scanner.Scan()
+	p.next()
 
 	e.Index = p.parseExpression(PLowest)
 
-	if !p.expectNextToken(t.RightSquareBracket) {
-		return nil
-	}
+	p.expectNextToken(t.RightSquareBracket)
 
 	return e
 }
@@ -447,6 +1464,76 @@ func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
 	return e
 }
 
+// parseMemberExpression parses a.b, the property name may be a reserved word
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	e := &ast.MemberExpression{Token: p.currentToken(), Object: object}
+	p.next()
+	if !isIdentifierName(p.currentToken()) {
+		p.unexpected(p.currentToken(), t.Identifier)
+	}
+	e.Property = p.currentToken().Literal
+	return e
+}
+
+var chainTokens = []t.TokenType{
+	t.QuestionDot, t.Dot, t.LeftSquareBracket, t.LeftParenthesis, t.NoSubstitutionTemplate, t.TemplateHead,
+}
+
+// parseOptionalChain parses the member accesses and calls following ?. into one ChainExpression,
+// a?.b.c() skips .c() too when a is null or undefined
+func (p *Parser) parseOptionalChain(left ast.Expression) ast.Expression {
+	chain := &ast.ChainExpression{Token: p.currentToken()}
+	for {
+		switch p.currentToken().TokenType {
+		case t.QuestionDot:
+			left = p.parseOptionalElement(left)
+		case t.Dot:
+			left = p.parseMemberExpression(left)
+		case t.LeftSquareBracket:
+			left = p.parseIndexExpression(left)
+		case t.LeftParenthesis:
+			left = p.parseCallExpression(left)
+		default:
+			p.fail(p.currentToken(), nil, "invalid tagged template on optional chain")
+		}
+		if !p.nextToken().IsOneOf(chainTokens) {
+			break
+		}
+		p.next()
+	}
+	chain.Chain = left
+	return chain
+}
+
+// parseOptionalElement parses ?.b, ?.[k] or ?.(args)
+func (p *Parser) parseOptionalElement(left ast.Expression) ast.Expression {
+	switch {
+	case p.matchNextToken(t.LeftSquareBracket):
+		e := p.parseIndexExpression(left).(*ast.IndexExpression)
+		e.Optional = true
+		return e
+	case p.matchNextToken(t.LeftParenthesis):
+		e := p.parseCallExpression(left).(*ast.CallExpression)
+		e.Optional = true
+		return e
+	case p.nextToken().Is(t.NoSubstitutionTemplate), p.nextToken().Is(t.TemplateHead):
+		p.fail(p.nextToken(), nil, "invalid tagged template on optional chain")
+	}
+	e := p.parseMemberExpression(left).(*ast.MemberExpression)
+	e.Optional = true
+	return e
+}
+
+func (p *Parser) parseThisExpression() ast.Expression {
+	return &ast.ThisExpression{Token: p.currentToken()}
+}
+
+func (p *Parser) parseTaggedTemplateExpression(tag ast.Expression) ast.Expression {
+	e := &ast.TaggedTemplateExpression{Token: p.currentToken(), Tag: tag}
+	e.Quasi = p.parseTemplateLiteral().(*ast.TemplateLiteral)
+	return e
+}
+
 func (p *Parser) currentToken() t.Token {
 	return p.scanner.CurrentToken()
 }
@@ -463,11 +1550,6 @@ func (p *Parser) registerInfix(tokenType t.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
-func (p *Parser) noPrefixParseFnError(t t.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t.String())
-	p.errors = append(p.errors, msg)
-}
-
 func (p *Parser) nextTokenPrecedence() precedenceType {
 	if p, ok := precedences[p.nextToken().TokenType]; ok {
 		return p
@@ -484,11 +1566,28 @@ func (p *Parser) currentPrecedence() precedenceType {
 	return PLowest
 }
 
+var postfixOperators = []t.TokenType{t.PlusPlus, t.MinusMinus}
+
+// canInsertSemicolon reports whether a statement can end before next token,
+// automatic semicolon insertion happens before }, EOF or a token after a line terminator
+func (p *Parser) canInsertSemicolon() bool {
+	next := p.nextToken()
+	return next.IsOneOf([]t.TokenType{t.Semicolon, t.RightBracket, t.EOF}) || next.NewLineBefore
+}
+
+// expectSemicolon skips the ; ending current statement, it may be inserted automatically
+func (p *Parser) expectSemicolon() {
+	if p.matchNextToken(t.Semicolon) || p.canInsertSemicolon() {
+		return
+	}
+	p.unexpected(p.nextToken(), t.Semicolon)
+}
+
 func (p *Parser) matchToken(tokenType t.TokenType) (ok bool) {
 	if !p.scanner.CurrentToken().Is(tokenType) {
 		return false
 	}
-	p.scanner.Scan()
+	p.next()
 	return true
 }
 
@@ -496,18 +1595,88 @@ func (p *Parser) matchNextToken(tokenType t.TokenType) (ok bool) {
 	if !p.scanner.NextToken().Is(tokenType) {
 		return false
 	}
-	p.scanner.Scan()
+	p.next()
 	return true
 }
 
-func (p *Parser) expectNextToken(tokenType t.TokenType) bool {
+// expectNextToken skips to next token, which must be tokenType
+func (p *Parser) expectNextToken(tokenType t.TokenType) {
 	if !p.matchNextToken(tokenType) {
-		p.tokenMatchError(p.nextToken(), tokenType)
-		return false
+		p.unexpected(p.nextToken(), tokenType)
 	}
-	return true
 }
 
-func (p *Parser) tokenMatchError(token t.Token, tokenType t.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("expected match token %s, got %s", token.TokenType.String(), tokenType.String()))
+// next moves to next token, counting brackets for synchronize
+func (p *Parser) next() {
+	p.scanner.Scan()
+	p.countBracket()
+}
+
+func (p *Parser) countBracket() {
+	switch p.currentToken().TokenType {
+	case t.LeftParenthesis, t.LeftSquareBracket, t.LeftBracket:
+		p.depth++
+	case t.RightParenthesis, t.RightSquareBracket, t.RightBracket:
+		p.depth--
+	}
+}
+
+// unexpected fails with token when one of expected is required
+func (p *Parser) unexpected(token t.Token, expected ...t.TokenType) {
+	names := make([]string, len(expected))
+	for i, tokenType := range expected {
+		names[i] = tokenType.String()
+	}
+	message := fmt.Sprintf("expected %s, got %s", strings.Join(names, " or "), token.TokenType)
+	p.fail(token, expected, message)
+}
+
+// fail records a ParseError at token and bails out of current statement,
+// errors at Error tokens are already reported by the scanner
+func (p *Parser) fail(token t.Token, expected []t.TokenType, message string) {
+	if !token.Is(t.Error) {
+		p.errors = append(p.errors, &ParseError{
+			Pos:      p.scanner.File().Pos(token.Start),
+			Line:     token.Line,
+			Col:      token.Col,
+			Expected: expected,
+			Actual:   token,
+			Message:  message,
+		})
+	}
+	panic(bailout{})
+}
+
+// numericValue converts a Number token literal to its float64 value
+func numericValue(literal string) (float64, error) {
+	l := strings.ReplaceAll(literal, "_", "")
+	if len(l) > 1 && l[0] == '0' {
+		switch l[1] {
+		case 'x', 'X':
+			return radixValue(l[2:], 16)
+		case 'o', 'O':
+			return radixValue(l[2:], 8)
+		case 'b', 'B':
+			return radixValue(l[2:], 2)
+		}
+		// legacy octal, e.g. 017, while 019 is decimal
+		if !strings.ContainsAny(l, ".eE89") {
+			return radixValue(l[1:], 8)
+		}
+	}
+	value, err := strconv.ParseFloat(l, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// out of range values round to Infinity or 0
+		return value, nil
+	}
+	return value, err
+}
+
+func radixValue(digits string, base int) (float64, error) {
+	i, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return 0, fmt.Errorf("invalid base %d digits %q", base, digits)
+	}
+	value, _ := new(big.Float).SetInt(i).Float64()
+	return value, nil
 }
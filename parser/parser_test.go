@@ -3,7 +3,9 @@ package parser
 import (
 	"github.com/Seeingu/coldmoon/ast"
 	"github.com/Seeingu/coldmoon/lexer"
+	"github.com/Seeingu/coldmoon/token"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 )
 
@@ -44,8 +46,8 @@ func TestFunction(t *testing.T) {
 	assert.True(t, ok)
 
 	function, ok := stmt.Expression.(*ast.FunctionLiteral)
-	testLiteralExpression(t, function.Parameters[0], "b")
-	testLiteralExpression(t, function.Parameters[1], "c")
+	testLiteralExpression(t, function.Parameters[0].Name, "b")
+	testLiteralExpression(t, function.Parameters[1].Name, "c")
 
 	returnStmt, ok := function.Body.Statements[0].(*ast.ReturnStatement)
 	assert.True(t, ok)
@@ -74,8 +76,83 @@ func TestIndex(t *testing.T) {
 	testInfixExpression(t, indexExp.Index, infixExpected{1, "+", 1})
 }
 
+func TestMemberExpressions(t *testing.T) {
+	program := parseProgram(t, "a.b.if; -a.b(c); this.a")
+
+	e := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MemberExpression)
+	assert.Equal(t, "if", e.Property)
+	inner := e.Object.(*ast.MemberExpression)
+	testIdentifier(t, inner.Object, "a")
+	assert.Equal(t, "b", inner.Property)
+	assert.False(t, inner.Optional)
+
+	prefix := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.PrefixExpression)
+	call := prefix.Right.(*ast.CallExpression)
+	method := call.FunctionName.(*ast.MemberExpression)
+	testIdentifier(t, method.Object, "a")
+	assert.Equal(t, "b", method.Property)
+	testIdentifier(t, call.Arguments[0], "c")
+
+	this := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.MemberExpression)
+	_, ok := this.Object.(*ast.ThisExpression)
+	assert.True(t, ok)
+}
+
+func TestOptionalChaining(t *testing.T) {
+	program := parseProgram(t, "a?.b.c(d)?.[e]; f?.() + 1")
+
+	chain := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ChainExpression)
+	index := chain.Chain.(*ast.IndexExpression)
+	assert.True(t, index.Optional)
+	testIdentifier(t, index.Index, "e")
+	call := index.Left.(*ast.CallExpression)
+	assert.False(t, call.Optional)
+	testIdentifier(t, call.Arguments[0], "d")
+	c := call.FunctionName.(*ast.MemberExpression)
+	assert.Equal(t, "c", c.Property)
+	assert.False(t, c.Optional)
+	b := c.Object.(*ast.MemberExpression)
+	assert.Equal(t, "b", b.Property)
+	assert.True(t, b.Optional)
+	testIdentifier(t, b.Object, "a")
+
+	infix := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	chain = infix.Left.(*ast.ChainExpression)
+	assert.True(t, chain.Chain.(*ast.CallExpression).Optional)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a?.b`c`", "line 1, col 5: invalid tagged template on optional chain"},
+		{"a?.`c`", "line 1, col 4: invalid tagged template on optional chain"},
+		{"a?.b = 1", "line 1, col 6: invalid assignment target"},
+		{"a.b++", "line 1, col 4: invalid left-hand side in postfix operation"},
+		{"a.;", "line 1, col 3: expected Identifier, got Semicolon"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestBlockStatement(t *testing.T) {
+	program := parseProgram(t, "{ let y = 2; y } {} ({})")
+	assert.Equal(t, 3, len(program.Statements))
+
+	block := program.Statements[0].(*ast.BlockStatement)
+	assert.Equal(t, 2, len(block.Statements))
+	_, ok := block.Statements[0].(*ast.LetStatement)
+	assert.True(t, ok)
+	empty := program.Statements[1].(*ast.BlockStatement)
+	assert.Equal(t, 0, len(empty.Statements))
+	_, ok = program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.ObjectLiteralExpression)
+	assert.True(t, ok)
+}
+
 func TestEmptyObjectLiteral(t *testing.T) {
-	input := "{}"
+	input := "({})"
 
 	l := lexer.New(input)
 	p := New(l)
@@ -85,11 +162,11 @@ func TestEmptyObjectLiteral(t *testing.T) {
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
 	o, ok := stmt.Expression.(*ast.ObjectLiteralExpression)
 	assert.True(t, ok)
-	assert.Equal(t, 0, len(o.Pairs))
+	assert.Equal(t, 0, len(o.Properties))
 }
 
 func TestParsingObjects(t *testing.T) {
-	input := `{"one": 1, "two": 2, three: 3}`
+	input := `({"one": 1, "two": 2, three: 3})`
 
 	l := lexer.New(input)
 	p := New(l)
@@ -106,14 +183,68 @@ func TestParsingObjects(t *testing.T) {
 		"three": 3,
 	}
 
-	assert.Equal(t, len(expected), len(hash.Pairs))
+	assert.Equal(t, len(expected), len(hash.Properties))
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, property := range hash.Properties {
+		literal, ok := property.Key.(*ast.StringLiteral)
 		assert.True(t, ok)
 
 		expectedValue := expected[literal.Value]
-		testIntegerLiteral(t, value, expectedValue)
+		testIntegerLiteral(t, property.Value, expectedValue)
+	}
+}
+
+func TestObjectPropertyNames(t *testing.T) {
+	program := parseProgram(t, `({
+	if: 1, class: 2, "a b": 3, 0x10: 4, [k + 1]: 5, a, f(x) { return x }, get: { nested: 6 },
+})`)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	o, ok := stmt.Expression.(*ast.ObjectLiteralExpression)
+	assert.True(t, ok)
+	assert.Equal(t, 8, len(o.Properties))
+
+	for i, name := range []string{"if", "class", "a b"} {
+		testStringLiteral(t, o.Properties[i].Key, name)
+		testIntegerLiteral(t, o.Properties[i].Value, int64(i+1))
+	}
+	testNumericLiteral(t, o.Properties[3].Key, float64(16))
+
+	computed := o.Properties[4]
+	assert.True(t, computed.Computed)
+	testInfixExpression(t, computed.Key, infixExpected{"k", "+", 1})
+
+	shorthand := o.Properties[5]
+	assert.True(t, shorthand.Shorthand)
+	testStringLiteral(t, shorthand.Key, "a")
+	testIdentifier(t, shorthand.Value, "a")
+
+	method := o.Properties[6]
+	assert.True(t, method.Method)
+	testStringLiteral(t, method.Key, "f")
+	f, ok := method.Value.(*ast.FunctionLiteral)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(f.Parameters))
+	assert.Equal(t, 1, len(f.Body.Statements))
+
+	nested, ok := o.Properties[7].Value.(*ast.ObjectLiteralExpression)
+	assert.True(t, ok)
+	testStringLiteral(t, nested.Properties[0].Key, "nested")
+}
+
+func TestObjectPropertyErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		error string
+	}{
+		{"({if})", "line 1, col 5: expected Colon or LeftParenthesis, got RightBracket"},
+		{"({a b})", "line 1, col 5: expected Colon or LeftParenthesis or Comma or RightBracket, got Identifier"},
+		{"({+: 1})", "line 1, col 3: expected Identifier or String or Number or LeftSquareBracket, got Plus"},
+		{"({[a: 1})", "line 1, col 5: expected RightSquareBracket, got Colon"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Contains(t, p.Errors(), tt.error, tt.input)
 	}
 }
 
@@ -132,6 +263,87 @@ func TestArrayLiteral(t *testing.T) {
 	assert.Equal(t, 2, len(array.Elements))
 }
 
+func TestNumericLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"5", 5},
+		{"1.5", 1.5},
+		{".5", 0.5},
+		{"5.", 5},
+		{"1e-7", 1e-7},
+		{"2.5E+3", 2500},
+		{"0x1F", 31},
+		{"0o17", 15},
+		{"0b101", 5},
+		{"1_000_000", 1000000},
+		{"0x7fff_ffff_ffff_ffff", 9223372036854775807},
+		{"017", 15},
+		{"019", 19},
+		{"08.5", 8.5},
+		{"1e400", math.Inf(1)},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		testNumericLiteral(t, stmt.Expression, tt.expected)
+	}
+}
+
+func TestRegExpLiteral(t *testing.T) {
+	program := parseProgram(t, "let re = /[a-z/]+\\//gi")
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	re, ok := stmt.Value.(*ast.RegExpLiteral)
+	assert.True(t, ok)
+	assert.Equal(t, "[a-z/]+\\/", re.Pattern)
+	assert.Equal(t, "gi", re.Flags)
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	input := "`a${b}c${1 + 2}`"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	template, ok := stmt.Expression.(*ast.TemplateLiteral)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(template.Quasis))
+	assert.Equal(t, "a", template.Quasis[0].Value)
+	assert.Equal(t, "c", template.Quasis[1].Value)
+	assert.Equal(t, "", template.Quasis[2].Value)
+	assert.Equal(t, 2, len(template.Expressions))
+	testIdentifier(t, template.Expressions[0], "b")
+	testInfixExpression(t, template.Expressions[1], infixExpected{1, "+", 2})
+}
+
+func TestTaggedTemplate(t *testing.T) {
+	input := "tag`a\\n${b}`"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	tagged, ok := stmt.Expression.(*ast.TaggedTemplateExpression)
+	assert.True(t, ok)
+	testIdentifier(t, tagged.Tag, "tag")
+	assert.Equal(t, 2, len(tagged.Quasi.Quasis))
+	assert.Equal(t, "a\n", tagged.Quasi.Quasis[0].Value)
+	assert.Equal(t, `a\n`, tagged.Quasi.Quasis[0].Raw)
+	testIdentifier(t, tagged.Quasi.Expressions[0], "b")
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world";`
 
@@ -188,6 +400,480 @@ func TestLet(t *testing.T) {
 
 }
 
+func TestVarConst(t *testing.T) {
+	program := parseProgram(t, "var a = 1; var b; const c = a; let d")
+	assert.Equal(t, 4, len(program.Statements))
+
+	tests := []struct {
+		tokenType token.TokenType
+		name      string
+		hasValue  bool
+	}{
+		{token.Var, "a", true},
+		{token.Var, "b", false},
+		{token.Const, "c", true},
+		{token.Let, "d", false},
+	}
+	for i, tt := range tests {
+		stmt := program.Statements[i].(*ast.LetStatement)
+		assert.Equal(t, tt.tokenType, stmt.Token.TokenType)
+		testLet(t, stmt, tt.name)
+		assert.Equal(t, tt.hasValue, stmt.Value != nil)
+	}
+
+	l := lexer.New("const a;")
+	p := New(l)
+	p.ParseProgram()
+	assert.Equal(t, []string{"line 1, col 8: missing initializer in const declaration"}, p.Errors())
+}
+
+func TestAssignmentExpression(t *testing.T) {
+	program := parseProgram(t, "a = b = 1 + 2")
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	outer := stmt.Expression.(*ast.AssignmentExpression)
+	testIdentifier(t, outer.Left, "a")
+	assert.Equal(t, "=", outer.Operator)
+	inner := outer.Right.(*ast.AssignmentExpression)
+	testIdentifier(t, inner.Left, "b")
+	testInfixExpression(t, inner.Right, infixExpected{1, "+", 2})
+
+	l := lexer.New("1 = 2")
+	p := New(l)
+	p.ParseProgram()
+	assert.Equal(t, []string{"line 1, col 3: invalid assignment target"}, p.Errors())
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	operators := []string{"+=", "-=", "*=", "/=", "%=", "**=", "<<=", ">>=", ">>>=", "&=", "|=", "^=", "&&=", "||=", "??="}
+	for _, operator := range operators {
+		program := parseProgram(t, "a "+operator+" b "+operator+" 1")
+		e := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.AssignmentExpression)
+		testIdentifier(t, e.Left, "a")
+		assert.Equal(t, operator, e.Operator)
+		inner := e.Right.(*ast.AssignmentExpression)
+		testIdentifier(t, inner.Left, "b")
+		testLiteralExpression(t, inner.Right, 1)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[a] += 1", "line 1, col 5: invalid assignment target"},
+		{"a + b -= 1", "line 1, col 7: invalid assignment target"},
+		{"(a += 1) => a", "line 1, col 2: invalid arrow function parameter"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestUpdateExpressions(t *testing.T) {
+	program := parseProgram(t, "++a; b--; -c++; a\n++b")
+	assert.Equal(t, 5, len(program.Statements))
+
+	tests := []struct {
+		operator string
+		prefix   bool
+		argument string
+	}{
+		{"++", true, "a"},
+		{"--", false, "b"},
+		{"++", false, "c"},
+		{"", false, ""},
+		{"++", true, "b"},
+	}
+	for i, tt := range tests {
+		e := program.Statements[i].(*ast.ExpressionStatement).Expression
+		if prefix, ok := e.(*ast.PrefixExpression); ok {
+			// -c++ is -(c++)
+			e = prefix.Right
+		}
+		if tt.operator == "" {
+			testIdentifier(t, e, "a")
+			continue
+		}
+		update := e.(*ast.UpdateExpression)
+		assert.Equal(t, tt.operator, update.Operator)
+		assert.Equal(t, tt.prefix, update.Prefix)
+		testIdentifier(t, update.Argument, tt.argument)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"++1", "line 1, col 1: invalid left-hand side in prefix operation"},
+		{"a++ ++", "line 1, col 5: invalid left-hand side in postfix operation"},
+		{"f()--", "line 1, col 4: invalid left-hand side in postfix operation"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	program := parseProgram(t, `
+	while (a < 1) { a }
+	do b; while (b)
+	for (let i = 0; i < 2; i = i + 1) c
+	for (;;) {}
+	outer: for (x; ;) { break outer; continue }
+	`)
+	assert.Equal(t, 5, len(program.Statements))
+
+	while := program.Statements[0].(*ast.WhileStatement)
+	testInfixExpression(t, while.Condition, infixExpected{"a", "<", 1})
+	assert.Equal(t, 1, len(while.Body.(*ast.BlockStatement).Statements))
+
+	doWhile := program.Statements[1].(*ast.DoWhileStatement)
+	testIdentifier(t, doWhile.Body.(*ast.ExpressionStatement).Expression, "b")
+	testIdentifier(t, doWhile.Condition, "b")
+
+	forStmt := program.Statements[2].(*ast.ForStatement)
+	testLet(t, forStmt.Init.(*ast.LetStatement), "i")
+	testInfixExpression(t, forStmt.Condition, infixExpected{"i", "<", 2})
+	_, ok := forStmt.Update.(*ast.AssignmentExpression)
+	assert.True(t, ok)
+	testIdentifier(t, forStmt.Body.(*ast.ExpressionStatement).Expression, "c")
+
+	empty := program.Statements[3].(*ast.ForStatement)
+	assert.Nil(t, empty.Init)
+	assert.Nil(t, empty.Condition)
+	assert.Nil(t, empty.Update)
+
+	labeled := program.Statements[4].(*ast.LabeledStatement)
+	testIdentifier(t, labeled.Label, "outer")
+	body := labeled.Body.(*ast.ForStatement).Body.(*ast.BlockStatement)
+	testIdentifier(t, body.Statements[0].(*ast.BreakStatement).Label, "outer")
+	assert.Nil(t, body.Statements[1].(*ast.ContinueStatement).Label)
+}
+
+func TestForInOf(t *testing.T) {
+	program := parseProgram(t, `
+	for (const k in o) {}
+	for (x of [1, 2]) x
+	for (var of of a) {}
+	`)
+	assert.Equal(t, 3, len(program.Statements))
+
+	forIn := program.Statements[0].(*ast.ForInStatement)
+	testLet(t, forIn.Left.(*ast.LetStatement), "k")
+	testIdentifier(t, forIn.Right, "o")
+
+	forOf := program.Statements[1].(*ast.ForOfStatement)
+	testIdentifier(t, forOf.Left.(*ast.ExpressionStatement).Expression, "x")
+	_, ok := forOf.Right.(*ast.ArrayLiteralExpression)
+	assert.True(t, ok)
+	testIdentifier(t, forOf.Body.(*ast.ExpressionStatement).Expression, "x")
+
+	forOf = program.Statements[2].(*ast.ForOfStatement)
+	testLet(t, forOf.Left.(*ast.LetStatement), "of")
+	testIdentifier(t, forOf.Right, "a")
+
+	l := lexer.New("for (a + 1 of b) {}")
+	p := New(l)
+	p.ParseProgram()
+	assert.Equal(t, []string{"line 1, col 12: invalid left-hand side in for-in or for-of"}, p.Errors())
+}
+
+func TestSwitch(t *testing.T) {
+	program := parseProgram(t, `
+	switch (x) {
+	case 1:
+	case "a": a; break
+	default:
+	case y + 1: { b }
+	}
+	`)
+	s := program.Statements[0].(*ast.SwitchStatement)
+	testIdentifier(t, s.Discriminant, "x")
+	assert.Equal(t, 4, len(s.Cases))
+	testLiteralExpression(t, s.Cases[0].Test, 1)
+	assert.Empty(t, s.Cases[0].Consequent)
+	assert.Equal(t, 2, len(s.Cases[1].Consequent))
+	assert.Nil(t, s.Cases[2].Test)
+	testInfixExpression(t, s.Cases[3].Test, infixExpected{"y", "+", 1})
+
+	l := lexer.New("switch (x) { default: default: }")
+	p := New(l)
+	p.ParseProgram()
+	assert.Equal(t, []string{"line 1, col 23: more than one default clause in switch statement"}, p.Errors())
+}
+
+func TestTryThrow(t *testing.T) {
+	program := parseProgram(t, `
+	try { a } catch (e) { e } finally { b }
+	try {} catch {}
+	try {} finally {}
+	throw a + 1
+	`)
+	assert.Equal(t, 4, len(program.Statements))
+
+	full := program.Statements[0].(*ast.TryStatement)
+	assert.Equal(t, 1, len(full.Block.Statements))
+	testIdentifier(t, full.Param, "e")
+	assert.Equal(t, 1, len(full.Handler.Statements))
+	assert.Equal(t, 1, len(full.Finalizer.Statements))
+
+	noBinding := program.Statements[1].(*ast.TryStatement)
+	assert.Nil(t, noBinding.Param)
+	assert.NotNil(t, noBinding.Handler)
+	assert.Nil(t, noBinding.Finalizer)
+
+	noCatch := program.Statements[2].(*ast.TryStatement)
+	assert.Nil(t, noCatch.Handler)
+	assert.NotNil(t, noCatch.Finalizer)
+
+	throw := program.Statements[3].(*ast.ThrowStatement)
+	testInfixExpression(t, throw.Argument, infixExpected{"a", "+", 1})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try {} a", "line 1, col 8: expected Catch or Finally, got Identifier"},
+		{"throw\n1", "line 2, col 1: illegal newline after throw"},
+		{"try {} catch (1) {}", "line 1, col 15: expected Identifier, got Number"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestArrowFunctions(t *testing.T) {
+	program := parseProgram(t, `
+	x => x * 2;
+	(a, b) => { a };
+	() => 1;
+	async x => x;
+	async (a) => a;
+	f(x => x, 1)
+	`)
+	assert.Equal(t, 6, len(program.Statements))
+	arrow := func(i int) *ast.FunctionLiteral {
+		return program.Statements[i].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	}
+
+	f := arrow(0)
+	assert.True(t, f.Arrow)
+	assert.False(t, f.Async)
+	testIdentifier(t, f.Parameters[0].Name, "x")
+	body := f.Body.Statements[0].(*ast.ReturnStatement)
+	testInfixExpression(t, body.ReturnValue, infixExpected{"x", "*", 2})
+
+	f = arrow(1)
+	assert.Equal(t, 2, len(f.Parameters))
+	testIdentifier(t, f.Parameters[1].Name, "b")
+	testIdentifier(t, f.Body.Statements[0].(*ast.ExpressionStatement).Expression, "a")
+
+	f = arrow(2)
+	assert.Empty(t, f.Parameters)
+	testLiteralExpression(t, f.Body.Statements[0].(*ast.ReturnStatement).ReturnValue, 1)
+
+	assert.True(t, arrow(3).Async)
+	assert.True(t, arrow(4).Async)
+	testIdentifier(t, arrow(4).Parameters[0].Name, "a")
+
+	call := program.Statements[5].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	assert.Equal(t, 2, len(call.Arguments))
+	assert.True(t, call.Arguments[0].(*ast.FunctionLiteral).Arrow)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(a, 1) => a", "line 1, col 5: invalid arrow function parameter"},
+		{"(a, a) => a", "line 1, col 5: duplicate parameter 'a' in arrow function"},
+		{"()", "line 1, col 3: expected EqualGreater, got EOF"},
+		{"(a, b)", "line 1, col 3: expected RightParenthesis, got Comma"},
+		{"a\n=> a", "line 2, col 1: illegal newline before =>"},
+		{"async 1", "line 1, col 7: expected Semicolon, got Number"},
+		{"async x + 1", "line 1, col 9: expected EqualGreater, got Plus"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestAwaitExpressions(t *testing.T) {
+	program := parseProgram(t, `
+	async x => await x + 1;
+	async function f() { await g(); () => await }
+	`)
+	assert.Equal(t, 2, len(program.Statements))
+
+	f := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	infix := f.Body.Statements[0].(*ast.ReturnStatement).ReturnValue.(*ast.InfixExpression)
+	await := infix.Left.(*ast.AwaitExpression)
+	testIdentifier(t, await.Argument, "x")
+
+	f = program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	await = f.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.AwaitExpression)
+	_, ok := await.Argument.(*ast.CallExpression)
+	assert.True(t, ok)
+	arrow := f.Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	testIdentifier(t, arrow.Body.Statements[0].(*ast.ReturnStatement).ReturnValue, "await")
+}
+
+func TestContextualKeywords(t *testing.T) {
+	program := parseProgram(t, `
+	let async = 1;
+	let;
+	let = await;
+	async(a, ...b);
+	async
+	x => x;
+	async function f() {}
+	`)
+	assert.Equal(t, 7, len(program.Statements))
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	assert.Equal(t, token.Let, stmt.Token.TokenType)
+	testLet(t, stmt, "async")
+	testIdentifier(t, program.Statements[1].(*ast.ExpressionStatement).Expression, "let")
+	assignment := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.AssignmentExpression)
+	testIdentifier(t, assignment.Left, "let")
+	testIdentifier(t, assignment.Right, "await")
+
+	call := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	testIdentifier(t, call.FunctionName, "async")
+	assert.Equal(t, 2, len(call.Arguments))
+	testIdentifier(t, program.Statements[4].(*ast.ExpressionStatement).Expression, "async")
+	assert.False(t, program.Statements[5].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral).Async)
+
+	f := program.Statements[6].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	assert.True(t, f.Async)
+	testIdentifier(t, f.Name, "f")
+}
+
+func TestParametersAndSpread(t *testing.T) {
+	program := parseProgram(t, `
+	let f = function(a, b = a + 1, ...rest) {};
+	(x = 1, ...xs) => x;
+	f(...a, b);
+	[1, ...a]
+	`)
+	assert.Equal(t, 4, len(program.Statements))
+
+	f := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	assert.Equal(t, 2, len(f.Parameters))
+	testIdentifier(t, f.Parameters[0].Name, "a")
+	assert.Nil(t, f.Parameters[0].Default)
+	testInfixExpression(t, f.Parameters[1].Default, infixExpected{"a", "+", 1})
+	testIdentifier(t, f.Rest, "rest")
+
+	f = program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	assert.Equal(t, 1, len(f.Parameters))
+	testLiteralExpression(t, f.Parameters[0].Default, 1)
+	testIdentifier(t, f.Rest, "xs")
+
+	call := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	testIdentifier(t, call.Arguments[0].(*ast.SpreadElement).Argument, "a")
+	testIdentifier(t, call.Arguments[1], "b")
+
+	array := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.ArrayLiteralExpression)
+	testIdentifier(t, array.Elements[1].(*ast.SpreadElement).Argument, "a")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"function f(...a, b) {}", "line 1, col 16: rest parameter must be last formal parameter"},
+		{"function f(a b) {}", "line 1, col 14: expected Comma or RightParenthesis, got Identifier"},
+		{"(...a, b) => a", "line 1, col 6: rest parameter must be last formal parameter"},
+		{"(...a)", "line 1, col 7: expected EqualGreater, got EOF"},
+		{"(a, ...a) => a", "line 1, col 5: duplicate parameter 'a' in arrow function"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	program := parseProgram(t, `
+	let {a, b: [c, , ...d] = [], ...e} = obj;
+	[x, {y = 1}] = z;
+	function f({a}, [b] = []) {};
+	({a = 1}) => a;
+	for (const [k, v] of m) {}
+	`)
+	assert.Equal(t, 5, len(program.Statements))
+
+	let := program.Statements[0].(*ast.LetStatement)
+	assert.Nil(t, let.Name)
+	object := let.Pattern.(*ast.ObjectPattern)
+	assert.Equal(t, 2, len(object.Properties))
+	assert.Equal(t, "a", object.Properties[0].Key.(*ast.StringLiteral).Value)
+	testIdentifier(t, object.Properties[0].Target, "a")
+	array := object.Properties[1].Target.(*ast.ArrayPattern)
+	assert.Equal(t, 2, len(array.Elements))
+	assert.Nil(t, array.Elements[1])
+	testIdentifier(t, array.Rest, "d")
+	assert.NotNil(t, object.Properties[1].Default)
+	testIdentifier(t, object.Rest, "e")
+	testIdentifier(t, let.Value, "obj")
+
+	assignment := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.AssignmentExpression)
+	array = assignment.Left.(*ast.ArrayPattern)
+	testIdentifier(t, array.Elements[0].Target, "x")
+	y := array.Elements[1].Target.(*ast.ObjectPattern).Properties[0]
+	testIdentifier(t, y.Target, "y")
+	testLiteralExpression(t, y.Default, 1)
+
+	f := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	assert.IsType(t, &ast.ObjectPattern{}, f.Parameters[0].Pattern)
+	assert.IsType(t, &ast.ArrayPattern{}, f.Parameters[1].Pattern)
+	assert.NotNil(t, f.Parameters[1].Default)
+
+	arrow := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	assert.IsType(t, &ast.ObjectPattern{}, arrow.Parameters[0].Pattern)
+
+	forOf := program.Statements[4].(*ast.ForOfStatement)
+	assert.Equal(t, 2, len(ast.BoundNames(forOf.Left.(*ast.LetStatement).Target())))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a]", "line 1, col 8: missing initializer in destructuring declaration"},
+		{"let [a, ...b, c] = d", "line 1, col 9: rest element must be last element"},
+		{"[a, 1] = b", "line 1, col 1: invalid destructuring target"},
+		{"({a = 1})", "line 1, col 5: invalid shorthand property initializer"},
+		{"f({a: {b = 1}})", "line 1, col 10: invalid shorthand property initializer"},
+		{"({a: {b = 1}} = c); ({d = 1})", "line 1, col 25: invalid shorthand property initializer"},
+		{"({a, b}) => a + b; (a, {a}) => a", "line 1, col 24: duplicate parameter 'a' in arrow function"},
+		{"[1 2]", "line 1, col 4: expected Comma or RightSquareBracket, got Number"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestJumpLabels(t *testing.T) {
+	program := parseProgram(t, "a: while (true) { break\na }")
+	body := program.Statements[0].(*ast.LabeledStatement).Body.(*ast.WhileStatement).Body.(*ast.BlockStatement)
+	assert.Equal(t, 2, len(body.Statements), "a line terminator ends break")
+	assert.Nil(t, body.Statements[0].(*ast.BreakStatement).Label)
+
+	l := lexer.New("for (const i; ;) {}")
+	p := New(l)
+	p.ParseProgram()
+	assert.Equal(t, []string{"line 1, col 13: missing initializer in const declaration"}, p.Errors())
+}
+
 func testLet(t *testing.T, s *ast.LetStatement, name string) bool {
 	assert.Equal(t, s.Name.Value, name)
 	return true
@@ -248,6 +934,13 @@ func TestInfix(t *testing.T) {
 	}{
 		{"1 < 2", infixExpected{1, "<", 2}},
 		{"1 > 2", infixExpected{1, ">", 2}},
+		{"1 % 2", infixExpected{1, "%", 2}},
+		{"1 ** 2", infixExpected{1, "**", 2}},
+		{"1 << 2", infixExpected{1, "<<", 2}},
+		{"1 >>> 2", infixExpected{1, ">>>", 2}},
+		{"1 & 2", infixExpected{1, "&", 2}},
+		{"1 | 2", infixExpected{1, "|", 2}},
+		{"1 ^ 2", infixExpected{1, "^", 2}},
 	}
 
 	for _, tt := range tests {
@@ -264,6 +957,255 @@ func TestInfix(t *testing.T) {
 
 }
 
+func TestOperatorPrecedence(t *testing.T) {
+	program := parseProgram(t, "a | b ^ c & d == e; a << b + c; a * b ** c ** d")
+
+	or := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	assert.Equal(t, "|", or.Operator)
+	xor := or.Right.(*ast.InfixExpression)
+	assert.Equal(t, "^", xor.Operator)
+	and := xor.Right.(*ast.InfixExpression)
+	assert.Equal(t, "&", and.Operator)
+	testInfixExpression(t, and.Right, infixExpected{"d", "==", "e"})
+
+	shift := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	assert.Equal(t, "<<", shift.Operator)
+	testInfixExpression(t, shift.Right, infixExpected{"b", "+", "c"})
+
+	product := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	assert.Equal(t, "*", product.Operator)
+	exponent := product.Right.(*ast.InfixExpression)
+	testIdentifier(t, exponent.Left, "b")
+	// ** is right-associative
+	testInfixExpression(t, exponent.Right, infixExpected{"c", "**", "d"})
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	program := parseProgram(t, "a || b && c | d; a ?? b ?? c | d; (a || b) ?? (c && d); a = b || c")
+
+	or := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.LogicalExpression)
+	assert.Equal(t, "||", or.Operator)
+	testIdentifier(t, or.Left, "a")
+	and := or.Right.(*ast.LogicalExpression)
+	assert.Equal(t, "&&", and.Operator)
+	testIdentifier(t, and.Left, "b")
+	testInfixExpression(t, and.Right, infixExpected{"c", "|", "d"})
+
+	// ?? is left-associative
+	nullish := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.LogicalExpression)
+	assert.Equal(t, "??", nullish.Operator)
+	testInfixExpression(t, nullish.Right, infixExpected{"c", "|", "d"})
+	inner := nullish.Left.(*ast.LogicalExpression)
+	assert.Equal(t, "??", inner.Operator)
+	testIdentifier(t, inner.Left, "a")
+	testIdentifier(t, inner.Right, "b")
+
+	grouped := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.LogicalExpression)
+	assert.Equal(t, "??", grouped.Operator)
+	assert.Equal(t, "||", grouped.Left.(*ast.LogicalExpression).Operator)
+	assert.Equal(t, "&&", grouped.Right.(*ast.LogicalExpression).Operator)
+
+	assignment := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.AssignmentExpression)
+	assert.Equal(t, "||", assignment.Right.(*ast.LogicalExpression).Operator)
+
+	program = parseProgram(t, "null ?? undefined")
+	literals := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.LogicalExpression)
+	_, ok := literals.Left.(*ast.NullLiteral)
+	assert.True(t, ok)
+	_, ok = literals.Right.(*ast.UndefinedLiteral)
+	assert.True(t, ok)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a ?? b || c", "line 1, col 8: cannot mix ?? and || without parentheses"},
+		{"a ?? b && c", "line 1, col 8: cannot mix ?? and && without parentheses"},
+		{"a || b ?? c", "line 1, col 8: cannot mix || and ?? without parentheses"},
+		{"a && b ?? c", "line 1, col 8: cannot mix && and ?? without parentheses"},
+		{"a || b && c ?? d", "line 1, col 13: cannot mix && and ?? without parentheses"},
+		{"a ?? b || c ?? d", "line 1, col 8: cannot mix ?? and || without parentheses"},
+		{"a || b = c", "line 1, col 8: invalid assignment target"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tt.expected}, p.Errors(), tt.input)
+	}
+}
+
+func TestAutomaticSemicolonInsertion(t *testing.T) {
+	tests := []struct {
+		input         string
+		numStatements int
+	}{
+		{"let a = 1\nlet b = 2", 2},
+		{"let a = 1 /*\n*/ let b = 2", 2},
+		{"let f = function() { 1 }\nf()", 2},
+		{"function f() { 1 } f()", 2},
+		{"if (a) { 1 } 2", 2},
+		{"let a = b\n(c)", 1},
+		{"a\n[1]", 1},
+		{"1;;", 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		assert.Equal(t, tt.numStatements, len(program.Statements), tt.input)
+	}
+
+	program := parseProgram(t, "let a = b\n(c)")
+	let := program.Statements[0].(*ast.LetStatement)
+	_, ok := let.Value.(*ast.CallExpression)
+	assert.True(t, ok, "a line terminator before ( doesn't end the statement")
+}
+
+func TestDirectivePrologue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"use strict"; 010`, []string{"line 1, col 15: legacy octal literals are not allowed in strict mode"}},
+		{"'use strict'\n010", []string{"line 2, col 1: legacy octal literals are not allowed in strict mode"}},
+		{`"a"; 'use strict'; "\07"`, []string{"line 1, col 20: octal escape sequences are not allowed here"}},
+		{`"\07"; "use strict"`, []string{"line 1, col 1: octal escape sequences are not allowed here"}},
+		{`"use strict"; let f = function() { return 010 }`, []string{"line 1, col 43: legacy octal literals are not allowed in strict mode"}},
+		{`let f = function() { "use strict"; return 010 }`, []string{"line 1, col 43: legacy octal literals are not allowed in strict mode"}},
+		{`let f = () => { "use strict"; 010 }`, []string{"line 1, col 31: legacy octal literals are not allowed in strict mode"}},
+		{`let f = function() { "use strict" }; 010`, nil},
+		{`let f = function() { "use strict"; { 1 } }; 010`, nil},
+		{`010; "use strict"; 010`, nil},
+		{`("use strict"); 010`, nil},
+		{`"use strict" + 1; 010`, nil},
+		{`"use\x20strict"; 010`, nil},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		assert.Equal(t, tt.expected, p.Errors(), tt.input)
+	}
+}
+
+func TestRestrictedProductions(t *testing.T) {
+	program := parseProgram(t, `
+	let f = function() {
+		return
+		1
+	}`)
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	assert.Equal(t, 2, len(fn.Body.Statements))
+	returnStmt := fn.Body.Statements[0].(*ast.ReturnStatement)
+	assert.Nil(t, returnStmt.ReturnValue)
+	stmt := fn.Body.Statements[1].(*ast.ExpressionStatement)
+	testIntegerLiteral(t, stmt.Expression, 1)
+}
+
+func TestMissingSemicolon(t *testing.T) {
+	l := lexer.New("let a = 1 var b = 2")
+	p := New(l)
+	p.ParseProgram()
+
+	assert.Equal(t, []string{"line 1, col 11: expected Semicolon, got Var"}, p.Errors())
+}
+
+func TestComments(t *testing.T) {
+	input := `
+	// one
+	let one = 1; /* trailing */
+	/* two */ let two = one // trailing
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	assert.Equal(t, 2, len(program.Statements))
+	one := program.Statements[0].(*ast.LetStatement)
+	assert.Equal(t, " one", one.Token.LeadingComments[0].Text)
+	two := program.Statements[1].(*ast.LetStatement)
+	assert.Equal(t, " two ", two.Token.LeadingComments[0].Text)
+	testIdentifier(t, two.Value, "one")
+}
+
+func TestScanErrors(t *testing.T) {
+	input := "let a = 1 # 2; let b = 'c"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	assert.Equal(t, 2, len(errors))
+	assert.Contains(t, errors[0], "unexpected char: #")
+	assert.Contains(t, errors[1], "unterminated string literal")
+}
+
+func TestParseErrors(t *testing.T) {
+	l := lexer.New("let a = 1;\nvar = 2")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.ParseErrors()
+	assert.Equal(t, 1, len(errors))
+	e := errors[0]
+	assert.Equal(t, []token.TokenType{token.Identifier}, e.Expected)
+	assert.Equal(t, token.Equal, e.Actual.TokenType)
+	assert.Equal(t, uint(2), e.Line)
+	assert.Equal(t, uint(5), e.Col)
+	assert.Equal(t, "2:5", l.File().Position(e.Pos).String())
+	assert.Equal(t, "line 2, col 5: expected Identifier, got Equal", e.Error())
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let a = ;
+let b = 1
+let c = {x y};
+let d = function(a, 1) { return a };
+let e = function() {
+	let f = ) ;
+	return 2
+}
+let g = 3 # 4
+let h = (1 + 2
+let i = 5;
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	assert.Equal(t, []string{
+		"line 1, col 9: unexpected Semicolon, expected an expression",
+		"line 3, col 12: expected Colon or LeftParenthesis or Comma or RightBracket, got Identifier",
+		"line 4, col 21: expected Identifier, got Number",
+		"line 6, col 10: unexpected RightParenthesis, expected an expression",
+		"line 9, col 11: unexpected char: #",
+		"line 11, col 1: expected RightParenthesis, got Identifier",
+	}, p.Errors())
+
+	var names []string
+	for _, stmt := range program.Statements {
+		names = append(names, stmt.(*ast.LetStatement).Name.Value)
+	}
+	assert.Equal(t, []string{"b", "e", "i"}, names)
+	e := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	assert.Equal(t, 1, len(e.Body.Statements))
+}
+
+func TestUnterminatedBlock(t *testing.T) {
+	l := lexer.New("let f = function() { return 1")
+	p := New(l)
+	p.ParseProgram()
+
+	assert.Equal(t, []string{"line 1, col 30: expected RightBracket, got EOF"}, p.Errors())
+}
+
 // MARK: Helpers
 
 type infixExpected struct {
@@ -299,6 +1241,8 @@ func testLiteralExpression(
 		return testIntegerLiteral(t, exp, int64(v))
 	case int64:
 		return testIntegerLiteral(t, exp, v)
+	case float64:
+		return testNumericLiteral(t, exp, v)
 	case string:
 		return testIdentifier(t, exp, v)
 	case bool:
@@ -317,9 +1261,13 @@ func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
 }
 
 func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
-	integ, ok := il.(*ast.IntegerLiteral)
-	assert.True(t, ok, "expression should be IntegerLiteral")
-	assert.Equal(t, value, integ.Value)
+	return testNumericLiteral(t, il, float64(value))
+}
+
+func testNumericLiteral(t *testing.T, exp ast.Expression, value float64) bool {
+	number, ok := exp.(*ast.NumericLiteral)
+	assert.True(t, ok, "expression should be NumericLiteral")
+	assert.Equal(t, value, number.Value)
 	return true
 }
 
@@ -331,6 +1279,22 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	return true
 }
 
+func testStringLiteral(t *testing.T, exp ast.Expression, value string) bool {
+	str, ok := exp.(*ast.StringLiteral)
+	assert.True(t, ok, "exp expression should be ast.StringLiteral")
+	assert.Equal(t, value, str.Value)
+
+	return true
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	return program
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
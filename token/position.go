@@ -0,0 +1,200 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pos is a compact position in a FileSet, it is the offset in a file plus the base of the file.
+// The zero value NoPos is not in any file
+type Pos int
+
+const NoPos Pos = 0
+
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// Position is a Pos resolved by a File, Line and Column start with 1.
+// Column counts code points like Token.Col, Offset counts bytes
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns file:line:col, line:col without a file name, or - if invalid
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// File maps byte offsets of one script to lines, the scanner adds line starts while scanning
+type File struct {
+	name string
+	base int
+	size int
+	// lines are the offsets of the first byte of each line, lines[0] is always 0
+	lines []int
+	// runes are the offsets of code points of more than one byte, extra are the bytes
+	// they have beyond the first one in total up to each, so columns count code points
+	runes []int
+	extra []int
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+// Base is the Pos of offset 0
+func (f *File) Base() int {
+	return f.base
+}
+
+func (f *File) Size() int {
+	return f.size
+}
+
+func (f *File) LineCount() int {
+	return len(f.lines)
+}
+
+// AddLine records offset as the start of a new line,
+// offsets must be added in increasing order, others are ignored
+func (f *File) AddLine(offset int) {
+	if offset > f.lines[len(f.lines)-1] && offset <= f.size {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// AddRune records a code point of size bytes at offset, the scanner adds those of more than one byte.
+// Offsets must be added in increasing order, others are ignored
+func (f *File) AddRune(offset int, size int) {
+	if size <= 1 || offset >= f.size || len(f.runes) > 0 && offset <= f.runes[len(f.runes)-1] {
+		return
+	}
+	extra := size - 1
+	if len(f.extra) > 0 {
+		extra += f.extra[len(f.extra)-1]
+	}
+	f.runes = append(f.runes, offset)
+	f.extra = append(f.extra, extra)
+}
+
+// extraBytes returns the bytes beyond the first one of the code points before offset
+func (f *File) extraBytes(offset int) int {
+	i := sort.SearchInts(f.runes, offset)
+	if i == 0 {
+		return 0
+	}
+	return f.extra[i-1]
+}
+
+// Pos returns the Pos of offset, which panics if offset is out of the file
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > f.size {
+		panic(fmt.Sprintf("offset %d is out of file %s of size %d", offset, f.name, f.size))
+	}
+	return Pos(f.base + offset)
+}
+
+// Offset returns the offset of p, which panics if p is out of the file
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+f.size {
+		panic(fmt.Sprintf("pos %d is out of file %s", p, f.name))
+	}
+	return int(p) - f.base
+}
+
+func (f *File) Line(p Pos) int {
+	return f.Position(p).Line
+}
+
+func (f *File) Position(p Pos) Position {
+	if !p.IsValid() {
+		return Position{}
+	}
+	offset := f.Offset(p)
+	return f.position(offset)
+}
+
+func (f *File) position(offset int) Position {
+	i := sort.Search(len(f.lines), func(i int) bool {
+		return f.lines[i] > offset
+	}) - 1
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     i + 1,
+		Column:   offset - f.lines[i] - f.extraBytes(offset) + f.extraBytes(f.lines[i]) + 1,
+	}
+}
+
+// FileSet gives the files added to it disjoint Pos ranges,
+// so a single Pos identifies a file and an offset in it
+type FileSet struct {
+	base  int
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// Base is the base of the next added file
+func (s *FileSet) Base() int {
+	return s.base
+}
+
+// AddFile adds a file of size bytes, the Pos range of it is [base, base+size]
+func (s *FileSet) AddFile(filename string, size int) *File {
+	f := &File{
+		name:  filename,
+		base:  s.base,
+		size:  size,
+		lines: []int{0},
+	}
+	// + 1 so the EOF position of a file is not the start of the next one
+	s.base += size + 1
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file containing p, or nil
+func (s *FileSet) File(p Pos) *File {
+	i := sort.Search(len(s.files), func(i int) bool {
+		return s.files[i].base > int(p)
+	}) - 1
+	if i < 0 || !p.IsValid() {
+		return nil
+	}
+	f := s.files[i]
+	if int(p) > f.base+f.size {
+		return nil
+	}
+	return f
+}
+
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
+
+func (s *FileSet) Files() []*File {
+	return s.files
+}
@@ -1,12 +1,34 @@
 package token
 
-import "github.com/samber/lo"
-
 type Token struct {
 	TokenType TokenType
 	Literal   string
+	// Raw is the source text of literals whose Literal is decoded, e.g. strings
+	Raw string
+	// Line and Col are the start position of the token, starting with 1
+	Line uint
+	Col  uint
+	// Start and End are the byte offsets of the token in its File, End is exclusive
+	Start int
+	End   int
+	// NewLineBefore is true when a line terminator is between the previous token and this one,
+	// including one inside a multi-line comment
+	NewLineBefore bool
+	// LeadingComments are comments between the previous line terminator and the token
+	LeadingComments []Comment
+	// TrailingComments are comments after the token on the same line
+	TrailingComments []Comment
+}
+
+// Comment is skipped by the scanner, Text excludes the // or /* */ delimiters
+type Comment struct {
+	Text      string
+	Multiline bool
 	Line      uint
 	Col       uint
+	// Start and End are the byte offsets of the comment including delimiters, End is exclusive
+	Start int
+	End   int
 }
 
 func NewToken(tokenType TokenType, literal string, line uint, col uint) Token {
@@ -23,5 +45,10 @@ func (t Token) Is(tokenType TokenType) bool {
 }
 
 func (t Token) IsOneOf(tokenTypes []TokenType) bool {
-	return lo.Contains(tokenTypes, t.TokenType)
+	for _, tokenType := range tokenTypes {
+		if t.TokenType == tokenType {
+			return true
+		}
+	}
+	return false
 }
@@ -6,9 +6,20 @@ type TokenType int
 const (
 	Var TokenType = iota
 	Const
+	// Let is never scanned, the parser retags the Identifier let at the start of a declaration
 	Let
 	Number
 	String
+	// NoSubstitutionTemplate `a`
+	NoSubstitutionTemplate
+	// TemplateHead `a${
+	TemplateHead
+	// TemplateMiddle }a${
+	TemplateMiddle
+	// TemplateTail }a`
+	TemplateTail
+	// RegExp /pattern/flags
+	RegExp
 	Boolean
 	Null
 	Undefined
@@ -20,6 +31,30 @@ const (
 	Return
 	For
 	While
+	Do
+	Switch
+	Case
+	Default
+	Break
+	Continue
+	Try
+	Catch
+	Finally
+	Typeof
+	Instanceof
+	In
+	Delete
+	Void
+	Extends
+	// Yield, Async and Await are contextual, they are scanned as Identifier
+	Yield
+	Async
+	Await
+	Import
+	Export
+	Debugger
+	With
+	Enum
 	Object
 	Function
 	Comma
@@ -34,21 +69,32 @@ const (
 	Star
 	StarEqual
 	StarStar
+	StarStarEqual
 	Slash
-	SlashSlash
 	SlashEqual
-	SlashStar
+	Percent
+	PercentEqual
 	Question
+	// QuestionDot ?.
 	QuestionDot
+	QuestionQuestion
+	QuestionQuestionEqual
 	Ampersand
+	AmpersandEqual
 	AmpersandAmpersand
+	AmpersandAmpersandEqual
 	Bar
+	BarEqual
 	BarBar
+	BarBarEqual
+	Caret
+	CaretEqual
 	Tilde
 	Dot
 	DotDotDot
 	Bang
 	BangEqual
+	BangEqualEqual
 	Equal
 	EqualEqual
 	EqualEqualEqual
@@ -59,9 +105,9 @@ const (
 	GreaterGreater
 	GreaterGreaterGreater
 	GreaterGreaterEqual
+	GreaterGreaterGreaterEqual
 	Less
 	LessLess
-	LessLessLess
 	LessEqual
 	LessLessEqual
 	LeftParenthesis
@@ -70,6 +116,7 @@ const (
 	RightBracket
 	LeftSquareBracket
 	RightSquareBracket
+	// Error is an invalid token, the scanner reports why in its diagnostics
 	Error
 	Throw
 	New
@@ -78,3 +125,142 @@ const (
 	Class
 	EOF
 )
+
+// Keywords are reserved words scanned as their own token type instead of Identifier,
+// the contextual keywords let, yield, async and await are valid identifiers and not included
+var Keywords = map[string]TokenType{
+	"var":        Var,
+	"const":      Const,
+	"null":       Null,
+	"undefined":  Undefined,
+	"true":       True,
+	"false":      False,
+	"if":         If,
+	"else":       Else,
+	"return":     Return,
+	"for":        For,
+	"while":      While,
+	"do":         Do,
+	"switch":     Switch,
+	"case":       Case,
+	"default":    Default,
+	"break":      Break,
+	"continue":   Continue,
+	"try":        Try,
+	"catch":      Catch,
+	"finally":    Finally,
+	"typeof":     Typeof,
+	"instanceof": Instanceof,
+	"in":         In,
+	"delete":     Delete,
+	"void":       Void,
+	"extends":    Extends,
+	"import":     Import,
+	"export":     Export,
+	"debugger":   Debugger,
+	"with":       With,
+	"enum":       Enum,
+	"function":   Function,
+	"throw":      Throw,
+	"new":        New,
+	"this":       This,
+	"super":      Super,
+	"class":      Class,
+}
+
+type keywordEntry struct {
+	name      string
+	tokenType TokenType
+}
+
+// keywordTable is a perfect hash table of Keywords indexed by keywordHash
+var keywordTable = func() (table [128]keywordEntry) {
+	for name, tokenType := range Keywords {
+		h := keywordHash(name)
+		if table[h].name != "" {
+			panic("keyword hash collision: " + name + ", " + table[h].name)
+		}
+		table[h] = keywordEntry{name: name, tokenType: tokenType}
+	}
+	return
+}()
+
+// keywordHash is collision free for Keywords, which are 2 to 10 bytes long
+func keywordHash(name string) int {
+	return (len(name)*12 + int(name[0])*40 + int(name[1]) + int(name[len(name)-1])) & 127
+}
+
+// LookupKeyword returns Keywords[name] with a single probe and comparison,
+// name is not a keyword if ok is false
+func LookupKeyword(name string) (tokenType TokenType, ok bool) {
+	if len(name) < 2 || len(name) > 10 {
+		return Identifier, false
+	}
+	entry := &keywordTable[keywordHash(name)]
+	if entry.name != name {
+		return Identifier, false
+	}
+	return entry.tokenType, true
+}
+
+// Punctuators are all ES2023 punctuators except / and /= which may start a RegExp,
+// and } which may continue a template
+var Punctuators = map[string]TokenType{
+	"{":    LeftBracket,
+	"(":    LeftParenthesis,
+	")":    RightParenthesis,
+	"[":    LeftSquareBracket,
+	"]":    RightSquareBracket,
+	".":    Dot,
+	"...":  DotDotDot,
+	";":    Semicolon,
+	",":    Comma,
+	"<":    Less,
+	">":    Greater,
+	"<=":   LessEqual,
+	">=":   GreaterEqual,
+	"==":   EqualEqual,
+	"!=":   BangEqual,
+	"===":  EqualEqualEqual,
+	"!==":  BangEqualEqual,
+	"+":    Plus,
+	"-":    Minus,
+	"*":    Star,
+	"%":    Percent,
+	"**":   StarStar,
+	"++":   PlusPlus,
+	"--":   MinusMinus,
+	"<<":   LessLess,
+	">>":   GreaterGreater,
+	">>>":  GreaterGreaterGreater,
+	"&":    Ampersand,
+	"|":    Bar,
+	"^":    Caret,
+	"!":    Bang,
+	"~":    Tilde,
+	"&&":   AmpersandAmpersand,
+	"||":   BarBar,
+	"??":   QuestionQuestion,
+	"?":    Question,
+	"?.":   QuestionDot,
+	":":    Colon,
+	"=":    Equal,
+	"+=":   PlusEqual,
+	"-=":   MinusEqual,
+	"*=":   StarEqual,
+	"%=":   PercentEqual,
+	"**=":  StarStarEqual,
+	"<<=":  LessLessEqual,
+	">>=":  GreaterGreaterEqual,
+	">>>=": GreaterGreaterGreaterEqual,
+	"&=":   AmpersandEqual,
+	"|=":   BarEqual,
+	"^=":   CaretEqual,
+	"&&=":  AmpersandAmpersandEqual,
+	"||=":  BarBarEqual,
+	"??=":  QuestionQuestionEqual,
+	"=>":   EqualGreater,
+}
+
+// MaxPunctuatorLength is the length of the longest punctuator >>>=
+const MaxPunctuatorLength = 4
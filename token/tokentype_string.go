@@ -13,78 +13,116 @@ func _() {
 	_ = x[Let-2]
 	_ = x[Number-3]
 	_ = x[String-4]
-	_ = x[Boolean-5]
-	_ = x[Null-6]
-	_ = x[Undefined-7]
-	_ = x[True-8]
-	_ = x[False-9]
-	_ = x[Identifier-10]
-	_ = x[If-11]
-	_ = x[Else-12]
-	_ = x[Return-13]
-	_ = x[For-14]
-	_ = x[While-15]
-	_ = x[Object-16]
-	_ = x[Function-17]
-	_ = x[Comma-18]
-	_ = x[Colon-19]
-	_ = x[Semicolon-20]
-	_ = x[Plus-21]
-	_ = x[PlusPlus-22]
-	_ = x[Minus-23]
-	_ = x[MinusMinus-24]
-	_ = x[PlusEqual-25]
-	_ = x[MinusEqual-26]
-	_ = x[Star-27]
-	_ = x[StarEqual-28]
-	_ = x[StarStar-29]
-	_ = x[Slash-30]
-	_ = x[SlashSlash-31]
-	_ = x[SlashEqual-32]
-	_ = x[SlashStar-33]
-	_ = x[Question-34]
-	_ = x[QuestionDot-35]
-	_ = x[Ampersand-36]
-	_ = x[AmpersandAmpersand-37]
-	_ = x[Bar-38]
-	_ = x[BarBar-39]
-	_ = x[Tilde-40]
-	_ = x[Dot-41]
-	_ = x[DotDotDot-42]
-	_ = x[Bang-43]
-	_ = x[BangEqual-44]
-	_ = x[Equal-45]
-	_ = x[EqualEqual-46]
-	_ = x[EqualEqualEqual-47]
-	_ = x[EqualGreater-48]
-	_ = x[Greater-49]
-	_ = x[GreaterEqual-50]
-	_ = x[GreaterGreater-51]
-	_ = x[GreaterGreaterGreater-52]
-	_ = x[GreaterGreaterEqual-53]
-	_ = x[Less-54]
-	_ = x[LessLess-55]
-	_ = x[LessLessLess-56]
-	_ = x[LessEqual-57]
-	_ = x[LessLessEqual-58]
-	_ = x[LeftParenthesis-59]
-	_ = x[RightParenthesis-60]
-	_ = x[LeftBracket-61]
-	_ = x[RightBracket-62]
-	_ = x[LeftSquareBracket-63]
-	_ = x[RightSquareBracket-64]
-	_ = x[Error-65]
-	_ = x[Throw-66]
-	_ = x[New-67]
-	_ = x[This-68]
-	_ = x[Super-69]
-	_ = x[Class-70]
-	_ = x[EOF-71]
+	_ = x[NoSubstitutionTemplate-5]
+	_ = x[TemplateHead-6]
+	_ = x[TemplateMiddle-7]
+	_ = x[TemplateTail-8]
+	_ = x[RegExp-9]
+	_ = x[Boolean-10]
+	_ = x[Null-11]
+	_ = x[Undefined-12]
+	_ = x[True-13]
+	_ = x[False-14]
+	_ = x[Identifier-15]
+	_ = x[If-16]
+	_ = x[Else-17]
+	_ = x[Return-18]
+	_ = x[For-19]
+	_ = x[While-20]
+	_ = x[Do-21]
+	_ = x[Switch-22]
+	_ = x[Case-23]
+	_ = x[Default-24]
+	_ = x[Break-25]
+	_ = x[Continue-26]
+	_ = x[Try-27]
+	_ = x[Catch-28]
+	_ = x[Finally-29]
+	_ = x[Typeof-30]
+	_ = x[Instanceof-31]
+	_ = x[In-32]
+	_ = x[Delete-33]
+	_ = x[Void-34]
+	_ = x[Extends-35]
+	_ = x[Yield-36]
+	_ = x[Async-37]
+	_ = x[Await-38]
+	_ = x[Import-39]
+	_ = x[Export-40]
+	_ = x[Debugger-41]
+	_ = x[With-42]
+	_ = x[Enum-43]
+	_ = x[Object-44]
+	_ = x[Function-45]
+	_ = x[Comma-46]
+	_ = x[Colon-47]
+	_ = x[Semicolon-48]
+	_ = x[Plus-49]
+	_ = x[PlusPlus-50]
+	_ = x[Minus-51]
+	_ = x[MinusMinus-52]
+	_ = x[PlusEqual-53]
+	_ = x[MinusEqual-54]
+	_ = x[Star-55]
+	_ = x[StarEqual-56]
+	_ = x[StarStar-57]
+	_ = x[StarStarEqual-58]
+	_ = x[Slash-59]
+	_ = x[SlashEqual-60]
+	_ = x[Percent-61]
+	_ = x[PercentEqual-62]
+	_ = x[Question-63]
+	_ = x[QuestionDot-64]
+	_ = x[QuestionQuestion-65]
+	_ = x[QuestionQuestionEqual-66]
+	_ = x[Ampersand-67]
+	_ = x[AmpersandEqual-68]
+	_ = x[AmpersandAmpersand-69]
+	_ = x[AmpersandAmpersandEqual-70]
+	_ = x[Bar-71]
+	_ = x[BarEqual-72]
+	_ = x[BarBar-73]
+	_ = x[BarBarEqual-74]
+	_ = x[Caret-75]
+	_ = x[CaretEqual-76]
+	_ = x[Tilde-77]
+	_ = x[Dot-78]
+	_ = x[DotDotDot-79]
+	_ = x[Bang-80]
+	_ = x[BangEqual-81]
+	_ = x[BangEqualEqual-82]
+	_ = x[Equal-83]
+	_ = x[EqualEqual-84]
+	_ = x[EqualEqualEqual-85]
+	_ = x[EqualGreater-86]
+	_ = x[Greater-87]
+	_ = x[GreaterEqual-88]
+	_ = x[GreaterGreater-89]
+	_ = x[GreaterGreaterGreater-90]
+	_ = x[GreaterGreaterEqual-91]
+	_ = x[GreaterGreaterGreaterEqual-92]
+	_ = x[Less-93]
+	_ = x[LessLess-94]
+	_ = x[LessEqual-95]
+	_ = x[LessLessEqual-96]
+	_ = x[LeftParenthesis-97]
+	_ = x[RightParenthesis-98]
+	_ = x[LeftBracket-99]
+	_ = x[RightBracket-100]
+	_ = x[LeftSquareBracket-101]
+	_ = x[RightSquareBracket-102]
+	_ = x[Error-103]
+	_ = x[Throw-104]
+	_ = x[New-105]
+	_ = x[This-106]
+	_ = x[Super-107]
+	_ = x[Class-108]
+	_ = x[EOF-109]
 }
 
-const _TokenType_name = "VarConstLetNumberStringBooleanNullUndefinedTrueFalseIdentifierIfElseReturnForWhileObjectFunctionCommaColonSemicolonPlusPlusPlusMinusMinusMinusPlusEqualMinusEqualStarStarEqualStarStarSlashSlashSlashSlashEqualSlashStarQuestionQuestionDotAmpersandAmpersandAmpersandBarBarBarTildeDotDotDotDotBangBangEqualEqualEqualEqualEqualEqualEqualEqualGreaterGreaterGreaterEqualGreaterGreaterGreaterGreaterGreaterGreaterGreaterEqualLessLessLessLessLessLessLessEqualLessLessEqualLeftParenthesisRightParenthesisLeftBracketRightBracketLeftSquareBracketRightSquareBracketErrorThrowNewThisSuperClassEOF"
+const _TokenType_name = "VarConstLetNumberStringNoSubstitutionTemplateTemplateHeadTemplateMiddleTemplateTailRegExpBooleanNullUndefinedTrueFalseIdentifierIfElseReturnForWhileDoSwitchCaseDefaultBreakContinueTryCatchFinallyTypeofInstanceofInDeleteVoidExtendsYieldAsyncAwaitImportExportDebuggerWithEnumObjectFunctionCommaColonSemicolonPlusPlusPlusMinusMinusMinusPlusEqualMinusEqualStarStarEqualStarStarStarStarEqualSlashSlashEqualPercentPercentEqualQuestionQuestionDotQuestionQuestionQuestionQuestionEqualAmpersandAmpersandEqualAmpersandAmpersandAmpersandAmpersandEqualBarBarEqualBarBarBarBarEqualCaretCaretEqualTildeDotDotDotDotBangBangEqualBangEqualEqualEqualEqualEqualEqualEqualEqualEqualGreaterGreaterGreaterEqualGreaterGreaterGreaterGreaterGreaterGreaterGreaterEqualGreaterGreaterGreaterEqualLessLessLessLessEqualLessLessEqualLeftParenthesisRightParenthesisLeftBracketRightBracketLeftSquareBracketRightSquareBracketErrorThrowNewThisSuperClassEOF"
 
-var _TokenType_index = [...]uint16{0, 3, 8, 11, 17, 23, 30, 34, 43, 47, 52, 62, 64, 68, 74, 77, 82, 88, 96, 101, 106, 115, 119, 127, 132, 142, 151, 161, 165, 174, 182, 187, 197, 207, 216, 224, 235, 244, 262, 265, 271, 276, 279, 288, 292, 301, 306, 316, 331, 343, 350, 362, 376, 397, 416, 420, 428, 440, 449, 462, 477, 493, 504, 516, 533, 551, 556, 561, 564, 568, 573, 578, 581}
+var _TokenType_index = [...]uint16{0, 3, 8, 11, 17, 23, 45, 57, 71, 83, 89, 96, 100, 109, 113, 118, 128, 130, 134, 140, 143, 148, 150, 156, 160, 167, 172, 180, 183, 188, 195, 201, 211, 213, 219, 223, 230, 235, 240, 245, 251, 257, 265, 269, 273, 279, 287, 292, 297, 306, 310, 318, 323, 333, 342, 352, 356, 365, 373, 386, 391, 401, 408, 420, 428, 439, 455, 476, 485, 499, 517, 540, 543, 551, 557, 568, 573, 583, 588, 591, 600, 604, 613, 627, 632, 642, 657, 669, 676, 688, 702, 723, 742, 768, 772, 780, 789, 802, 817, 833, 844, 856, 873, 891, 896, 901, 904, 908, 913, 918, 921}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
@@ -0,0 +1,148 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Seeingu/coldmoon/object"
+)
+
+// coroutine is the stack and frames of an async function call, kept while an await suspends it
+type coroutine struct {
+	stack      []object.Object
+	sp         int
+	frames     []*Frame
+	frameIndex int
+	// promise is the result of the call, settled when the function returns or throws
+	promise *object.PromiseObject
+}
+
+// errSuspended ends the run loop of a coroutine at an await, the coroutine is resumed by a job
+var errSuspended = errors.New("async function suspended")
+
+// callAsync replaces the callee and numArgs arguments on the stack with the promise of the call,
+// the async function runs on a new coroutine until its first await
+func (vm *VM) callAsync(cl *object.Closure, numArgs int, this object.Object) error {
+	co := &coroutine{
+		stack:      make([]object.Object, StackSize),
+		frames:     make([]*Frame, MaxFrames),
+		frameIndex: 1,
+		promise:    &object.PromiseObject{},
+	}
+	// the function returns to an empty main frame
+	co.frames[0] = NewFrame(&object.Closure{Fn: &object.CompiledFunction{}}, 0)
+	co.sp = copy(co.stack, vm.stack[vm.sp-1-numArgs:vm.sp])
+	vm.sp -= numArgs + 1
+	err := vm.switchTo(co, func() error {
+		return vm.callClosure(cl, numArgs, this)
+	})
+	if err != nil {
+		return err
+	}
+	return vm.push(co.promise)
+}
+
+// resume continues co suspended at an await with the value of the settled promise, a rejection is thrown
+func (vm *VM) resume(co *coroutine, promise *object.PromiseObject) error {
+	return vm.switchTo(co, func() error {
+		if promise.State == object.PromiseRejected {
+			return &Exception{Value: promise.Value}
+		}
+		return vm.push(promise.Value)
+	})
+}
+
+// switchTo runs co after start until it returns, throws or awaits, the stack and frames of the caller are kept.
+// The promise of co is settled when the function returns or throws
+func (vm *VM) switchTo(co *coroutine, start func() error) error {
+	stack, sp, frames, frameIndex, caller := vm.stack, vm.sp, vm.frames, vm.frameIndex, vm.coroutine
+	vm.stack, vm.sp, vm.frames, vm.frameIndex, vm.coroutine = co.stack, co.sp, co.frames, co.frameIndex, co
+	defer func() {
+		co.sp, co.frameIndex = vm.sp, vm.frameIndex
+		vm.stack, vm.sp, vm.frames, vm.frameIndex, vm.coroutine = stack, sp, frames, frameIndex, caller
+	}()
+
+	err := start()
+	if err != nil {
+		err = vm.throw(err, 1)
+	}
+	if err == nil {
+		err = vm.run(1)
+	}
+	var exception *Exception
+	switch {
+	case err == nil:
+		vm.resolve(co.promise, vm.pop())
+	case errors.As(err, &exception):
+		vm.settle(co.promise, object.PromiseRejected, exception.Value)
+	case err != errSuspended:
+		return err
+	}
+	return nil
+}
+
+// await suspends the current coroutine until value is settled, a value other than a promise is fulfilled already
+func (vm *VM) await(value object.Object) error {
+	promise, ok := value.(*object.PromiseObject)
+	if !ok {
+		promise = &object.PromiseObject{State: object.PromiseFulfilled, Value: value}
+	}
+	co := vm.coroutine
+	vm.then(promise, func() error {
+		return vm.resume(co, promise)
+	})
+	return errSuspended
+}
+
+// resolve fulfills promise with value, a promise value is followed until it is settled
+func (vm *VM) resolve(promise *object.PromiseObject, value object.Object) {
+	other, ok := value.(*object.PromiseObject)
+	if !ok {
+		vm.settle(promise, object.PromiseFulfilled, value)
+		return
+	}
+	if other == promise {
+		vm.settle(promise, object.PromiseRejected, errorObject(fmt.Errorf("TypeError: chaining cycle detected for promise")))
+		return
+	}
+	vm.then(other, func() error {
+		vm.settle(promise, other.State, other.Value)
+		return nil
+	})
+}
+
+// settle fulfills or rejects a pending promise and queues the jobs of its reactions
+func (vm *VM) settle(promise *object.PromiseObject, state object.PromiseState, value object.Object) {
+	if promise.State != object.PromisePending {
+		return
+	}
+	promise.State, promise.Value = state, value
+	for _, reaction := range promise.Reactions {
+		reaction()
+	}
+	promise.Reactions = nil
+}
+
+// then queues job once promise is settled
+func (vm *VM) then(promise *object.PromiseObject, job func() error) {
+	if promise.State != object.PromisePending {
+		vm.jobs = append(vm.jobs, job)
+		return
+	}
+	promise.Reactions = append(promise.Reactions, func() {
+		vm.jobs = append(vm.jobs, job)
+	})
+}
+
+// runJobs runs queued jobs in order until none is left, including the ones they queue
+func (vm *VM) runJobs() error {
+	for len(vm.jobs) > 0 {
+		job := vm.jobs[0]
+		vm.jobs = vm.jobs[1:]
+		err := job()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
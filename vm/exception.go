@@ -0,0 +1,69 @@
+package vm
+
+import (
+	"github.com/Seeingu/coldmoon/object"
+	"strings"
+)
+
+// Exception is a thrown value, returned by Run if no handler catches it
+type Exception struct {
+	Value object.Object
+	// err is the runtime fault thrown as Value
+	err error
+}
+
+func (e *Exception) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return "Uncaught " + toString(e.Value)
+}
+
+// throw unwinds the frames above depth to the nearest handler of err, iterators of loops left are closed.
+// The exception is returned if no handler catches it, the main frame stays for inspection
+func (vm *VM) throw(err error, depth int) error {
+	exception, ok := err.(*Exception)
+	if !ok {
+		exception = &Exception{Value: errorObject(err), err: err}
+	}
+	for vm.frameIndex > depth {
+		frame := vm.currentFrame()
+		base := frame.basePointer + frame.cl.Fn.NumLocals
+		if handler, ok := frame.handler(); ok {
+			vm.closeIteratorsAbove(base + handler.StackDepth)
+			vm.sp = base + handler.StackDepth
+			frame.ip = handler.Target - 1
+			return vm.push(exception.Value)
+		}
+		vm.closeIteratorsAbove(base)
+		if vm.frameIndex == 1 {
+			break
+		}
+		vm.popFrame()
+		vm.sp = frame.basePointer - 1
+	}
+	return exception
+}
+
+// closeIteratorsAbove closes the iterators of loops on the stack from sp down to bottom,
+// errors of return methods are dropped for the exception being thrown
+func (vm *VM) closeIteratorsAbove(bottom int) {
+	for i := vm.sp - 1; i >= bottom; i-- {
+		if iterator, ok := vm.stack[i].(*object.Iterator); ok {
+			_ = vm.closeIterator(iterator)
+		}
+	}
+}
+
+// errorObject is the value thrown for a runtime fault, an object of the name and message of err,
+// e.g. {name: "TypeError", message: "1 is not a function"}
+func errorObject(err error) object.Object {
+	name, message := "Error", err.Error()
+	if before, after, ok := strings.Cut(message, ": "); ok && strings.HasSuffix(before, "Error") && !strings.Contains(before, " ") {
+		name, message = before, after
+	}
+	o := object.NewObject()
+	o.Set(&object.StringObject{Value: "name"}, &object.StringObject{Value: name})
+	o.Set(&object.StringObject{Value: "message"}, &object.StringObject{Value: message})
+	return o
+}
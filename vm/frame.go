@@ -9,6 +9,10 @@ type Frame struct {
 	cl          *object.Closure
 	ip          int
 	basePointer int
+	// this is the receiver of a method call, undefined for other calls
+	this object.Object
+	// arguments are all the arguments passed, for a function using its arguments object
+	arguments *object.ArrayObject
 }
 
 func NewFrame(cl *object.Closure, basePointer int) *Frame {
@@ -16,9 +20,20 @@ func NewFrame(cl *object.Closure, basePointer int) *Frame {
 		cl:          cl,
 		ip:          -1,
 		basePointer: basePointer,
+		this:        JSUndefined,
 	}
 }
 
 func (f *Frame) Instructions() code.Instructions {
 	return f.cl.Fn.Instructions
 }
+
+// handler returns the innermost handler covering the instruction at ip
+func (f *Frame) handler() (object.Handler, bool) {
+	for _, h := range f.cl.Fn.Handlers {
+		if f.ip >= h.Start && f.ip < h.End {
+			return h, true
+		}
+	}
+	return object.Handler{}, false
+}
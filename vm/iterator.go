@@ -0,0 +1,185 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/Seeingu/coldmoon/object"
+	"strconv"
+	"unicode/utf16"
+)
+
+var (
+	nextKey   = &object.StringObject{Value: "next"}
+	returnKey = &object.StringObject{Value: "return"}
+	doneKey   = &object.StringObject{Value: "done"}
+	valueKey  = &object.StringObject{Value: "value"}
+)
+
+// getIterator returns the iterator record of obj for for-of,
+// arrays and strings are iterated natively, an object by the iterator of its Symbol.iterator method
+func (vm *VM) getIterator(obj object.Object) (*object.Iterator, error) {
+	switch obj := obj.(type) {
+	case *object.ArrayObject:
+		i := 0
+		// elements added while iterating are visited
+		return &object.Iterator{Step: func() (object.Object, bool) {
+			if i >= len(obj.Elements) {
+				return nil, false
+			}
+			i++
+			return obj.Elements[i-1], true
+		}}, nil
+	case *object.StringObject:
+		var values []object.Object
+		for _, r := range obj.Value {
+			values = append(values, &object.StringObject{Value: string(r)})
+		}
+		return valuesIterator(values), nil
+	case *object.ObjectObject:
+		method, ok := obj.Get(object.SymbolIterator)
+		if !ok {
+			break
+		}
+		target, err := vm.call(method, obj)
+		if err != nil {
+			return nil, err
+		}
+		iterator, ok := target.(*object.ObjectObject)
+		if !ok {
+			return nil, fmt.Errorf("TypeError: result of the Symbol.iterator method is not an object")
+		}
+		next, _ := iterator.Get(nextKey)
+		return &object.Iterator{Target: iterator, Next: next}, nil
+	}
+	return nil, fmt.Errorf("TypeError: %s is not iterable", toString(obj))
+}
+
+// iteratorStep returns the next value of iterator, false when it is done
+func (vm *VM) iteratorStep(iterator *object.Iterator) (object.Object, bool, error) {
+	if iterator.Step != nil {
+		value, ok := iterator.Step()
+		return value, ok, nil
+	}
+	result, err := vm.call(iterator.Next, iterator.Target)
+	if err != nil {
+		return nil, false, err
+	}
+	r, ok := result.(*object.ObjectObject)
+	if !ok {
+		return nil, false, fmt.Errorf("TypeError: iterator result %s is not an object", toString(result))
+	}
+	if done, ok := r.Get(doneKey); ok && isTruthy(done) {
+		return nil, false, nil
+	}
+	value, ok := r.Get(valueKey)
+	if !ok {
+		value = JSUndefined
+	}
+	return value, true, nil
+}
+
+// iteratorValue returns the next value of iterator for destructuring, undefined when it is done.
+// An iterator throwing is done, so it is not closed
+func (vm *VM) iteratorValue(iterator *object.Iterator) (object.Object, error) {
+	if iterator.Done {
+		return JSUndefined, nil
+	}
+	value, ok, err := vm.iteratorStep(iterator)
+	if err != nil || !ok {
+		iterator.Done = true
+		return JSUndefined, err
+	}
+	return value, nil
+}
+
+// closeIterator calls the return method of the iterator object, if there is one and it is not done
+func (vm *VM) closeIterator(iterator *object.Iterator) error {
+	if iterator.Done {
+		return nil
+	}
+	target, ok := iterator.Target.(*object.ObjectObject)
+	if !ok {
+		return nil
+	}
+	method, ok := target.Get(returnKey)
+	if !ok || method == JSUndefined || method == JSNull {
+		return nil
+	}
+	_, err := vm.call(method, target)
+	return err
+}
+
+// closeIterators closes the iterators of loops a return exits, innermost first,
+// iterator records on the stack above the locals of current frame belong to running loops
+func (vm *VM) closeIterators() error {
+	frame := vm.currentFrame()
+	for i := vm.sp - 1; i >= frame.basePointer+frame.cl.Fn.NumLocals; i-- {
+		if iterator, ok := vm.stack[i].(*object.Iterator); ok {
+			err := vm.closeIterator(iterator)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// objectRest returns a new object of the own properties of obj except the excluded keys, for {a, ...rest}
+func objectRest(obj object.Object, excluded map[object.HashKey]bool) *object.ObjectObject {
+	rest := object.NewObject()
+	switch obj := obj.(type) {
+	case *object.ObjectObject:
+		for _, key := range obj.Keys() {
+			if !excluded[key.HashKey()] {
+				rest.Set(key, obj.Pairs[key.HashKey()].Value)
+			}
+		}
+	case *object.ArrayObject:
+		for i, element := range obj.Elements {
+			key := &object.StringObject{Value: strconv.Itoa(i)}
+			if !excluded[key.HashKey()] {
+				rest.Set(key, element)
+			}
+		}
+	}
+	return rest
+}
+
+// enumerate returns an iterator record of the enumerable string keys of obj and its prototypes for for-in,
+// a key shadowed by an earlier object is visited once
+func enumerate(obj object.Object) *object.Iterator {
+	var keys []object.Object
+	switch obj := obj.(type) {
+	case *object.ObjectObject:
+		visited := make(map[object.HashKey]bool)
+		for o := obj; o != nil; o = o.Prototype {
+			for _, key := range o.Keys() {
+				if _, ok := key.(*object.StringObject); !ok || visited[key.HashKey()] {
+					continue
+				}
+				visited[key.HashKey()] = true
+				keys = append(keys, key)
+			}
+		}
+	case *object.ArrayObject:
+		// named properties of arrays aren't enumerable, e.g. raw of a template strings array
+		for i := range obj.Elements {
+			keys = append(keys, &object.StringObject{Value: strconv.Itoa(i)})
+		}
+	case *object.StringObject:
+		for i := range utf16.Encode([]rune(obj.Value)) {
+			keys = append(keys, &object.StringObject{Value: strconv.Itoa(i)})
+		}
+	}
+	return valuesIterator(keys)
+}
+
+func valuesIterator(values []object.Object) *object.Iterator {
+	i := 0
+	return &object.Iterator{Step: func() (object.Object, bool) {
+		if i >= len(values) {
+			return nil, false
+		}
+		i++
+		return values[i-1], true
+	}}
+}
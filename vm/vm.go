@@ -1,10 +1,17 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Seeingu/coldmoon/code"
 	"github.com/Seeingu/coldmoon/compiler"
 	"github.com/Seeingu/coldmoon/object"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
 )
 
 var JSTrue = &object.BooleanObject{Value: true}
@@ -27,10 +34,19 @@ type VM struct {
 
 	frames     []*Frame
 	frameIndex int
+
+	// coroutine is the async function call running, nil in the main program
+	coroutine *coroutine
+	// jobs run after the main program, they resume async functions once awaited values are settled
+	jobs []func() error
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		NumLocals:    bytecode.NumLocals,
+		Handlers:     bytecode.Handlers,
+	}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
@@ -40,18 +56,43 @@ func New(bytecode *compiler.Bytecode) *VM {
 	return &VM{
 		constants:  bytecode.Constants,
 		stack:      make([]object.Object, StackSize),
-		sp:         0,
+		sp:         mainFn.NumLocals,
 		globals:    make([]object.Object, GlobalsSize),
 		frames:     frames,
 		frameIndex: 1,
 	}
 }
 
+// Run executes the program, then the jobs of async functions until none is left
 func (vm *VM) Run() error {
+	err := vm.run(0)
+	if err != nil {
+		return err
+	}
+	return vm.runJobs()
+}
+
+// run executes instructions until the frames above depth return, the main frame runs to its end.
+// An exception not caught by these frames is returned
+func (vm *VM) run(depth int) error {
+	for {
+		err := vm.execute(depth)
+		if err == nil || err == errSuspended {
+			return err
+		}
+		err = vm.throw(err, depth)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// execute runs instructions like run, until an error
+func (vm *VM) execute(depth int) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+	for vm.frameIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
 		vm.currentFrame().ip++
 
 		ip = vm.currentFrame().ip
@@ -66,7 +107,9 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpExp,
+			code.OpShiftLeft, code.OpShiftRight, code.OpUnsignedShiftRight,
+			code.OpBitwiseAnd, code.OpBitwiseOr, code.OpBitwiseXor:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
@@ -107,6 +150,34 @@ func (vm *VM) Run() error {
 			if !isTruthy(condition) {
 				vm.currentFrame().ip = pos - 1
 			}
+		case code.OpJumpNotUndefined:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.pop() != JSUndefined {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpTrue:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if isTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpNullish:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if value := vm.pop(); value == JSUndefined || value == JSNull {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpNotNullish:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if value := vm.pop(); value != JSUndefined && value != JSNull {
+				vm.currentFrame().ip = pos - 1
+			}
 		case code.OpNull:
 			err := vm.push(JSNull)
 			if err != nil {
@@ -136,6 +207,26 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpAppend:
+			value := vm.pop()
+			array := vm.StackTop().(*object.ArrayObject)
+			array.Elements = append(array.Elements, value)
+		case code.OpAppendSpread:
+			iterator, err := vm.getIterator(vm.pop())
+			if err != nil {
+				return err
+			}
+			array := vm.StackTop().(*object.ArrayObject)
+			for {
+				value, ok, err := vm.iteratorStep(iterator)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					break
+				}
+				array.Elements = append(array.Elements, value)
+			}
 		case code.OpObject:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -158,28 +249,98 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpGetProperty:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value, err := getProperty(vm.pop(), vm.constants[constIndex].(*object.StringObject))
+			if err != nil {
+				return err
+			}
+			err = vm.push(value)
+			if err != nil {
+				return err
+			}
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
 
-			err := vm.executeCall(int(numArgs))
+			err := vm.executeCall(int(numArgs), JSUndefined)
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallSpread:
+			numArgs, err := vm.spreadArguments()
+			if err != nil {
+				return err
+			}
+			err = vm.executeCall(numArgs, JSUndefined)
+			if err != nil {
+				return err
+			}
+		case code.OpCallMethod:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			this := vm.removeReceiver(numArgs)
+			err := vm.executeCall(numArgs, this)
+			if err != nil {
+				return err
+			}
+		case code.OpCallMethodSpread:
+			numArgs, err := vm.spreadArguments()
+			if err != nil {
+				return err
+			}
+			this := vm.removeReceiver(numArgs)
+			err = vm.executeCall(numArgs, this)
+			if err != nil {
+				return err
+			}
+		case code.OpThis:
+			err := vm.push(vm.currentFrame().this)
+			if err != nil {
+				return err
+			}
+		case code.OpArguments:
+			err := vm.push(vm.currentFrame().arguments)
+			if err != nil {
+				return err
+			}
+		case code.OpAwait:
+			return vm.await(vm.pop())
+		case code.OpToNumeric:
+			value, err := toNumber(vm.pop())
+			if err != nil {
+				return err
+			}
+			err = vm.push(object.NewNumber(value))
 			if err != nil {
 				return err
 			}
 
 		case code.OpReturnValue:
 			returnValue := vm.pop()
+			err := vm.closeIterators()
+			if err != nil {
+				return err
+			}
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
 
-			err := vm.push(returnValue)
+			err = vm.push(returnValue)
 			if err != nil {
 				return err
 			}
 		case code.OpReturn:
+			err := vm.closeIterators()
+			if err != nil {
+				return err
+			}
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
-			err := vm.push(JSUndefined)
+			err = vm.push(JSUndefined)
 			if err != nil {
 				return err
 			}
@@ -198,6 +359,44 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpNewCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = &object.Cell{}
+		case code.OpGetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			cell := vm.stack[frame.basePointer+int(localIndex)].(*object.Cell)
+			err := vm.push(cell.Value)
+			if err != nil {
+				return err
+			}
+		case code.OpSetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			cell := vm.stack[frame.basePointer+int(localIndex)].(*object.Cell)
+			cell.Value = vm.pop()
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell := vm.currentFrame().cl.Free[freeIndex].(*object.Cell)
+			err := vm.push(cell.Value)
+			if err != nil {
+				return err
+			}
+		case code.OpSetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell := vm.currentFrame().cl.Free[freeIndex].(*object.Cell)
+			cell.Value = vm.pop()
 		case code.OpGetBuiltin:
 			builtinIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
@@ -224,12 +423,145 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpRegExp:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			re := vm.constants[constIndex].(*object.RegExpObject)
+			err := vm.push(&object.RegExpObject{Pattern: re.Pattern, Flags: re.Flags, Regexp: re.Regexp})
+			if err != nil {
+				return err
+			}
 		case code.OpCurrentClosure:
 			currentClosure := vm.currentFrame().cl
 			err := vm.push(currentClosure)
 			if err != nil {
 				return err
 			}
+		case code.OpUndefined:
+			err := vm.push(JSUndefined)
+			if err != nil {
+				return err
+			}
+		case code.OpCheckInitialized:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			// slots of let and const are nil until initialized
+			if vm.StackTop() == nil {
+				return referenceError(vm.constants[constIndex])
+			}
+		case code.OpReferenceError:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			return referenceError(vm.constants[constIndex])
+		case code.OpGetIterator:
+			iterator, err := vm.getIterator(vm.pop())
+			if err != nil {
+				return err
+			}
+			err = vm.push(iterator)
+			if err != nil {
+				return err
+			}
+		case code.OpEnumerate:
+			err := vm.push(enumerate(vm.pop()))
+			if err != nil {
+				return err
+			}
+		case code.OpIteratorNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			value, ok, err := vm.iteratorStep(vm.StackTop().(*object.Iterator))
+			if err != nil {
+				// an iterator throwing is not closed
+				vm.pop()
+				return err
+			}
+			if !ok {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+			err = vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpIteratorClose:
+			err := vm.closeIterator(vm.pop().(*object.Iterator))
+			if err != nil {
+				return err
+			}
+		case code.OpIteratorValue:
+			value, err := vm.iteratorValue(vm.StackTop().(*object.Iterator))
+			if err != nil {
+				return err
+			}
+			err = vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpIteratorRest:
+			iterator := vm.StackTop().(*object.Iterator)
+			rest := &object.ArrayObject{Elements: []object.Object{}}
+			for !iterator.Done {
+				value, err := vm.iteratorValue(iterator)
+				if err != nil {
+					return err
+				}
+				if !iterator.Done {
+					rest.Elements = append(rest.Elements, value)
+				}
+			}
+			err := vm.push(rest)
+			if err != nil {
+				return err
+			}
+		case code.OpObjectRest:
+			numKeys := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			excluded := make(map[object.HashKey]bool)
+			for _, key := range vm.stack[vm.sp-numKeys : vm.sp] {
+				excluded[propertyKey(key).HashKey()] = true
+			}
+			vm.sp -= numKeys
+			err := vm.push(objectRest(vm.pop(), excluded))
+			if err != nil {
+				return err
+			}
+		case code.OpCheckObjectCoercible:
+			if value := vm.StackTop(); value == JSUndefined || value == JSNull {
+				return fmt.Errorf("TypeError: cannot destructure %s", toString(value))
+			}
+		case code.OpDup:
+			err := vm.push(vm.StackTop())
+			if err != nil {
+				return err
+			}
+		case code.OpThrow:
+			return &Exception{Value: vm.pop()}
+		case code.OpCase:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			test := vm.pop()
+			if strictEquals(vm.StackTop(), test) {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpTable:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			table := vm.constants[constIndex].(*object.JumpTable)
+			pos := table.Default
+			if key, ok := caseKey(vm.pop()); ok {
+				if target, ok := table.Targets[key]; ok {
+					pos = target
+				}
+			}
+			vm.currentFrame().ip = pos - 1
 		}
 	}
 	return nil
@@ -267,35 +599,26 @@ func (vm *VM) popFrame() *Frame {
 // MARK: Execute
 
 func (vm *VM) executeNot() error {
-	operand := vm.pop()
-	switch operand {
-	case JSTrue:
-		return vm.push(JSFalse)
-	case JSFalse:
-		return vm.push(JSTrue)
-	case JSNull:
-		return vm.push(JSTrue)
-	default:
-		return vm.push(JSFalse)
-
-	}
+	return vm.push(nativeBoolToBooleanObject(!isTruthy(vm.pop())))
 }
 
 func (vm *VM) executeNegate() error {
 	operand := vm.pop()
-	if operand.Type() != object.TypeInt {
-		return fmt.Errorf("negate: operand must be an integer")
+	value, ok := numberValue(operand)
+	if !ok {
+		return fmt.Errorf("negate: operand must be a number")
 	}
-	value := operand.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
+	return vm.push(object.NewNumber(-value))
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	if left.Type() == object.TypeInt && right.Type() == object.TypeInt {
-		return vm.executeIntegerComparison(op, left, right)
+	leftValue, leftOk := numberValue(left)
+	rightValue, rightOk := numberValue(right)
+	if leftOk && rightOk {
+		return vm.executeNumberComparison(op, leftValue, rightValue)
 	}
 
 	switch op {
@@ -308,10 +631,7 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	}
 }
 
-func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
-
+func (vm *VM) executeNumberComparison(op code.Opcode, leftValue, rightValue float64) error {
 	switch op {
 	case code.OpEqual:
 		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
@@ -322,7 +642,7 @@ func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object
 	case code.OpLessThan:
 		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
 	default:
-		return fmt.Errorf("integer comparison: unknown operator %d", op)
+		return fmt.Errorf("number comparison: unknown operator %d", op)
 	}
 
 }
@@ -331,40 +651,118 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	leftType := left.Type()
-	rightType := right.Type()
-	if leftType == object.TypeInt && rightType == object.TypeInt {
-		return vm.executeIntegerOperation(op, left, right)
+	leftValue, leftOk := numberValue(left)
+	rightValue, rightOk := numberValue(right)
+	if leftOk && rightOk {
+		return vm.executeNumberOperation(op, leftValue, rightValue)
 	}
-	if leftType == object.TypeString && rightType == object.TypeString {
+	if left.Type() == object.TypeString || right.Type() == object.TypeString {
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
 	return fmt.Errorf("unknown operator %d", op)
 
 }
 
-func (vm *VM) executeCall(numArgs int) error {
+// executeCall calls the callee under numArgs arguments on the stack with this
+func (vm *VM) executeCall(numArgs int, this object.Object) error {
 	callee := vm.stack[vm.sp-1-numArgs]
 	switch callee := callee.(type) {
 	case *object.Closure:
-		return vm.callClosure(callee, numArgs)
+		if callee.Fn.Async {
+			return vm.callAsync(callee, numArgs, this)
+		}
+		return vm.callClosure(callee, numArgs, this)
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
 	default:
-		return fmt.Errorf("unknown function call %d", callee)
+		return fmt.Errorf("TypeError: %s is not a function", toString(callee))
 	}
 }
 
-func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("expected %d parameters, got %d", numArgs, cl.Fn.NumParameters)
+func (vm *VM) callClosure(cl *object.Closure, numArgs int, this object.Object) error {
+	if vm.frameIndex >= MaxFrames {
+		return fmt.Errorf("RangeError: maximum call stack size exceeded")
+	}
+	var arguments *object.ArrayObject
+	if cl.Fn.Arguments {
+		arguments = vm.buildArray(vm.sp-numArgs, vm.sp).(*object.ArrayObject)
+	}
+	// missing arguments are undefined, extra ones are collected by the rest parameter or dropped
+	for ; numArgs < cl.Fn.NumParameters; numArgs++ {
+		err := vm.push(JSUndefined)
+		if err != nil {
+			return err
+		}
+	}
+	extra := numArgs - cl.Fn.NumParameters
+	rest := vm.buildArray(vm.sp-extra, vm.sp)
+	vm.sp -= extra
+	numArgs -= extra
+	if cl.Fn.Rest {
+		err := vm.push(rest)
+		if err != nil {
+			return err
+		}
+		numArgs++
 	}
 	frame := NewFrame(cl, vm.sp-numArgs)
+	frame.this = this
+	frame.arguments = arguments
+	if cl.Fn.Arrow {
+		frame.this = cl.This
+	}
 	vm.pushFrame(frame)
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	// clear locals left by previous calls, so let and const start uninitialized
+	clear(vm.stack[frame.basePointer+numArgs : vm.sp])
 	return nil
 }
 
+// call calls fn with this and args and returns its result, a closure runs until it returns
+func (vm *VM) call(fn object.Object, this object.Object, args ...object.Object) (object.Object, error) {
+	depth := vm.frameIndex
+	err := vm.push(fn)
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		err := vm.push(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = vm.executeCall(len(args), this)
+	if err != nil {
+		return nil, err
+	}
+	err = vm.run(depth)
+	if err != nil {
+		return nil, err
+	}
+	return vm.pop(), nil
+}
+
+// spreadArguments replaces the arguments array on top of stack with its elements
+func (vm *VM) spreadArguments() (int, error) {
+	args := vm.pop().(*object.ArrayObject)
+	for _, arg := range args.Elements {
+		err := vm.push(arg)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(args.Elements), nil
+}
+
+// removeReceiver removes the receiver under the callee and numArgs arguments of a method call and returns it
+func (vm *VM) removeReceiver(numArgs int) object.Object {
+	callee := vm.sp - 1 - numArgs
+	receiver := vm.stack[callee-1]
+	copy(vm.stack[callee-1:], vm.stack[callee:vm.sp])
+	vm.sp--
+	return receiver
+}
+
 func (vm *VM) callBuiltin(callee *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 	result := callee.Fn(args...)
@@ -401,11 +799,8 @@ func (vm *VM) pop() object.Object {
 	return o
 }
 
-func (vm *VM) executeIntegerOperation(op code.Opcode, left object.Object, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
-
-	var result int64
+func (vm *VM) executeNumberOperation(op code.Opcode, leftValue float64, rightValue float64) error {
+	var result float64
 	switch op {
 	case code.OpAdd:
 		result = leftValue + rightValue
@@ -415,19 +810,33 @@ func (vm *VM) executeIntegerOperation(op code.Opcode, left object.Object, right
 		result = leftValue * rightValue
 	case code.OpDiv:
 		result = leftValue / rightValue
+	case code.OpMod:
+		result = math.Mod(leftValue, rightValue)
+	case code.OpExp:
+		result = exponent(leftValue, rightValue)
+	case code.OpShiftLeft:
+		result = float64(toInt32(leftValue) << (toUint32(rightValue) & 31))
+	case code.OpShiftRight:
+		result = float64(toInt32(leftValue) >> (toUint32(rightValue) & 31))
+	case code.OpUnsignedShiftRight:
+		result = float64(toUint32(leftValue) >> (toUint32(rightValue) & 31))
+	case code.OpBitwiseAnd:
+		result = float64(toInt32(leftValue) & toInt32(rightValue))
+	case code.OpBitwiseOr:
+		result = float64(toInt32(leftValue) | toInt32(rightValue))
+	case code.OpBitwiseXor:
+		result = float64(toInt32(leftValue) ^ toInt32(rightValue))
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return fmt.Errorf("unknown number operator: %d", op)
 	}
-	return vm.push(&object.Integer{Value: result})
+	return vm.push(object.NewNumber(result))
 }
 
 func (vm *VM) executeBinaryStringOperation(op code.Opcode, left object.Object, right object.Object) error {
 	if op != code.OpAdd {
 		return fmt.Errorf("string: unknown operator %d", op)
 	}
-	leftValue := left.(*object.StringObject).Value
-	rightValue := right.(*object.StringObject).Value
-	return vm.push(&object.StringObject{Value: leftValue + rightValue})
+	return vm.push(&object.StringObject{Value: toString(left) + toString(right)})
 }
 
 func (vm *VM) buildArray(startIndex int, endIndex int) object.Object {
@@ -439,26 +848,29 @@ func (vm *VM) buildArray(startIndex int, endIndex int) object.Object {
 }
 
 func (vm *VM) buildHash(startIndex int, endIndex int) (object.Object, error) {
-	pairs := make(map[object.HashKey]object.HashPair)
+	o := object.NewObject()
 
 	for i := startIndex; i < endIndex; i += 2 {
-		key := vm.stack[i]
+		key := propertyKey(vm.stack[i])
 		value := vm.stack[i+1]
 
-		pair := object.HashPair{Key: key, Value: value}
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return nil, fmt.Errorf("invalid hash key type: %T", key)
-		}
-		pairs[hashKey.HashKey()] = pair
+		o.Set(key, value)
 	}
-	return &object.ObjectObject{Pairs: pairs}, nil
+	return o, nil
 }
 
 func (vm *VM) executeIndexExpression(left object.Object, i object.Object) error {
+	switch left.(type) {
+	case *object.UndefinedObject, *object.NullObject:
+		return fmt.Errorf("TypeError: cannot read properties of %s (reading '%s')", toString(left), toString(i))
+	case *object.StringObject:
+		return vm.executeStringIndex(left, i)
+	}
 	switch {
 	case left.Type() == object.TypeArray && i.Type() == object.TypeInt:
 		return vm.executeArrayIndex(left, i)
+	case left.Type() == object.TypeArray && i.Type() == object.TypeString:
+		return vm.executeArrayProperty(left, i)
 	case left.Type() == object.TypeObject:
 		return vm.executeObjectIndex(left, i)
 	default:
@@ -478,19 +890,64 @@ func (vm *VM) executeArrayIndex(left object.Object, i object.Object) error {
 	return vm.push(a.Elements[index])
 }
 
-func (vm *VM) executeObjectIndex(left object.Object, i object.Object) error {
-	o := left.(*object.ObjectObject)
-	key, ok := i.(object.Hashable)
+// executeStringIndex pushes the UTF-16 code unit at an integer index as a string, other keys are properties
+func (vm *VM) executeStringIndex(left object.Object, i object.Object) error {
+	index, ok := i.(*object.Integer)
 	if !ok {
-		return fmt.Errorf("invalid object key type: %T", key)
+		value, err := getProperty(left, &object.StringObject{Value: toString(i)})
+		if err != nil {
+			return err
+		}
+		return vm.push(value)
 	}
+	units := utf16.Encode([]rune(left.(*object.StringObject).Value))
+	if index.Value < 0 || index.Value >= int64(len(units)) {
+		return vm.push(JSUndefined)
+	}
+	return vm.push(&object.StringObject{Value: string(utf16.Decode(units[index.Value : index.Value+1]))})
+}
 
-	pair, ok := o.Pairs[key.HashKey()]
+func (vm *VM) executeArrayProperty(left object.Object, i object.Object) error {
+	value, err := getProperty(left, i.(*object.StringObject))
+	if err != nil {
+		return err
+	}
+	return vm.push(value)
+}
+
+func (vm *VM) executeObjectIndex(left object.Object, i object.Object) error {
+	o := left.(*object.ObjectObject)
+
+	value, ok := o.Get(propertyKey(i))
 	if !ok {
 		return vm.push(JSUndefined)
 	}
 
-	return vm.push(pair.Value)
+	return vm.push(value)
+}
+
+// getProperty returns the property key of obj for a.b, the length of arrays and strings too
+func getProperty(obj object.Object, key *object.StringObject) (object.Object, error) {
+	switch obj := obj.(type) {
+	case *object.ObjectObject:
+		if value, ok := obj.Get(key); ok {
+			return value, nil
+		}
+	case *object.ArrayObject:
+		if key.Value == "length" {
+			return &object.Integer{Value: int64(len(obj.Elements))}, nil
+		}
+		if pair, ok := obj.Properties[key.HashKey()]; ok {
+			return pair.Value, nil
+		}
+	case *object.StringObject:
+		if key.Value == "length" {
+			return &object.Integer{Value: int64(len(utf16.Encode([]rune(obj.Value))))}, nil
+		}
+	case *object.UndefinedObject, *object.NullObject:
+		return nil, fmt.Errorf("TypeError: cannot read properties of %s (reading '%s')", toString(obj), key.Value)
+	}
+	return JSUndefined, nil
 }
 
 func (vm *VM) pushClosure(constIndex, numFree int) error {
@@ -506,9 +963,94 @@ func (vm *VM) pushClosure(constIndex, numFree int) error {
 	vm.sp = vm.sp - numFree
 
 	closure := &object.Closure{Fn: function, Free: free}
+	if function.Arrow {
+		closure.This = vm.currentFrame().this
+	}
 	return vm.push(closure)
 }
 
+func referenceError(name object.Object) error {
+	return fmt.Errorf("ReferenceError: cannot access '%s' before initialization", toString(name))
+}
+
+// numberValue returns the float64 value of Integer and NumberObject
+func numberValue(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.NumberObject:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// toNumber is ToNumber, other objects are converted by their string value
+func toNumber(obj object.Object) (float64, error) {
+	if value, ok := numberValue(obj); ok {
+		return value, nil
+	}
+	switch obj := obj.(type) {
+	case *object.BooleanObject:
+		if obj.Value {
+			return 1, nil
+		}
+		return 0, nil
+	case *object.NullObject:
+		return 0, nil
+	case *object.UndefinedObject:
+		return math.NaN(), nil
+	case *object.SymbolObject:
+		return 0, fmt.Errorf("TypeError: cannot convert a Symbol value to a number")
+	}
+	return stringToNumber(toString(obj)), nil
+}
+
+// stringToNumber converts a decimal, 0x, 0o or 0b literal or Infinity surrounded by white space,
+// the empty string is 0 and other strings are NaN
+func stringToNumber(s string) float64 {
+	s = strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\ufeff'
+	})
+	if s == "" {
+		return 0
+	}
+	if len(s) > 2 && s[0] == '0' {
+		base := 0
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 0 {
+			value, ok := new(big.Int).SetString(s[2:], base)
+			if !ok || s[2] == '+' || s[2] == '-' {
+				return math.NaN()
+			}
+			f, _ := new(big.Float).SetInt(value).Float64()
+			return f
+		}
+	}
+	switch s {
+	case "Infinity", "+Infinity":
+		return math.Inf(1)
+	case "-Infinity":
+		return math.Inf(-1)
+	}
+	// ParseFloat accepts inf, nan, underscores and hexadecimal floats too
+	if strings.Trim(s, "0123456789.eE+-") != "" {
+		return math.NaN()
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return math.NaN()
+	}
+	return value
+}
+
 func nativeBoolToBooleanObject(input bool) object.Object {
 	if input {
 		return JSTrue
@@ -517,13 +1059,144 @@ func nativeBoolToBooleanObject(input bool) object.Object {
 	}
 }
 
+// isTruthy is ToBoolean, false, 0, NaN, "", null and undefined are falsy
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case *object.BooleanObject:
 		return obj.Value
-	case *object.NullObject:
+	case *object.Integer:
+		return obj.Value != 0
+	case *object.NumberObject:
+		return obj.Value != 0 && !math.IsNaN(obj.Value)
+	case *object.StringObject:
+		return obj.Value != ""
+	case *object.NullObject, *object.UndefinedObject:
 		return false
 	default:
 		return true
 	}
 }
+
+// toInt32 converts value to a 32-bit integer for bitwise operators, modulo 2^32
+func toInt32(value float64) int32 {
+	return int32(toUint32(value))
+}
+
+func toUint32(value float64) uint32 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+	return uint32(int64(math.Mod(math.Trunc(value), 1<<32)))
+}
+
+// exponent is **, unlike math.Pow 1 ** NaN and (-1) ** ±Infinity are NaN
+func exponent(base, exp float64) float64 {
+	if math.IsNaN(exp) || math.Abs(base) == 1 && math.IsInf(exp, 0) {
+		return math.NaN()
+	}
+	return math.Pow(base, exp)
+}
+
+// strictEquals is ===, numbers, strings and booleans are compared by value, other objects by identity
+func strictEquals(left, right object.Object) bool {
+	leftValue, leftOk := numberValue(left)
+	rightValue, rightOk := numberValue(right)
+	if leftOk && rightOk {
+		return leftValue == rightValue
+	}
+	switch left := left.(type) {
+	case *object.StringObject:
+		right, ok := right.(*object.StringObject)
+		return ok && left.Value == right.Value
+	case *object.BooleanObject:
+		right, ok := right.(*object.BooleanObject)
+		return ok && left.Value == right.Value
+	}
+	return left == right
+}
+
+// caseKey returns the key of a switch value in a jump table, -0 is the key of 0
+func caseKey(obj object.Object) (object.HashKey, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.HashKey(), true
+	case *object.NumberObject:
+		if obj.Value == 0 {
+			return (&object.Integer{}).HashKey(), true
+		}
+	case *object.StringObject:
+		return obj.HashKey(), true
+	}
+	return object.HashKey{}, false
+}
+
+// propertyKey converts obj to a string property key, e.g. 1 and "1" are the same key, symbols are keys too
+func propertyKey(obj object.Object) object.PropertyKey {
+	switch obj := obj.(type) {
+	case *object.StringObject:
+		return obj
+	case *object.SymbolObject:
+		return obj
+	}
+	return &object.StringObject{Value: toString(obj)}
+}
+
+// toString converts obj to a string like String(obj)
+func toString(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.StringObject:
+		return obj.Value
+	case *object.Integer:
+		return strconv.FormatInt(obj.Value, 10)
+	case *object.NumberObject:
+		return numberToString(obj.Value)
+	case *object.BooleanObject:
+		return strconv.FormatBool(obj.Value)
+	case *object.NullObject:
+		return "null"
+	case *object.UndefinedObject:
+		return "undefined"
+	case *object.ArrayObject:
+		elements := make([]string, len(obj.Elements))
+		for i, e := range obj.Elements {
+			if e != JSNull && e != JSUndefined {
+				elements[i] = toString(e)
+			}
+		}
+		return strings.Join(elements, ",")
+	case *object.ObjectObject:
+		return "[object Object]"
+	case *object.RegExpObject:
+		return "/" + obj.Pattern + "/" + obj.Flags
+	case *object.PromiseObject:
+		return "[object Promise]"
+	case *object.SymbolObject:
+		return "Symbol(" + obj.Description + ")"
+	case *object.Closure, *object.Builtin:
+		return "function () { [native code] }"
+	default:
+		return ""
+	}
+}
+
+// numberToString formats value like Number.prototype.toString
+func numberToString(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	case value == 0:
+		return "0"
+	}
+	if abs := math.Abs(value); abs >= 1e21 || abs < 1e-6 {
+		// 1e+21, 1.5e-7
+		s := strconv.FormatFloat(value, 'e', -1, 64)
+		mantissa, exponent, _ := strings.Cut(s, "e")
+		sign, digits := exponent[:1], strings.TrimLeft(exponent[1:], "0")
+		return mantissa + "e" + sign + digits
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
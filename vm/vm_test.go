@@ -134,6 +134,16 @@ func TestFunctionCalls(t *testing.T) {
 `,
 			expected: 1,
 		},
+		{
+			input: `
+	let a = function() {
+		return
+		1
+	};
+	a();
+`,
+			expected: JSUndefined,
+		},
 	}
 	runVMTests(t, tests)
 }
@@ -142,8 +152,134 @@ func TestIndexExpression(t *testing.T) {
 	tests := []vmTest{
 		{"[1, 2, 3][1]", 2},
 		{"[][0]", JSUndefined},
-		{`{a: 1}["a"]`, 1},
-		{`{a: 1}["b"]`, JSUndefined},
+		{`({a: 1})["a"]`, 1},
+		{`({a: 1})["b"]`, JSUndefined},
+		{`({if: 1, class: 2})["class"]`, 2},
+		{`({"a b": 1})["a b"]`, 1},
+		{`({1: 2})[1]`, 2},
+		{`({0x10: 2})["16"]`, 2},
+		{`({1.50: 2})["1.5"]`, 2},
+		{`({["a" + 1]: 2})["a1"]`, 2},
+		{`let a = 3; ({a})["a"]`, 3},
+		{`({a: 1, a: 2})["a"]`, 2},
+		{`({a: {b: 1}})["a"]["b"]`, 1},
+		{`({f(x) { return x * 2 }})["f"](2)`, 4},
+		{`({f: function(x) { return x }})["f"](1)`, 1},
+		{`"abc"[0]`, "a"},
+		{`"aé"[1]`, "é"},
+		{`"abc"[3]`, JSUndefined},
+		{`"abc"["length"]`, 3},
+		{`let s = "abc"; let i = 2; s[i]`, "c"},
+		{`let a; let r; try { a[0] } catch (e) { r = e["message"] } r`, "cannot read properties of undefined (reading '0')"},
+		{`let r; try { null["x"] } catch (e) { r = e["name"] + ": " + e["message"] } r`, "TypeError: cannot read properties of null (reading 'x')"},
+		{`let r; try { r = "abc"[1] } catch (e) { r = e } r`, "b"},
+	}
+	runVMTests(t, tests)
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []vmTest{
+		{"let o = {a: 1}; o.a", 1},
+		{"let o = {a: 1}; o.b", JSUndefined},
+		{"let o = {a: {b: {c: 2}}}; o.a.b.c", 2},
+		{"let o = {if: 1, class: 2}; o.if + o.class", 3},
+		{"[1, 2, 3].length", 3},
+		{`"abc".length`, 3},
+		{"let o = {a: [1, 2]}; o.a[1]", 2},
+		{"let o = {a: 1}; -o.a", -1},
+		{`let t = function(s) { return s.raw[0] }; t` + "`a\\n`", "a\\n"},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"undefined.a", "TypeError: cannot read properties of undefined (reading 'a')"},
+		{"null.a", "TypeError: cannot read properties of null (reading 'a')"},
+		{"let o = {}; o.a.b", "TypeError: cannot read properties of undefined (reading 'b')"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		assert.NoError(t, err)
+
+		err = New(comp.Bytecode()).Run()
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestOptionalChaining(t *testing.T) {
+	tests := []vmTest{
+		{"let o = {a: 1}; o?.a", 1},
+		{"null?.x", JSUndefined},
+		{"undefined?.x", JSUndefined},
+		{"undefined?.()", JSUndefined},
+		{"null?.[0]", JSUndefined},
+		{"null?.a()", JSUndefined},
+		{"let o = {a: null}; o.a?.b.c.d", JSUndefined},
+		{"let o = {a: null}; o.a?.b().c[0]", JSUndefined},
+		{"let o = {a: undefined}; o?.a?.b", JSUndefined},
+		{"let o = {}; o.f?.()", JSUndefined},
+		{"let o = {}; o.f?.(1, 2).g", JSUndefined},
+		{`let o = {}; o["f"]?.(...[1])`, JSUndefined},
+		{"let o = {f: null}; o.f?.().g", JSUndefined},
+		{"let o = {a: {b: 2}}; o?.a?.b", 2},
+		{"let o = {a: [1, 2]}; o?.a?.[1]", 2},
+		{"let f = function() { return 3 }; f?.()", 3},
+		{"let o = {f: function(x) { return x + 1 }}; o.f?.(1)", 2},
+		{"let o = {}; o.a?.b == undefined", true},
+		{"let n = 0; null?.[n++]; n", 0},
+		{"let n = 0; null?.a(n++); n", 0},
+		{"let n = 0; let o = {}; o?.f?.(n++); n", 0},
+		{"let n = 0; let o = {}; let f = function() { n++ }; o.f?.(f(), n++); n", 0},
+		{"let n = 0; let o = {a: null}; o.a?.b(n++).c(n++); n", 0},
+		{"let o = {a: 1}; let r = [o?.b, o?.a]; r[1]", 1},
+		{"let o = {a: 1}; let s = 0; for (let x of [o, null, o]) { if (x?.a == undefined) { s++ } } s", 1},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let o = {}; o?.a.b", "TypeError: cannot read properties of undefined (reading 'b')"},
+		{"let o = {}; o?.f()", "TypeError: undefined is not a function"},
+		{"(null?.a).b", "TypeError: cannot read properties of undefined (reading 'b')"},
+		{"let o = {a: null}; o.a.b", "TypeError: cannot read properties of null (reading 'b')"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		assert.NoError(t, err)
+
+		err = New(comp.Bytecode()).Run()
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []vmTest{
+		{"let o = {n: 1, f() { return this.n }}; o.f()", 1},
+		{`let o = {n: 2, f() { return this.n }}; o["f"]()`, 2},
+		{"let o = {n: 3, f(a, b) { return this.n + a + b }}; o.f(...[1, 2])", 6},
+		{"let o = {n: 4, f() { return this.n }}; o?.f()", 4},
+		{"let o = {n: 5, f() { return this.n }}; o.f?.()", 5},
+		{"let o = {n: 6, f() { let g = () => this.n; return g() }}; o.f()", 6},
+		{"let o = {n: 7, f() { return () => this.n }}; let g = o.f(); g()", 7},
+		{"let o = {n: 1, inc() { this.n; return this }}; o.inc().inc().n", 1},
+		{"let o = {a: {n: 8, f() { return this.n }}}; o.a.f()", 8},
+		{"let f = function() { return this }; f()", JSUndefined},
+		{"let o = {f() { return this }}; let f = o.f; f()", JSUndefined},
+		{"this", JSUndefined},
+		{`
+		let n = 0;
+		let iterable = {
+			[Symbol.iterator]() { return this },
+			next() { n++; return {value: n, done: this.done(n)} },
+			done(n) { return n > 3 }
+		};
+		let s = 0; for (let x of iterable) { s = s + x } s`, 6},
 	}
 	runVMTests(t, tests)
 }
@@ -151,10 +287,10 @@ func TestIndexExpression(t *testing.T) {
 func TestObjectLiterals(t *testing.T) {
 	tests := []vmTest{
 		{
-			"{}", map[object.HashKey]int64{},
+			"({})", map[object.HashKey]int64{},
 		},
 		{
-			"{a: 1, b: 2}",
+			"({a: 1, b: 2})",
 			map[object.HashKey]int64{
 				(&object.StringObject{Value: "a"}).HashKey(): 1,
 				(&object.StringObject{Value: "b"}).HashKey(): 2,
@@ -182,6 +318,36 @@ func TestStringExpressions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestTemplateLiterals(t *testing.T) {
+	tests := []vmTest{
+		{"`a`", "a"},
+		{"`${1}`", "1"},
+		{"`a${1 + 2}b${0.5}c`", "a3b0.5c"},
+		{"`${[1, 2]} ${true} ${`nested ${1e21}`}`", "1,2 true nested 1e+21"},
+		{`"a" + 1`, "a1"},
+		{
+			"let tag = function(s, a) { s[\"raw\"][0] + a + s[1] }; tag`x\\n${1}y`",
+			"x\\n1y",
+		},
+		{
+			"let f = function(s) { s }; let g = function() { f`a` }; g() == g()",
+			true,
+		},
+		{"let o = {n: 1, tag(s, a) { return this.n + a }}; o.tag`x${2}`", 3},
+		{"let o = {n: 1, tag(s) { return this.n }}; o[\"tag\"]`x`", 1},
+		{"let o = {n: 1, tag(s) { return this }}; let tag = o.tag; tag`x`", JSUndefined},
+	}
+	runVMTests(t, tests)
+}
+
+func TestRegExpLiterals(t *testing.T) {
+	tests := []vmTest{
+		{"`${/a+b/gi}`", "/a+b/gi"},
+		{"let f = function() { /a/ }; f() == f()", false},
+	}
+	runVMTests(t, tests)
+}
+
 func TestGlobalLet(t *testing.T) {
 	tests := []vmTest{
 		{
@@ -195,15 +361,574 @@ func TestGlobalLet(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestVarConst(t *testing.T) {
+	tests := []vmTest{
+		{"var a; a", JSUndefined},
+		{"let a; a", JSUndefined},
+		{"a; var a = 1; a", 1},
+		{"var a = 1; var a; a", 1},
+		{"const a = 1; if (true) { const a = 2 } a", 1},
+		{"let a = 1; if (true) { let b = 2; a = b } a", 2},
+		{"let f = function() { if (true) { var a = 1 } return a }; f()", 1},
+		{"let f = function() { if (false) { var a = 1 } return a }; f()", JSUndefined},
+		{"let f = function(a) { var a; return a }; f(3)", 3},
+		{"let f = function() { return a }; let a = 1; f()", 1},
+		{"let a = 1; let b = a = 2; b", 2},
+		{"let a = 1; { let a = 2 } a", 1},
+		{"let a = 1; { let a = 2; a }", 2},
+		{"let a = 1; { a = 2 } a", 2},
+		{"let f = function() { { var a = 1 } return a }; f()", 1},
+		{"let g; { let a = 3; g = function() { return a } } g()", 3},
+		{"{ let f = function() { return x }; let x = 1; f() }", 1},
+		{"let g = function() { let f = function() { return x }; let x = 1; return f() }; g()", 1},
+		{"let f = () => c; const c = 2; f()", 2},
+		{"let g; { let f = function() { return x }; g = f; let x = 3 } g()", 3},
+		{"let async = 1; async", 1},
+		{"var let = 1; let = let + 1; let", 2},
+		{"let yield = 1; let await = 2; yield + await", 3},
+		{"let async = x => x + 1; async(2)", 3},
+		{"let n = 0; for (let async = 0; async < 3; async++) { n = async } n", 2},
+	}
+	runVMTests(t, tests)
+
+	comp := compiler.New()
+	err := comp.Compile(parse("{ const c = 1 } c"))
+	assert.EqualError(t, err, "symbol not found: c")
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []vmTest{
+		{"let a = 1; a += 2; a", 3},
+		{`let a = "a"; a += "b"`, "ab"},
+		{"let a = 10; a -= 3; a *= 2; a /= 7; a", 2},
+		{"let a = 7; a %= 4", 3},
+		{"let a = 2; a **= 3", 8},
+		{"let a = 1; a <<= 4", 16},
+		{"let a = -16; a >>= 2", -4},
+		{"let a = -1; a >>>= 28", 15},
+		{"let a = 12; a &= 10", 8},
+		{"let a = 12; a |= 3", 15},
+		{"let a = 12; a ^= 10", 6},
+		{"let a = 0; a &&= 5; a", 0},
+		{"let a = 1; a &&= 5; a", 5},
+		{`let a = ""; a ||= 5; a`, 5},
+		{"let a = 2; a ||= 5; a", 2},
+		{"let u = function() {}; let a = u(); a ??= 3; a", 3},
+		{"let a = 0; a ??= 3; a", 0},
+		{"let n = 0; let f = function() { n += 1; return 1 }; let a = 1; a ||= f(); a &&= 0; a &&= f(); n", 0},
+		{"let a = 1; let b = 2; a += b *= 3; a * 10 + b", 76},
+		{"let f = function(x) { x += 1; x *= 2; return x }; f(2)", 6},
+	}
+	runVMTests(t, tests)
+}
+
+func TestUpdateExpressions(t *testing.T) {
+	tests := []vmTest{
+		{"let a = 1; a++", 1},
+		{"let a = 1; a++; a", 2},
+		{"let a = 1; ++a", 2},
+		{"let a = 1; a--", 1},
+		{"let a = 1; --a; a", 0},
+		{"let f = function() { let i = 0; let j = i++ + ++i; return j * 10 + i }; f()", 22},
+		{"let a = 1; let b = 1; a\n++b; a * 10 + b", 12},
+		{"let s = 0; for (let i = 0; i < 4; i++) { s += i } s", 6},
+		{`let s = "5"; s++; s`, 6},
+		{`let s = "5"; s++`, 5},
+		{`let s = " 0x10 "; ++s`, 17},
+		{`let s = "1.5"; --s`, 0.5},
+		{`let s = ""; s--`, 0},
+		{`let s = "a"; s++; s != s`, true},
+		{`let s = "1e3"; s++`, 1000},
+		{`let s = "0x-1"; ++s; s != s`, true},
+		{`let s = "inf"; ++s; s != s`, true},
+		{"let b = true; b++; b", 2},
+		{"let b = false; b--", 0},
+		{"let n = null; ++n", 1},
+		{"let u; u++; u != u", true},
+		{"let u; let r = u--; r != r", true},
+	}
+	runVMTests(t, tests)
+}
+
+func TestCapturedAssignment(t *testing.T) {
+	tests := []vmTest{
+		{"let counter = function() { let n = 0; return function() { n += 1; return n } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = function() { let n = 0; let inc = function() { n++ }; inc(); inc(); return n }; f()", 2},
+		{"let f = function() { let n = 1; let g = function() { return n }; n = 5; return g() }; f()", 5},
+		{"let f = function() { let n = 0; let inc = function() { n++ }; let get = function() { return n }; inc(); return get() }; f()", 1},
+		{"let f = function() { let n = 0; let g = function() { return function() { return ++n } }; g()(); return g()() }; f()", 2},
+		{"let f = function(n) { let g = function() { n *= 2 }; g(); return n }; f(3)", 6},
+		{"let f = function([n]) { let g = function() { n *= 2 }; g(); return n }; f([3])", 6},
+		{"let f = function() { let g = function() { return n }; let n = 1; n = 2; return g() }; f()", 2},
+		{"let r; if (true) { let n = 1; let g = function() { n++ }; g(); r = n } r", 2},
+		{"let f = function() { var g; for (let i = 0; i < 3; i++) { var x = i; if (i == 0) { g = function() { return x } } } return g() }; f()", 2},
+		{"let f = function() { try { throw 1 } catch (e) { let g = function() { e += 1 }; g(); return e } }; f()", 2},
+	}
+	runVMTests(t, tests)
+}
+
+func TestTemporalDeadZone(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a; let a = 1", "ReferenceError: cannot access 'a' before initialization"},
+		{"let a = a", "ReferenceError: cannot access 'a' before initialization"},
+		{"a = 1; let a", "ReferenceError: cannot access 'a' before initialization"},
+		{"let f = function() { return a }; f(); const a = 1", "ReferenceError: cannot access 'a' before initialization"},
+		{"let f = function() { let g = function() { b }; g(); let b = 1 }; f()", "ReferenceError: cannot access 'b' before initialization"},
+		{"let f = function() { a = 1 }; f(); let a", "ReferenceError: cannot access 'a' before initialization"},
+		{"let f = function() { a += 1 }; f(); let a = 1", "ReferenceError: cannot access 'a' before initialization"},
+		{"let f = function() { let g = function() { b = 2 }; g(); let b = 1; b = 3 }; f()", "ReferenceError: cannot access 'b' before initialization"},
+		{"let x = 1; { x; let x = 2 }", "ReferenceError: cannot access 'x' before initialization"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		assert.NoError(t, err)
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTest{
+		{"let s = 0; let i = 0; while (i < 5) { i = i + 1; s = s + i } s", 15},
+		{"let s = 0; do { s = s + 1 } while (s < 3); s", 3},
+		{"let s = 0; do s = s + 1; while (false) s", 1},
+		{"let s = 0; for (let i = 0; i < 5; i = i + 1) { s = s + i } s", 10},
+		{"let s = 0; for (let i = 0; i < 5; i = i + 1) { if (i == 2) { continue } s = s + i } s", 8},
+		{"let s = 0; for (;;) { s = s + 1; if (s == 3) { break } } s", 3},
+		{"let i = 0; for (i = 1; i < 3; i = i + 1) {} i", 3},
+		{"let f = function() { var s = 0; for (var i = 0; i < 4; i = i + 1) { s = s + i } return s + i }; f()", 10},
+		{"let f = function() { while (true) { return 1 } }; f()", 1},
+	}
+	runVMTests(t, tests)
+}
+
+func TestLabeledLoops(t *testing.T) {
+	tests := []vmTest{
+		{`
+	let s = 0;
+	outer: for (let i = 0; i < 3; i = i + 1) {
+		for (let j = 0; j < 3; j = j + 1) {
+			if (j == 1) { continue outer }
+			if (i == 2) { break outer }
+			s = s + 10
+		}
+		s = s + 1
+	}
+	s`, 20},
+		{"let s = 0; a: b: while (true) { s = s + 1; if (s < 3) { continue a } break b } s", 3},
+		{"let s = 1; a: if (true) { break a; s = 2 } s", 1},
+	}
+	runVMTests(t, tests)
+}
+
+func TestForInOf(t *testing.T) {
+	tests := []vmTest{
+		{"let s = 0; for (const x of [1, 2, 3]) { s = s + x } s", 6},
+		{`let s = ""; for (let c of "a😀b") { s = s + c + "," } s`, "a,😀,b,"},
+		{`let s = ""; for (let k in {b: 1, 2: 1, a: 1, 1: 1}) { s = s + k + "," } s`, "1,2,b,a,"},
+		{`let s = ""; for (let i in ["a", "b"]) { s = s + i } s`, "01"},
+		{`let s = ""; for (let i in "a😀") { s = s + i } s`, "012"},
+		{"let x; for (x of [1, 2]) {} x", 2},
+		{"let f = function() { for (var x of [1, 2]) {} return x }; f()", 2},
+		{"let s = 0; for (let x of [1, 2, 3, 4]) { if (x == 2) { continue } if (x == 4) { break } s = s + x } s", 4},
+		{"let f; for (let x of [1, 2, 3]) { if (x == 2) { f = function() { return x } } } f()", 2},
+		{"let f = function() { for (let x of [1, 2]) { for (let y of [3, 4]) { return x + y } } }; f()", 4},
+	}
+	runVMTests(t, tests)
+}
+
+func TestIteratorProtocol(t *testing.T) {
+	iterable := `
+	let i = 0;
+	let closed = 0;
+	let iterable = {
+		[Symbol["iterator"]]: function() {
+			i = 0;
+			return {
+				next: function() { i = i + 1; return {value: i, done: i > 3} },
+				return: function() { closed = closed + 1; return {} }
+			}
+		}
+	};
+`
+	tests := []vmTest{
+		{iterable + "let s = 0; for (let x of iterable) { s = s + x } s", 6},
+		{iterable + "for (let x of iterable) {} closed", 0},
+		{iterable + "for (let x of iterable) { if (x == 2) { break } } closed", 1},
+		{iterable + "for (let x of iterable) { continue } closed", 0},
+		{iterable + "outer: for (let a of [1, 2]) { for (let x of iterable) { continue outer } } closed", 2},
+		{iterable + "let f = function() { for (let x of iterable) { return x } }; f() + closed", 2},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let x of 1) {}", "TypeError: 1 is not iterable"},
+		{`for (let x of {a: 1}) {}`, "TypeError: [object Object] is not iterable"},
+		{`for (let x of {[Symbol["iterator"]]: function() { return 1 }}) {}`, "TypeError: result of the Symbol.iterator method is not an object"},
+		{"const x = 0; for (x of [1]) {}", "TypeError: assignment to constant variable 'x'"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err == nil {
+			err = New(comp.Bytecode()).Run()
+		}
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestEnumeratePrototypes(t *testing.T) {
+	key := func(s string) *object.StringObject { return &object.StringObject{Value: s} }
+	proto := object.NewObject()
+	proto.Set(key("a"), JSNull)
+	proto.Set(key("c"), JSNull)
+	proto.Set(object.NewSymbol("s"), JSNull)
+	obj := object.NewObject()
+	obj.Set(key("b"), JSNull)
+	obj.Set(key("a"), JSNull)
+	obj.Prototype = proto
+
+	var keys []string
+	iterator := enumerate(obj)
+	for k, ok := iterator.Step(); ok; k, ok = iterator.Step() {
+		keys = append(keys, k.(*object.StringObject).Value)
+	}
+	assert.Equal(t, []string{"b", "a", "c"}, keys)
+}
+
+func TestSwitch(t *testing.T) {
+	tests := []vmTest{
+		{`let f = function(x) { switch (x) { case 1: return "a"; case "1": return "b"; default: return "c" } }; f(1) + f("1") + f(2)`, "abc"},
+		{`let f = function(x) { switch (x) { case 0: return "a"; default: return "b" } }; f(-0) + f(0.5) + f(true)`, "abb"},
+		{`let f = function(x) { let s = ""; switch (x) { case 1: s = s + "1"; default: s = s + "d"; case 2: s = s + "2"; break; case 3: s = s + "3" } return s }; f(1) + f(2) + f(3) + f(4)`, "1d223d2"},
+		{`let one = 1; let f = function(x) { let s = ""; switch (x) { case one: s = s + "1"; default: s = s + "d"; case one + 1: s = s + "2"; break; case "3": s = s + "3" } return s }; f(1) + f(2) + f("3") + f(3)`, "1d223d2"},
+		{`let s = ""; switch ("b") { case "a": s = "a"; break; case "b": s = "b"; break; case "b": s = "c" } s`, "b"},
+		{`let s = 0; switch (1) { } s`, 0},
+		{`let s = 0; switch (1) { case 2: s = 1 } s`, 0},
+		{"let s = 0; for (let i = 0; i < 4; i = i + 1) { switch (i) { case 1: continue; case 3: break; default: s = s + i } } s", 2},
+		{"let s = 0; a: switch (1) { case 1: for (let x of [1]) { break a } s = 1 } s", 0},
+		{"let f = function(x) { switch (x) { case 1: var a = 1; let b = 2; return a + b; default: return a } }; f(1)", 3},
+		{"let f = function(x) { switch (x) { case 1: const c = 1; default: return c } }; f(1)", 1},
+		{"let f = function(x) { switch (x) { case 1: break; default: 2 } }; f(1)", JSUndefined},
+		{"let f = function(x) { if (x) { switch (x) { case 1: break; default: 2 } } }; f(1)", JSNull},
+		{"let f = function(x) { switch (x) { case 1: let {a} = {a: 5}; return a } }; f(1)", 5},
+		{"let f = function(x) { switch (x) { case 1: let [a, {b}] = [1, {b: 2}]; default: return a + b } }; f(1)", 3},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"switch (2) { case 1: const c = 1; default: c }", "ReferenceError: cannot access 'c' before initialization"},
+		{"switch (1) { case c: let c = 1 }", "ReferenceError: cannot access 'c' before initialization"},
+		{"switch (2) { case 1: let {a} = {a: 5}; default: a }", "ReferenceError: cannot access 'a' before initialization"},
+		{"switch (1) { case 1: let c; case 2: let c }", "SyntaxError: identifier 'c' has already been declared"},
+		{"switch (1) { case 1: continue }", "SyntaxError: illegal continue statement"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err == nil {
+			err = New(comp.Bytecode()).Run()
+		}
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	iterable := `
+	let closed = 0;
+	let iterable = {
+		[Symbol["iterator"]]: function() {
+			return {
+				next: function() { return {value: 1, done: false} },
+				return: function() { closed = closed + 1; return {} }
+			}
+		}
+	};
+`
+	tests := []vmTest{
+		{"let r; try { throw 1 } catch (e) { r = e } r", 1},
+		{"let r = 0; try { r = 1 } catch { r = 2 } r", 1},
+		{`let r; try { throw "x" } catch { r = 2 } r`, 2},
+		{`let f = function() { throw "boom" }; let r; try { f() } catch (e) { r = e } r`, "boom"},
+		{"let f = function(n) { if (n == 0) { throw n } return f(n - 1) }; let r; try { f(5) } catch (e) { r = e } r", 0},
+		{`let s = ""; try { s = s + "a" } finally { s = s + "b" } s`, "ab"},
+		{`let s = ""; try { try { throw 1 } finally { s = s + "f" } } catch (e) { s = s + e } s`, "f1"},
+		{`let s = ""; try { try { throw 1 } catch (e) { throw e + 1 } finally { s = s + "f" } } catch (e) { s = s + e } s`, "f2"},
+		{`let s = ""; let f = function() { try { return "r" } finally { s = s + "f" } }; f() + s`, "rf"},
+		{"let f = function() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let f = function() { try { throw 1 } finally { return 2 } }; f()", 2},
+		{`let s = ""; for (let i = 0; i < 3; i = i + 1) { try { if (i == 1) { break } s = s + i } finally { s = s + "f" } } s`, "0ff"},
+		{`let s = ""; for (let i = 0; i < 2; i = i + 1) { try { continue } finally { s = s + i } } s`, "01"},
+		{"let s = 0; for (let x of [1, 2]) { try { throw x } catch (e) { s = s + e } } s", 3},
+		{`let x = 1; let r; try { x() } catch (e) { r = e["name"] + ": " + e["message"] } r`, "TypeError: 1 is not a function"},
+		{`let r; try { a; let a = 1 } catch (e) { r = e["name"] } r`, "ReferenceError"},
+		{`let f = function() { f() }; let r; try { f() } catch (e) { r = e["name"] } r`, "RangeError"},
+		{iterable + "try { for (let x of iterable) { throw 1 } } catch {} closed", 1},
+		{iterable + "let f = function() { for (let x of iterable) { throw 1 } }; try { f() } catch {} closed", 1},
+		{`let it = {[Symbol["iterator"]]: function() { return {next: function() { throw "n" }} }}; let r; try { for (let x of it) {} } catch (e) { r = e } r`, "n"},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"throw 1", "Uncaught 1"},
+		{"let f = function() { try { throw 1 } finally { 2 } }; f()", "Uncaught 1"},
+		{"let x = 1; x()", "TypeError: 1 is not a function"},
+		{"try {} catch (e) { let e }", "SyntaxError: identifier 'e' has already been declared"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err == nil {
+			err = New(comp.Bytecode()).Run()
+		}
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestArrowFunctions(t *testing.T) {
+	tests := []vmTest{
+		{"let double = x => x * 2; double(4)", 8},
+		{"let add = (a, b) => { return a + b }; add(1, 2)", 3},
+		{"let f = () => 5; f()", 5},
+		{"let adder = a => b => a + b; adder(1)(2)", 3},
+		{`let f = () => ({a: 1}); f()["a"]`, 1},
+		{"let apply = (f, x) => f(x); apply(x => x + 1, 1)", 2},
+		{"let f = function() { return arguments }; f(1, 2)", []int{1, 2}},
+		{"let f = function(a) { return arguments.length }; f() + f(1, 2, 3)", 3},
+		{"let f = function(a, ...b) { return arguments[2] }; f(1, 2, 3)", 3},
+		{"let f = function() { let g = () => arguments[0]; return g(2) }; f(1)", 1},
+		{"let f = function() { return () => () => arguments[0] }; f(4)()()", 4},
+		{"let f = function() { let g = function() { return arguments[0] }; return g(2) }; f(1)", 2},
+		{"let f = function(arguments) { return arguments }; f(5)", 5},
+		{"let f = function() { let arguments = 6; return arguments }; f(1)", 6},
+		{"let f = function() { let g = () => { arguments = [7] }; g(); return arguments[0] }; f(1)", 7},
+		{"let o = {n: 1, f() { return (() => this.n)() }}; o.f()", 1},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = () => arguments; f()", "symbol not found: arguments"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestAsyncFunctions(t *testing.T) {
+	tests := []vmTest{
+		{"let f = async x => x; f(1)", fulfilled{1}},
+		{"let f = async () => { return 2 }; f()", fulfilled{2}},
+		{"let f = async function(x) { return await x + 1 }; f(1)", fulfilled{2}},
+		{"let f = async x => (await x) * 2; f(f(2))", fulfilled{8}},
+		{"let g = async () => 1; let f = async () => await g() + await g(); f()", fulfilled{2}},
+		{"let g = async () => 3; let f = async () => g(); f()", fulfilled{3}},
+		{`let s = ""; let f = async () => { s += "a"; await 0; s += "c"; return s }; let p = f(); s += "b"; p`, fulfilled{"abc"}},
+		{`let s = ""; let f = async x => { await 0; s += x }; let g = async () => { f("a"); await f("b"); return s }; g()`, fulfilled{"ab"}},
+		{"let f = async () => { throw 1 }; f()", rejected{1}},
+		{"let f = async () => { await 0; undefined() }; let g = async () => { try { await f() } catch (e) { return 1 } }; g()", fulfilled{1}},
+		{"let g = async () => { throw 2 }; let f = async () => { try { await g() } catch (e) { return e + 1 } }; f()", fulfilled{3}},
+		{"let f = async () => { let s = 0; for (let x of [1, 2]) { s += await x } return s }; f()", fulfilled{3}},
+		{"let o = {n: 1, m() { return (async x => this.n + arguments[0] + await x)(2) }}; o.m(3)", fulfilled{6}},
+		{"let f = async function() { return (async () => arguments[0])() }; f(4)", fulfilled{4}},
+		{"let f = async function(a = await1()) {}; let await1 = function() { throw 5 }; f()", rejected{5}},
+	}
+	runVMTests(t, tests)
+}
+
+func TestParametersAndSpread(t *testing.T) {
+	tests := []vmTest{
+		{"let f = function(a, b) { return b }; f(1)", JSUndefined},
+		{"let f = function(a) { return a }; f(1, 2, 3)", 1},
+		{"let f = function(a, b = a + 1) { return b }; f(1)", 2},
+		{"let f = function(a, b = a + 1) { return b }; f(1, 5)", 5},
+		{"let f = function(a = 1) { return a }; let u = function() {}; f(u())", 1},
+		{"let f = function(a = 1) { return a }; f(0)", 0},
+		{"let f = function(a, ...rest) { return rest }; f(1, 2, 3)", []int{2, 3}},
+		{"let f = function(...rest) { return rest }; f()", []int{}},
+		{"let f = (a = 2, ...rest) => a + rest[1]; let u = function() {}; f(u(), 3, 4)", 6},
+		{"let f = function(a, b, c) { return a + b * c }; let xs = [2, 3]; f(1, ...xs)", 7},
+		{"let f = function(...rest) { return rest }; f(...[1, 2], 3, ...[])", []int{1, 2, 3}},
+		{"let a = [2, 3]; [1, ...a, 4, ...a]", []int{1, 2, 3, 4, 2, 3}},
+		{`let f = function(...args) { return args }; f(..."ab")[1]`, "b"},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = function(a = b, b) {}; f()", "ReferenceError: cannot access 'b' before initialization"},
+		{"let f = function(a = a) {}; f()", "ReferenceError: cannot access 'a' before initialization"},
+		{"[...1]", "TypeError: 1 is not iterable"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		assert.NoError(t, err)
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []vmTest{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, , b] = [1, 2, 3]; b", 3},
+		{"let [a, b = 5] = [1]; b", 5},
+		{"let [a, ...rest] = [1, 2, 3]; rest", []int{2, 3}},
+		{"let [a, [b, c]] = [1, [2, 3]]; c", 3},
+		{`let [a, b] = "xy"; b`, "y"},
+		{"const {a, b: c} = {a: 1, b: 2}; a + c", 3},
+		{"let {a, b: [c] = [4]} = {a: 1}; c", 4},
+		{"let {a = 1, b = a + 1} = {}; b", 2},
+		{`let {["a"]: x} = {a: 3}; x`, 3},
+		{`let {a, ...rest} = {a: 1, b: 2, c: 3}; rest["c"]`, 3},
+		{"let {length} = [1, 2]; length", 2},
+		{`let {length} = "abc"; length`, 3},
+		{`let {["length"]: n} = [1]; n`, 1},
+		{"let {0: a, 1: b} = [7, 8]; a + b", 15},
+		{"var [a] = [1]; a", 1},
+		{"let a = 1; let b = 2; [a, b] = [b, a]; a - b", 1},
+		{"let a; let b; ({a, b = 3} = {a: 1}); a + b", 4},
+		{"let a; let c = ([a] = [1, 2]); c", []int{1, 2}},
+		{"let f = function([a, b], {c}) { return a + b + c }; f([1, 2], {c: 3})", 6},
+		{"let f = function({a} = {a: 5}) { return a }; f()", 5},
+		{"let f = ([a, b] = [1, 2], c = a + b) => c; f()", 3},
+		{"let sum = 0; for (const [k, v] of [[1, 2], [3, 4]]) { sum = sum + k * v } sum", 14},
+		{"let a; let b; for ([a, b] of [[1, 2]]) {} a + b", 3},
+	}
+	runVMTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = function() {}; let {a} = f()", "TypeError: cannot read properties of undefined (reading 'a')"},
+		{`let {["b"]: b} = null`, "TypeError: cannot read properties of null (reading 'b')"},
+		{"let {} = null", "TypeError: cannot destructure null"},
+		{"let {...r} = undefined", "TypeError: cannot destructure undefined"},
+		{`let k = "a"; let {[k]: a, ...r} = {a: 1}`, "computed keys with a rest element are not supported"},
+		{"let [a] = 1", "TypeError: 1 is not iterable"},
+		{"let [a = b, b] = []", "ReferenceError: cannot access 'b' before initialization"},
+		{"let f = function(a = b, {b}) {}; f()", "ReferenceError: cannot access 'b' before initialization"},
+		{"const [a] = [1]; [a] = [2]", "TypeError: assignment to constant variable 'a'"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err == nil {
+			vm := New(comp.Bytecode())
+			err = vm.Run()
+		}
+		assert.EqualError(t, err, tt.expected, tt.input)
+	}
+}
+
+func TestDestructuringIterators(t *testing.T) {
+	iterable := `
+	let i = 0;
+	let closed = 0;
+	let iterable = {
+		[Symbol["iterator"]]: function() {
+			i = 0;
+			return {
+				next: function() { i = i + 1; return {value: i, done: i > 3} },
+				return: function() { closed = closed + 1; return {} }
+			}
+		}
+	};
+`
+	tests := []vmTest{
+		{iterable + "let [a, b] = iterable; a + b * 10 + closed * 100", 121},
+		{iterable + "let [a, ...b] = iterable; b[1] + closed * 100", 3},
+		{iterable + "let [a, b, c, d, e] = iterable; closed", 0},
+		{iterable + "try { let [a, [b]] = iterable } catch {} i + closed * 100", 102},
+	}
+	runVMTests(t, tests)
+}
+
+func TestLoopClosures(t *testing.T) {
+	tests := []vmTest{
+		{"let f; for (let i = 0; i < 3; i = i + 1) { if (i == 1) { f = function() { return i } } } f()", 1},
+		{"let f; let g; for (let i = 0; i < 3; i++) { if (i == 0) { f = function() { return i } } if (i == 1) { g = function() { i += 10; return i } } } g() * 100 + f()", 1100},
+		{`
+	let g = function() {
+		let f;
+		for (let i = 0; i < 3; i = i + 1) {
+			let j = i * 2;
+			if (i == 1) { f = function() { return i + j } }
+		}
+		return f();
+	}
+	g()`, 3},
+	}
+	runVMTests(t, tests)
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []vmTest{
 		{"if (true) { 10 }", 10},
+		{"if (0) { 10 } else { 20 }", 20},
+		{`if ("") { 10 } else { 20 }`, 20},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 > 2) { 10 }", JSNull},
 	}
 	runVMTests(t, tests)
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	tests := []vmTest{
+		{"1 && 2", 2},
+		{"0 && 2", 0},
+		{"null && 2", JSNull},
+		{`"" || "a"`, "a"},
+		{"1 || 2", 1},
+		{"null || 1", 1},
+		{"undefined || 0", 0},
+		{"null ?? 1", 1},
+		{"undefined ?? 1", 1},
+		{"0 ?? 1", 0},
+		{"false ?? 1", false},
+		{`"" ?? 1`, ""},
+		{"null ?? undefined ?? 2", 2},
+		{"null ?? undefined", JSUndefined},
+		{"1 || 2 && 0", 1},
+		{"(1 || 2) && 0", 0},
+		{"0 || 2 && 3", 3},
+		{"(null || 0) ?? 1", 0},
+		{"let n = 0; let f = function() { n++; return true }; false && f(); true || f(); 1 ?? f(); n", 0},
+		{"let n = 0; let f = function() { n++; return true }; true && f(); false || f(); null ?? f(); undefined ?? f(); n", 4},
+		{"let o = {}; o.a?.b ?? 5", 5},
+		{"let o = {a: null}; o.a ?? 6", 6},
+		{"let r = 0; if (1 > 0 && 2 > 1) { r = 1 } r", 1},
+		{"let s = 0; for (let i = 0; i < 5 && s < 6; i++) { s += i } s", 6},
+	}
+	runVMTests(t, tests)
+}
+
 func TestIntegerArithmetic(t *testing.T) {
 	tests := []vmTest{
 		{"1", 1},
@@ -213,6 +938,22 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"6 - 2 * 2", 2},
 		{"6 - 2 / 2", 5},
 		{"(6 - 2) / 2", 2},
+		{"1 / 2", 0.5},
+		{"1.5 + .5", 2},
+		{"0x10 * 0b10", 32},
+		{"-2.5", -2.5},
+		{"1e3 > 999", true},
+		{"7 % 4", 3},
+		{"-7 % 4", -3},
+		{"5.5 % 2", 1.5},
+		{"2 ** 3 ** 2", 512},
+		{"2 ** -1", 0.5},
+		{"1 + 2 << 1", 6},
+		{"1 << 4 | 1", 17},
+		{"-16 >> 2", -4},
+		{"-1 >>> 28", 15},
+		{"6 & 3 ^ 1", 3},
+		{"4294967297 | 0", 1},
 	}
 	runVMTests(t, tests)
 }
@@ -227,6 +968,8 @@ func TestBoolean(t *testing.T) {
 		{"!true", false},
 		{"!!true", true},
 		{"!5", false},
+		{"!0", true},
+		{`!""`, true},
 	}
 	runVMTests(t, tests)
 }
@@ -263,6 +1006,23 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testNumberObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.NumberObject)
+	if !ok {
+		return fmt.Errorf("object is not number. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object expected %g, got %g", expected, result.Value)
+	}
+
+	return nil
+}
+
+// fulfilled and rejected are the expected promise returned by an async function call
+type fulfilled struct{ value interface{} }
+type rejected struct{ value interface{} }
+
 type vmTest struct {
 	input    string
 	expected interface{}
@@ -323,6 +1083,9 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 	case int:
 		err := testIntegerObject(int64(expected), actual)
 		assert.NoError(t, err)
+	case float64:
+		err := testNumberObject(expected, actual)
+		assert.NoError(t, err)
 	case string:
 		err := testStringObject(expected, actual)
 		assert.NoError(t, err)
@@ -331,5 +1094,22 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		assert.NoError(t, err)
 	case *object.NullObject:
 		assert.Equal(t, JSNull, actual)
+	case *object.UndefinedObject:
+		assert.Equal(t, JSUndefined, actual)
+	case fulfilled:
+		testPromiseObject(t, object.PromiseFulfilled, expected.value, actual)
+	case rejected:
+		testPromiseObject(t, object.PromiseRejected, expected.value, actual)
+	}
+}
+
+func testPromiseObject(t *testing.T, state object.PromiseState, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	promise, ok := actual.(*object.PromiseObject)
+	if !assert.True(t, ok, "object is not promise. got=%T (%+v)", actual, actual) {
+		return
 	}
+	assert.Equal(t, state, promise.State)
+	testExpectedObject(t, expected, promise.Value)
 }